@@ -3,28 +3,75 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config représente la configuration de l'application
 type Config struct {
 	// Serveur
 	ServerPort int
+	PublicURL  string // URL publique du site, utilisée dans les liens générés (QR codes...)
 
 	// Base de données
 	DatabasePath string
 
+	// Fichiers envoyés par les utilisateurs (preuves de défis...)
+	UploadDir string
+
+	// Intervalle de passage de la tâche qui abandonne les défis en retard
+	ChallengeSweepInterval time.Duration
+
+	// Délai accordé après la durée minimale d'un défi pour envoyer sa preuve
+	// (CHALLENGE_GRACE_PERIOD, 7 jours par défaut)
+	ChallengeGracePeriod time.Duration
+
+	// Intervalle de traitement de la file des événements (attribution des badges...)
+	EventWorkerInterval time.Duration
+
 	// JWT
-	JWTSecret          string
-	JWTExpirationHours int
+	JWTSecret       string
+	AccessTokenTTL  time.Duration // Durée de vie d'un jeton d'accès
+	RefreshTokenTTL time.Duration // Durée de vie d'un jeton de renouvellement
+
+	// Sessions des navigateurs (cookie)
+	SessionTTL    time.Duration
+	SecureCookies bool // Cookies envoyés uniquement en HTTPS
+
+	// Envoi des emails: par SMTP si SMTPHost est renseigné, sinon écrits dans MailDir
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+	MailDir      string
+
+	// Durée de validité d'un lien de confirmation d'adresse email
+	EmailVerificationTTL time.Duration
+
+	// Durée de validité d'un lien de réinitialisation du mot de passe
+	PasswordResetTTL time.Duration
 }
 
 // LoadConfig charge la configuration depuis les variables d'environnement ou utilise des valeurs par défaut
 func LoadConfig() *Config {
 	config := &Config{
-		ServerPort:         8080,
-		DatabasePath:       "./bdd.db",
-		JWTSecret:          "BDDSecretKey", // À remplacer par une clé sécurisée en production
-		JWTExpirationHours: 24,
+		ServerPort:             8080,
+		PublicURL:              "http://localhost:8080",
+		DatabasePath:           "./bdd.db",
+		UploadDir:              "./uploads",
+		ChallengeSweepInterval: time.Hour,
+		ChallengeGracePeriod:   7 * 24 * time.Hour,
+		EventWorkerInterval:    2 * time.Second,
+		JWTSecret:              "BDDSecretKey", // À remplacer par une clé sécurisée en production
+		AccessTokenTTL:         15 * time.Minute,
+		RefreshTokenTTL:        30 * 24 * time.Hour,
+		SessionTTL:             7 * 24 * time.Hour,
+		SMTPPort:               587,
+		MailFrom:               "BDD <no-reply@localhost>",
+		MailDir:                "./mails",
+		EmailVerificationTTL:   48 * time.Hour,
+		PasswordResetTTL:       time.Hour,
 	}
 
 	// Chargement des variables d'environnement si définies
@@ -34,17 +81,107 @@ func LoadConfig() *Config {
 		}
 	}
 
+	if publicURL, exists := os.LookupEnv("PUBLIC_URL"); exists {
+		config.PublicURL = strings.TrimRight(publicURL, "/")
+	}
+
+	// Par défaut, les cookies sont sécurisés si le site est servi en HTTPS
+	config.SecureCookies = strings.HasPrefix(config.PublicURL, "https://")
+	if secure, exists := os.LookupEnv("SECURE_COOKIES"); exists {
+		if b, err := strconv.ParseBool(secure); err == nil {
+			config.SecureCookies = b
+		}
+	}
+
 	if dbPath, exists := os.LookupEnv("DATABASE_PATH"); exists {
 		config.DatabasePath = dbPath
 	}
 
+	if uploadDir, exists := os.LookupEnv("UPLOAD_DIR"); exists {
+		config.UploadDir = uploadDir
+	}
+
+	if sweep, exists := os.LookupEnv("CHALLENGE_SWEEP_INTERVAL"); exists {
+		if d, err := time.ParseDuration(sweep); err == nil && d > 0 {
+			config.ChallengeSweepInterval = d
+		}
+	}
+
+	if grace, exists := os.LookupEnv("CHALLENGE_GRACE_PERIOD"); exists {
+		if d, err := time.ParseDuration(grace); err == nil && d >= 0 {
+			config.ChallengeGracePeriod = d
+		}
+	}
+
+	if interval, exists := os.LookupEnv("EVENT_WORKER_INTERVAL"); exists {
+		if d, err := time.ParseDuration(interval); err == nil && d > 0 {
+			config.EventWorkerInterval = d
+		}
+	}
+
 	if jwtSecret, exists := os.LookupEnv("JWT_SECRET"); exists {
 		config.JWTSecret = jwtSecret
 	}
 
-	if jwtExp, exists := os.LookupEnv("JWT_EXPIRATION_HOURS"); exists {
-		if exp, err := strconv.Atoi(jwtExp); err == nil {
-			config.JWTExpirationHours = exp
+	// JWT_EXPIRATION_HOURS, l'ancienne durée de validité des jetons en heures,
+	// reste lue si ACCESS_TOKEN_TTL n'est pas définie
+	if ttl, exists := os.LookupEnv("ACCESS_TOKEN_TTL"); exists {
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			config.AccessTokenTTL = d
+		}
+	} else if jwtExp, exists := os.LookupEnv("JWT_EXPIRATION_HOURS"); exists {
+		if exp, err := strconv.Atoi(jwtExp); err == nil && exp > 0 {
+			config.AccessTokenTTL = time.Duration(exp) * time.Hour
+		}
+	}
+
+	if ttl, exists := os.LookupEnv("REFRESH_TOKEN_TTL"); exists {
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			config.RefreshTokenTTL = d
+		}
+	}
+
+	if ttl, exists := os.LookupEnv("SESSION_TTL"); exists {
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			config.SessionTTL = d
+		}
+	}
+
+	if host, exists := os.LookupEnv("SMTP_HOST"); exists {
+		config.SMTPHost = host
+	}
+
+	if port, exists := os.LookupEnv("SMTP_PORT"); exists {
+		if p, err := strconv.Atoi(port); err == nil {
+			config.SMTPPort = p
+		}
+	}
+
+	if username, exists := os.LookupEnv("SMTP_USERNAME"); exists {
+		config.SMTPUsername = username
+	}
+
+	if password, exists := os.LookupEnv("SMTP_PASSWORD"); exists {
+		config.SMTPPassword = password
+	}
+
+	if from, exists := os.LookupEnv("MAIL_FROM"); exists {
+		config.MailFrom = from
+	}
+
+	if mailDir, exists := os.LookupEnv("MAIL_DIR"); exists {
+		config.MailDir = mailDir
+	}
+
+	if ttl, exists := os.LookupEnv("EMAIL_VERIFICATION_TTL"); exists {
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			config.EmailVerificationTTL = d
+		}
+	}
+
+	if ttl, exists := os.LookupEnv("PASSWORD_RESET_TTL"); exists {
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			config.PasswordResetTTL = d
 		}
 	}
 
@@ -3,29 +3,82 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
 )
 
+// Statuts possibles d'une activité
+const (
+	ActivityScheduled = "scheduled"
+	ActivityCancelled = "cancelled"
+)
+
+// Tris possibles de la liste des activités
+const (
+	ActivitySortDate       = "date"
+	ActivitySortPopularity = "popularity"
+	ActivitySortPoints     = "points"
+)
+
+// activitySortOrders associe chaque tri autorisé à sa clause ORDER BY
+var activitySortOrders = map[string]string{
+	ActivitySortDate:       "a.start_date ASC",
+	ActivitySortPopularity: "current_participants DESC, a.start_date ASC",
+	ActivitySortPoints:     "a.eco_points DESC, a.start_date ASC",
+}
+
 // CreateActivity crée une nouvelle activité dans la base de données
 func CreateActivity(db *sql.DB, activity models.ActivityCreate) (int64, error) {
+	// Créer l'activité et ses tags dans une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
 	// Insérer l'activité
-	result, err := db.Exec(
+	result, err := tx.Exec(
 		`INSERT INTO activities 
-		(title, description, image_path, start_date, end_date, location, max_participants, eco_points, updated_at) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		(title, description, image_path, start_date, end_date, location, latitude, longitude, max_participants, eco_points, category, updated_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		activity.Title, activity.Description, activity.ImagePath,
-		activity.StartDate, activity.EndDate, activity.Location,
-		activity.MaxParticipants, activity.EcoPoints, time.Now(),
+		activity.StartDate, activity.EndDate, activity.Location, activity.Latitude, activity.Longitude,
+		activity.MaxParticipants, activity.EcoPoints, activity.Category, time.Now(),
 	)
 
 	if err != nil {
+		tx.Rollback()
 		return 0, err
 	}
 
 	// Récupérer l'ID généré
-	return result.LastInsertId()
+	activityID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Enregistrer les tags
+	if err = setActivityTagsTx(tx, activityID, activity.Tags); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Enregistrer les facteurs d'impact
+	if err = setImpactFactorsTx(tx, "activity_id", activityID, activity.Impact); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return activityID, nil
 }
 
 // UpdateActivity met à jour une activité existante
@@ -41,20 +94,97 @@ func UpdateActivity(db *sql.DB, activityID int64, activity models.ActivityUpdate
 		return errors.New("activité non trouvée")
 	}
 
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
 	// Mettre à jour l'activité
-	_, err = db.Exec(
+	_, err = tx.Exec(
 		`UPDATE activities 
 		SET title = ?, description = ?, image_path = ?, 
-		    start_date = ?, end_date = ?, location = ?, 
-		    max_participants = ?, eco_points = ?, updated_at = ?
+		    start_date = ?, end_date = ?, location = ?, latitude = ?, longitude = ?,
+		    max_participants = ?, eco_points = ?, category = ?, revision = revision + 1, updated_at = ?
 		WHERE id = ?`,
 		activity.Title, activity.Description, activity.ImagePath,
-		activity.StartDate, activity.EndDate, activity.Location,
-		activity.MaxParticipants, activity.EcoPoints, time.Now(),
+		activity.StartDate, activity.EndDate, activity.Location, activity.Latitude, activity.Longitude,
+		activity.MaxParticipants, activity.EcoPoints, activity.Category, time.Now(),
 		activityID,
 	)
 
-	return err
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Remplacer les tags s'ils sont fournis
+	if activity.Tags != nil {
+		if err = setActivityTagsTx(tx, activityID, *activity.Tags); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Remplacer les facteurs d'impact s'ils sont fournis
+	if activity.Impact != nil {
+		if err = setImpactFactorsTx(tx, "activity_id", activityID, *activity.Impact); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Promouvoir la liste d'attente si des places se sont libérées
+	if _, err = promoteFromWaitlistTx(tx, activityID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CancelActivity annule une activité sans la supprimer: elle disparaît de la liste des activités
+// mais reste publiée comme annulée dans les flux iCalendar. Retourne les inscriptions à prévenir.
+func CancelActivity(db *sql.DB, activityID int64) ([]models.CancelledRegistration, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var status string
+	var endDate time.Time
+	err = tx.QueryRow("SELECT status, end_date FROM activities WHERE id = ?", activityID).Scan(&status, &endDate)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, errors.New("activité non trouvée")
+		}
+		return nil, err
+	}
+
+	if status == ActivityCancelled {
+		tx.Rollback()
+		return nil, errors.New("cette activité est déjà annulée")
+	}
+
+	now := time.Now()
+	if now.After(endDate) {
+		tx.Rollback()
+		return nil, errors.New("une activité terminée ne peut pas être annulée")
+	}
+
+	registrations, err := cancelActivityTx(tx, activityID, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return registrations, nil
 }
 
 // DeleteActivity supprime une activité
@@ -83,6 +213,20 @@ func DeleteActivity(db *sql.DB, activityID int64) error {
 		return err
 	}
 
+	// Supprimer la liste d'attente liée
+	_, err = tx.Exec("DELETE FROM waitlist WHERE activity_id = ?", activityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Supprimer les présences liées
+	_, err = tx.Exec("DELETE FROM attendances WHERE activity_id = ?", activityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Mettre à null les références dans eco_points
 	_, err = tx.Exec("UPDATE eco_points SET activity_id = NULL WHERE activity_id = ?", activityID)
 	if err != nil {
@@ -90,6 +234,19 @@ func DeleteActivity(db *sql.DB, activityID int64) error {
 		return err
 	}
 
+	// Supprimer les facteurs d'impact et conserver les impacts déjà enregistrés
+	_, err = tx.Exec("DELETE FROM impact_factors WHERE activity_id = ?", activityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE impact_entries SET activity_id = NULL WHERE activity_id = ?", activityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Supprimer l'activité
 	_, err = tx.Exec("DELETE FROM activities WHERE id = ?", activityID)
 	if err != nil {
@@ -101,22 +258,25 @@ func DeleteActivity(db *sql.DB, activityID int64) error {
 }
 
 // GetActivities récupère les activités avec pagination et filtres
-func GetActivities(db *sql.DB, page, pageSize int, upcoming bool, userID int64) ([]models.Activity, int, error) {
+func GetActivities(db *sql.DB, page, pageSize int, filter models.ActivityFilter, userID int64) ([]models.Activity, int, error) {
 	// Calculer l'offset pour la pagination
 	offset := (page - 1) * pageSize
 
 	// Base de la requête
 	baseQuery := `
 		SELECT a.id, a.title, a.description, a.image_path, 
-		       a.start_date, a.end_date, a.location, 
-		       a.max_participants, a.eco_points, a.created_at, a.updated_at,
-		       COUNT(r.id) as current_participants
+		       a.start_date, a.end_date, a.location, a.latitude, a.longitude,
+		       a.max_participants, a.eco_points, a.category, a.series_id, a.status, a.revision, a.created_at, a.updated_at,
+		       COUNT(r.id) as current_participants,
+		       (SELECT COUNT(*) FROM waitlist w WHERE w.activity_id = a.id) as waitlist_count
 	`
 
-	// Ajouter un champ pour indiquer si l'utilisateur est inscrit (si userID > 0)
+	// Ajouter les champs indiquant si l'utilisateur est inscrit ou en liste d'attente (si userID > 0)
 	if userID > 0 {
 		baseQuery += `, 
-		(SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = a.id)) as user_registered
+		(SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = a.id)) as user_registered,
+		(SELECT COUNT(*) FROM waitlist w WHERE w.activity_id = a.id
+		   AND w.position <= (SELECT position FROM waitlist WHERE user_id = ? AND activity_id = a.id)) as waitlist_position
 		`
 	}
 
@@ -127,17 +287,24 @@ func GetActivities(db *sql.DB, page, pageSize int, upcoming bool, userID int64)
 	`
 
 	// Ajouter les filtres
-	whereClause := ""
-	if upcoming {
-		whereClause = " WHERE a.end_date >= ?"
+	whereClause, filterArgs := buildActivityFilter(filter)
+
+	// Déterminer l'ordre de tri (valeur inconnue: tri par date)
+	orderBy, ok := activitySortOrders[filter.Sort]
+	if !ok {
+		orderBy = activitySortOrders[ActivitySortDate]
 	}
 
-	// Grouper et ordonner
+	// Grouper et ordonner.
+	// En recherche de proximité, la distance est calculée en Go: toutes les activités
+	// du rectangle englobant sont lues, puis triées et paginées après calcul.
 	groupAndOrder := `
 		GROUP BY a.id
-		ORDER BY a.start_date ASC
-		LIMIT ? OFFSET ?
-	`
+		ORDER BY ` + orderBy
+	if filter.Near == nil {
+		groupAndOrder += `
+		LIMIT ? OFFSET ?`
+	}
 
 	// Construire la requête finale
 	query := baseQuery + whereClause + groupAndOrder
@@ -145,12 +312,12 @@ func GetActivities(db *sql.DB, page, pageSize int, upcoming bool, userID int64)
 	// Préparer les arguments
 	var args []interface{}
 	if userID > 0 {
-		args = append(args, userID)
+		args = append(args, userID, userID)
 	}
-	if upcoming {
-		args = append(args, time.Now())
+	args = append(args, filterArgs...)
+	if filter.Near == nil {
+		args = append(args, pageSize, offset)
 	}
-	args = append(args, pageSize, offset)
 
 	// Exécuter la requête
 	var rows *sql.Rows
@@ -167,19 +334,21 @@ func GetActivities(db *sql.DB, page, pageSize int, upcoming bool, userID int64)
 	for rows.Next() {
 		var activity models.Activity
 		var startDate, endDate, createdAt, updatedAt time.Time
+		var seriesID sql.NullInt64
+		var latitude, longitude sql.NullFloat64
 		var userRegistered sql.NullBool
 
 		// Préparer les variables pour le scan
 		scanArgs := []interface{}{
 			&activity.ID, &activity.Title, &activity.Description, &activity.ImagePath,
-			&startDate, &endDate, &activity.Location,
-			&activity.MaxParticipants, &activity.EcoPoints, &createdAt, &updatedAt,
-			&activity.CurrentParticipants,
+			&startDate, &endDate, &activity.Location, &latitude, &longitude,
+			&activity.MaxParticipants, &activity.EcoPoints, &activity.Category, &seriesID, &activity.Status, &activity.Revision, &createdAt, &updatedAt,
+			&activity.CurrentParticipants, &activity.WaitlistCount,
 		}
 
-		// Ajouter userRegistered si nécessaire
+		// Ajouter userRegistered et la position en liste d'attente si nécessaire
 		if userID > 0 {
-			scanArgs = append(scanArgs, &userRegistered)
+			scanArgs = append(scanArgs, &userRegistered, &activity.WaitlistPosition)
 		}
 
 		// Scanner les résultats
@@ -192,6 +361,9 @@ func GetActivities(db *sql.DB, page, pageSize int, upcoming bool, userID int64)
 		activity.EndDate = endDate
 		activity.CreatedAt = createdAt
 		activity.UpdatedAt = updatedAt
+		activity.SeriesID = seriesID.Int64
+		activity.Latitude = nullFloatPtr(latitude)
+		activity.Longitude = nullFloatPtr(longitude)
 
 		// Assigner userRegistered si nécessaire
 		if userID > 0 && userRegistered.Valid {
@@ -207,35 +379,166 @@ func GetActivities(db *sql.DB, page, pageSize int, upcoming bool, userID int64)
 
 	// Récupérer le total pour la pagination
 	var total int
-	countQuery := "SELECT COUNT(*) FROM activities"
-	if upcoming {
-		countQuery += " WHERE end_date >= ?"
-		err = db.QueryRow(countQuery, time.Now()).Scan(&total)
+	if filter.Near != nil {
+		activities = sortActivitiesByDistance(activities, *filter.Near, filter.RadiusKm)
+		total = len(activities)
+		activities = paginateActivities(activities, offset, pageSize)
 	} else {
-		err = db.QueryRow(countQuery).Scan(&total)
+		err = db.QueryRow("SELECT COUNT(*) FROM activities a"+whereClause, filterArgs...).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
 	}
 
-	if err != nil {
+	// Récupérer les tags des activités
+	if err = loadActivityTags(db, activities); err != nil {
 		return nil, 0, err
 	}
 
 	return activities, total, nil
 }
 
+// sortActivitiesByDistance calcule la distance de chaque activité au point donné,
+// écarte celles situées hors du rayon et trie les autres de la plus proche à la plus lointaine
+func sortActivitiesByDistance(activities []models.Activity, near models.GeoPoint, radiusKm float64) []models.Activity {
+	nearby := []models.Activity{}
+	for _, activity := range activities {
+		if activity.Latitude == nil || activity.Longitude == nil {
+			continue
+		}
+
+		distance := utils.DistanceKm(near.Lat, near.Lng, *activity.Latitude, *activity.Longitude)
+		if distance > radiusKm {
+			continue
+		}
+
+		// Arrondir à 10 mètres
+		distance = math.Round(distance*100) / 100
+		activity.DistanceKm = &distance
+		nearby = append(nearby, activity)
+	}
+
+	// Tri stable: à distance égale, l'ordre demandé (date par défaut) est conservé
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return *nearby[i].DistanceKm < *nearby[j].DistanceKm
+	})
+
+	return nearby
+}
+
+// paginateActivities retourne la page demandée d'une liste d'activités déjà triée
+func paginateActivities(activities []models.Activity, offset, pageSize int) []models.Activity {
+	if offset >= len(activities) {
+		return []models.Activity{}
+	}
+
+	end := offset + pageSize
+	if end > len(activities) {
+		end = len(activities)
+	}
+
+	return activities[offset:end]
+}
+
+// nullFloatPtr convertit un réel SQL nullable en pointeur (nil si NULL)
+func nullFloatPtr(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+// buildActivityFilter construit la clause WHERE correspondant aux filtres de la liste des activités.
+// Les conditions sont des fragments fixes: les valeurs sont toujours passées en paramètres.
+func buildActivityFilter(filter models.ActivityFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if !filter.IncludeCancelled {
+		conditions = append(conditions, "a.status != ?")
+		args = append(args, ActivityCancelled)
+	}
+
+	if filter.Upcoming {
+		conditions = append(conditions, "a.end_date >= ?")
+		args = append(args, time.Now())
+	}
+
+	if filter.Category != "" {
+		conditions = append(conditions, "a.category = ?")
+		args = append(args, filter.Category)
+	}
+
+	if filter.Tag != "" {
+		conditions = append(conditions, "EXISTS(SELECT 1 FROM activity_tags t WHERE t.activity_id = a.id AND t.tag = ?)")
+		args = append(args, strings.ToLower(filter.Tag))
+	}
+
+	if filter.Location != "" {
+		conditions = append(conditions, `a.location LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(filter.Location)+"%")
+	}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "a.end_date >= ?")
+		args = append(args, filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "a.start_date <= ?")
+		args = append(args, filter.To)
+	}
+
+	if filter.Located {
+		conditions = append(conditions, "a.latitude IS NOT NULL AND a.longitude IS NOT NULL")
+	}
+
+	if filter.Near != nil {
+		// Pré-filtre sur le rectangle englobant le cercle de recherche
+		minLat, maxLat, minLng, maxLng, wrapsLng := utils.BoundingBox(filter.Near.Lat, filter.Near.Lng, filter.RadiusKm)
+		conditions = append(conditions, "a.latitude BETWEEN ? AND ? AND a.longitude IS NOT NULL")
+		args = append(args, minLat, maxLat)
+		if !wrapsLng {
+			conditions = append(conditions, "a.longitude BETWEEN ? AND ?")
+			args = append(args, minLng, maxLng)
+		}
+	}
+
+	if filter.HasFreeSeats {
+		conditions = append(conditions, `a.status = ? AND (a.max_participants <= 0
+			OR (SELECT COUNT(*) FROM registrations r2 WHERE r2.activity_id = a.id) < a.max_participants)`)
+		args = append(args, ActivityScheduled)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// escapeLike échappe les caractères spéciaux d'un motif LIKE
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 // GetActivity récupère les détails d'une activité spécifique
 func GetActivity(db *sql.DB, activityID int64, userID int64) (*models.Activity, error) {
 	// Construire la requête
 	query := `
 		SELECT a.id, a.title, a.description, a.image_path, 
-		       a.start_date, a.end_date, a.location, 
-		       a.max_participants, a.eco_points, a.created_at, a.updated_at,
-		       COUNT(r.id) as current_participants
+		       a.start_date, a.end_date, a.location, a.latitude, a.longitude,
+		       a.max_participants, a.eco_points, a.category, a.series_id, a.status, a.revision, a.created_at, a.updated_at,
+		       COUNT(r.id) as current_participants,
+		       (SELECT COUNT(*) FROM waitlist w WHERE w.activity_id = a.id) as waitlist_count
 	`
 
-	// Ajouter un champ pour indiquer si l'utilisateur est inscrit (si userID > 0)
+	// Ajouter les champs indiquant si l'utilisateur est inscrit ou en liste d'attente (si userID > 0)
 	if userID > 0 {
 		query += `, 
-		(SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = a.id)) as user_registered
+		(SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = a.id)) as user_registered,
+		(SELECT COUNT(*) FROM waitlist w WHERE w.activity_id = a.id
+		   AND w.position <= (SELECT position FROM waitlist WHERE user_id = ? AND activity_id = a.id)) as waitlist_position
 		`
 	}
 
@@ -250,26 +553,28 @@ func GetActivity(db *sql.DB, activityID int64, userID int64) (*models.Activity,
 	// Préparer les arguments
 	var args []interface{}
 	if userID > 0 {
-		args = append(args, userID)
+		args = append(args, userID, userID)
 	}
 	args = append(args, activityID)
 
 	// Exécuter la requête
 	var activity models.Activity
 	var startDate, endDate, createdAt, updatedAt time.Time
+	var seriesID sql.NullInt64
+	var latitude, longitude sql.NullFloat64
 	var userRegistered sql.NullBool
 
 	// Préparer les variables pour le scan
 	scanArgs := []interface{}{
 		&activity.ID, &activity.Title, &activity.Description, &activity.ImagePath,
-		&startDate, &endDate, &activity.Location,
-		&activity.MaxParticipants, &activity.EcoPoints, &createdAt, &updatedAt,
-		&activity.CurrentParticipants,
+		&startDate, &endDate, &activity.Location, &latitude, &longitude,
+		&activity.MaxParticipants, &activity.EcoPoints, &activity.Category, &seriesID, &activity.Status, &activity.Revision, &createdAt, &updatedAt,
+		&activity.CurrentParticipants, &activity.WaitlistCount,
 	}
 
-	// Ajouter userRegistered si nécessaire
+	// Ajouter userRegistered et la position en liste d'attente si nécessaire
 	if userID > 0 {
-		scanArgs = append(scanArgs, &userRegistered)
+		scanArgs = append(scanArgs, &userRegistered, &activity.WaitlistPosition)
 	}
 
 	// Exécuter la requête
@@ -287,108 +592,182 @@ func GetActivity(db *sql.DB, activityID int64, userID int64) (*models.Activity,
 	activity.EndDate = endDate
 	activity.CreatedAt = createdAt
 	activity.UpdatedAt = updatedAt
+	activity.SeriesID = seriesID.Int64
+	activity.Latitude = nullFloatPtr(latitude)
+	activity.Longitude = nullFloatPtr(longitude)
 
 	// Assigner userRegistered si nécessaire
 	if userID > 0 && userRegistered.Valid {
 		activity.UserRegistered = userRegistered.Bool
 	}
 
+	// Récupérer les tags
+	activity.Tags, err = getActivityTags(db, activityID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Récupérer les facteurs d'impact
+	activity.Impact, err = getImpactFactors(db, "activity_id", activityID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &activity, nil
 }
 
-// RegisterToActivity inscrit un utilisateur à une activité
-func RegisterToActivity(db *sql.DB, userID, activityID int64) error {
-	// Vérifier si l'utilisateur est déjà inscrit
-	var isRegistered bool
-	err := db.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = ?)",
-		userID, activityID,
-	).Scan(&isRegistered)
+// RegisterToActivity inscrit un utilisateur à une activité.
+// Si l'activité est complète, l'utilisateur est placé sur la liste d'attente
+// et sa position (à partir de 1) est retournée ; elle vaut 0 en cas d'inscription directe.
+// Toutes les vérifications et l'insertion ont lieu dans une seule transaction IMMEDIATE,
+// ce qui empêche deux inscriptions concurrentes de dépasser max_participants.
+func RegisterToActivity(db *sql.DB, userID, activityID int64) (int, error) {
+	// Démarrer une transaction (verrou d'écriture pris immédiatement, cf. InitDB)
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	// Vérifier si l'utilisateur est déjà inscrit ou en liste d'attente
+	var isRegistered, isWaitlisted bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = ?),
+		       EXISTS(SELECT 1 FROM waitlist WHERE user_id = ? AND activity_id = ?)
+	`, userID, activityID, userID, activityID).Scan(&isRegistered, &isWaitlisted)
 
 	if err != nil {
-		return err
+		tx.Rollback()
+		return 0, err
 	}
 
 	if isRegistered {
-		return errors.New("vous êtes déjà inscrit à cette activité")
+		tx.Rollback()
+		return 0, errors.New("vous êtes déjà inscrit à cette activité")
 	}
 
-	// Vérifier si l'activité existe et n'est pas déjà pleine
-	var maxParticipants, currentParticipants int
-	var activityStartDate time.Time
-	var ecoPoints int
-
-	err = db.QueryRow(`
-		SELECT a.max_participants, COUNT(r.id), a.start_date, a.eco_points
-		FROM activities a
-		LEFT JOIN registrations r ON a.id = r.activity_id
-		WHERE a.id = ?
-		GROUP BY a.id
-	`, activityID).Scan(&maxParticipants, &currentParticipants, &activityStartDate, &ecoPoints)
+	if isWaitlisted {
+		tx.Rollback()
+		return 0, errors.New("vous êtes déjà sur la liste d'attente de cette activité")
+	}
 
+	// Vérifier si l'activité existe, n'est pas annulée et n'est pas déjà passée
+	var activityStartDate time.Time
+	var status string
+	err = tx.QueryRow("SELECT start_date, status FROM activities WHERE id = ?", activityID).Scan(&activityStartDate, &status)
 	if err != nil {
+		tx.Rollback()
 		if err == sql.ErrNoRows {
-			return errors.New("activité non trouvée")
+			return 0, errors.New("activité non trouvée")
 		}
-		return err
+		return 0, err
 	}
 
-	if maxParticipants > 0 && currentParticipants >= maxParticipants {
-		return errors.New("cette activité est complète")
+	if status == ActivityCancelled {
+		tx.Rollback()
+		return 0, errors.New("cette activité a été annulée")
 	}
 
-	// Vérifier si l'activité n'est pas déjà passée
 	if time.Now().After(activityStartDate) {
-		return errors.New("impossible de s'inscrire à une activité passée")
+		tx.Rollback()
+		return 0, errors.New("impossible de s'inscrire à une activité passée")
 	}
 
-	// Démarrer une transaction
-	tx, err := db.Begin()
+	// Inscrire l'utilisateur seulement s'il reste une place et que personne n'attend déjà
+	result, err := tx.Exec(`
+		INSERT INTO registrations (user_id, activity_id)
+		SELECT ?, a.id
+		FROM activities a
+		WHERE a.id = ?
+		  AND NOT EXISTS (SELECT 1 FROM waitlist WHERE activity_id = a.id)
+		  AND (a.max_participants <= 0
+		       OR (SELECT COUNT(*) FROM registrations WHERE activity_id = a.id) < a.max_participants)
+	`, userID, activityID)
+
 	if err != nil {
-		return err
+		tx.Rollback()
+		return 0, err
 	}
 
-	// Inscrire l'utilisateur
-	_, err = tx.Exec(
-		"INSERT INTO registrations (user_id, activity_id) VALUES (?, ?)",
-		userID, activityID,
-	)
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if inserted == 1 {
+		// Valider la transaction
+		return 0, tx.Commit()
+	}
+
+	// Activité complète: placer l'utilisateur en liste d'attente
+	_, err = tx.Exec(`
+		INSERT INTO waitlist (user_id, activity_id, position)
+		VALUES (?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM waitlist WHERE activity_id = ?))
+	`, userID, activityID, activityID)
 
 	if err != nil {
 		tx.Rollback()
-		return err
+		return 0, err
 	}
 
-	// Valider la transaction
-	return tx.Commit()
+	position, err := waitlistPositionTx(tx, userID, activityID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return position, tx.Commit()
 }
 
-// UnregisterFromActivity désinscrire un utilisateur d'une activité
+// UnregisterFromActivity désinscrire un utilisateur d'une activité ou le retire de sa liste d'attente.
+// Une place libérée est attribuée au premier utilisateur de la liste d'attente.
 func UnregisterFromActivity(db *sql.DB, userID, activityID int64) error {
-	// Vérifier si l'utilisateur est inscrit
-	var isRegistered bool
-	err := db.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = ?)",
-		userID, activityID,
-	).Scan(&isRegistered)
+	// Démarrer une transaction (verrou d'écriture pris immédiatement, cf. InitDB)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Vérifier si l'utilisateur est inscrit ou en liste d'attente
+	var isRegistered, isWaitlisted bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = ?),
+		       EXISTS(SELECT 1 FROM waitlist WHERE user_id = ? AND activity_id = ?)
+	`, userID, activityID, userID, activityID).Scan(&isRegistered, &isWaitlisted)
 
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	if !isRegistered {
+	if !isRegistered && !isWaitlisted {
+		tx.Rollback()
 		return errors.New("vous n'êtes pas inscrit à cette activité")
 	}
 
+	// Quitter la liste d'attente ne libère aucune place
+	if !isRegistered {
+		_, err = tx.Exec(
+			"DELETE FROM waitlist WHERE user_id = ? AND activity_id = ?",
+			userID, activityID,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+
 	// Vérifier si l'activité n'est pas déjà passée
 	var activityStartDate time.Time
 
-	err = db.QueryRow(
+	err = tx.QueryRow(
 		"SELECT start_date FROM activities WHERE id = ?",
 		activityID,
 	).Scan(&activityStartDate)
 
 	if err != nil {
+		tx.Rollback()
 		if err == sql.ErrNoRows {
 			return errors.New("activité non trouvée")
 		}
@@ -396,16 +775,103 @@ func UnregisterFromActivity(db *sql.DB, userID, activityID int64) error {
 	}
 
 	if time.Now().After(activityStartDate) {
+		tx.Rollback()
 		return errors.New("impossible de se désinscrire d'une activité passée")
 	}
 
 	// Désinscrire l'utilisateur
-	_, err = db.Exec(
+	_, err = tx.Exec(
 		"DELETE FROM registrations WHERE user_id = ? AND activity_id = ?",
 		userID, activityID,
 	)
 
-	return err
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Attribuer la place libérée à la liste d'attente
+	if _, err = promoteFromWaitlistTx(tx, activityID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// promoteFromWaitlistTx inscrit les premiers utilisateurs de la liste d'attente
+// tant qu'il reste des places et que l'activité n'a pas commencé.
+// Retourne les utilisateurs promus.
+func promoteFromWaitlistTx(tx *sql.Tx, activityID int64) ([]int64, error) {
+	promoted := []int64{}
+
+	for {
+		// Récupérer la capacité et le nombre d'inscrits actuel
+		var maxParticipants, currentParticipants int
+		var startDate time.Time
+		var status string
+
+		err := tx.QueryRow(`
+			SELECT a.max_participants, a.start_date, a.status,
+			       (SELECT COUNT(*) FROM registrations WHERE activity_id = a.id)
+			FROM activities a
+			WHERE a.id = ?
+		`, activityID).Scan(&maxParticipants, &startDate, &status, &currentParticipants)
+
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return promoted, nil
+			}
+			return nil, err
+		}
+
+		// Plus de place, activité annulée ou déjà commencée
+		if status == ActivityCancelled || time.Now().After(startDate) ||
+			(maxParticipants > 0 && currentParticipants >= maxParticipants) {
+			return promoted, nil
+		}
+
+		// Récupérer le premier utilisateur de la liste d'attente
+		var waitlistID, userID int64
+		err = tx.QueryRow(
+			"SELECT id, user_id FROM waitlist WHERE activity_id = ? ORDER BY position ASC LIMIT 1",
+			activityID,
+		).Scan(&waitlistID, &userID)
+
+		if err == sql.ErrNoRows {
+			return promoted, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Inscrire l'utilisateur et le retirer de la liste d'attente
+		_, err = tx.Exec(
+			"INSERT OR IGNORE INTO registrations (user_id, activity_id) VALUES (?, ?)",
+			userID, activityID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec("DELETE FROM waitlist WHERE id = ?", waitlistID)
+		if err != nil {
+			return nil, err
+		}
+
+		promoted = append(promoted, userID)
+	}
+}
+
+// waitlistPositionTx calcule la position actuelle d'un utilisateur sur la liste d'attente d'une activité
+func waitlistPositionTx(tx *sql.Tx, userID, activityID int64) (int, error) {
+	var position int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM waitlist
+		WHERE activity_id = ?
+		  AND position <= (SELECT position FROM waitlist WHERE user_id = ? AND activity_id = ?)
+	`, activityID, userID, activityID).Scan(&position)
+
+	return position, err
 }
 
 // GetUserRegistrations récupère les activités auxquelles un utilisateur est inscrit
@@ -413,9 +879,10 @@ func GetUserRegistrations(db *sql.DB, userID int64, includeHistory bool) ([]mode
 	// Construire la requête
 	query := `
 		SELECT a.id, a.title, a.description, a.image_path, 
-		       a.start_date, a.end_date, a.location, 
-		       a.max_participants, a.eco_points, a.created_at, a.updated_at,
+		       a.start_date, a.end_date, a.location, a.latitude, a.longitude,
+		       a.max_participants, a.eco_points, a.category, a.series_id, a.status, a.revision, a.created_at, a.updated_at,
 		       COUNT(r2.id) as current_participants,
+		       (SELECT COUNT(*) FROM waitlist w WHERE w.activity_id = a.id) as waitlist_count,
 		       1 as user_registered
 		FROM activities a
 		JOIN registrations r ON a.id = r.activity_id AND r.user_id = ?
@@ -453,13 +920,15 @@ func GetUserRegistrations(db *sql.DB, userID int64, includeHistory bool) ([]mode
 	for rows.Next() {
 		var activity models.Activity
 		var startDate, endDate, createdAt, updatedAt time.Time
+		var seriesID sql.NullInt64
+		var latitude, longitude sql.NullFloat64
 		var userRegistered bool
 
 		err := rows.Scan(
 			&activity.ID, &activity.Title, &activity.Description, &activity.ImagePath,
-			&startDate, &endDate, &activity.Location,
-			&activity.MaxParticipants, &activity.EcoPoints, &createdAt, &updatedAt,
-			&activity.CurrentParticipants, &userRegistered,
+			&startDate, &endDate, &activity.Location, &latitude, &longitude,
+			&activity.MaxParticipants, &activity.EcoPoints, &activity.Category, &seriesID, &activity.Status, &activity.Revision, &createdAt, &updatedAt,
+			&activity.CurrentParticipants, &activity.WaitlistCount, &userRegistered,
 		)
 
 		if err != nil {
@@ -471,6 +940,9 @@ func GetUserRegistrations(db *sql.DB, userID int64, includeHistory bool) ([]mode
 		activity.EndDate = endDate
 		activity.CreatedAt = createdAt
 		activity.UpdatedAt = updatedAt
+		activity.SeriesID = seriesID.Int64
+		activity.Latitude = nullFloatPtr(latitude)
+		activity.Longitude = nullFloatPtr(longitude)
 		activity.UserRegistered = userRegistered
 
 		activities = append(activities, activity)
@@ -480,5 +952,10 @@ func GetUserRegistrations(db *sql.DB, userID int64, includeHistory bool) ([]mode
 		return nil, err
 	}
 
+	// Récupérer les tags des activités
+	if err = loadActivityTags(db, activities); err != nil {
+		return nil, err
+	}
+
 	return activities, nil
 }
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestDB crée une base temporaire avec le schéma et le DSN de production (cf. InitDB).
+// Les migrations sont lues depuis la racine du dépôt.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	MigrationsDir = filepath.Join("..", "..", "migrations")
+
+	db, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// TestRegisterToActivityConcurrent inscrit de nombreux utilisateurs en même temps à une activité
+// de capacité limitée: exactement max_participants inscriptions doivent être confirmées,
+// les autres utilisateurs étant placés en liste d'attente.
+func TestRegisterToActivityConcurrent(t *testing.T) {
+	const users = 60
+	const capacity = 5
+
+	db := openTestDB(t)
+
+	result, err := db.Exec(
+		`INSERT INTO activities (title, description, start_date, end_date, location, max_participants)
+		VALUES ('Nettoyage', 'Test de concurrence', ?, ?, 'Campus', ?)`,
+		time.Now().Add(24*time.Hour), time.Now().Add(26*time.Hour), capacity,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activityID, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userIDs := make([]int64, users)
+	for i := range userIDs {
+		result, err := db.Exec(
+			"INSERT INTO users (email, username, password_hash) VALUES (?, ?, 'x')",
+			fmt.Sprintf("user%d@example.com", i), fmt.Sprintf("user%d", i),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userIDs[i], err = result.LastInsertId(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Lancer toutes les inscriptions en même temps
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	positions := make([]int, users)
+	errs := make([]error, users)
+	for i, userID := range userIDs {
+		wg.Add(1)
+		go func(i int, userID int64) {
+			defer wg.Done()
+			<-start
+			positions[i], errs[i] = RegisterToActivity(db, userID, activityID)
+		}(i, userID)
+	}
+	close(start)
+	wg.Wait()
+
+	confirmed, waitlisted := 0, 0
+	seen := make(map[int]bool)
+	for i := range userIDs {
+		if errs[i] != nil {
+			t.Errorf("inscription de l'utilisateur %d: %v", userIDs[i], errs[i])
+			continue
+		}
+		if positions[i] == 0 {
+			confirmed++
+			continue
+		}
+		if seen[positions[i]] {
+			t.Errorf("position %d attribuée deux fois sur la liste d'attente", positions[i])
+		}
+		seen[positions[i]] = true
+		waitlisted++
+	}
+
+	if confirmed != capacity {
+		t.Errorf("%d inscriptions confirmées, %d attendues", confirmed, capacity)
+	}
+	if waitlisted != users-capacity {
+		t.Errorf("%d utilisateurs en liste d'attente, %d attendus", waitlisted, users-capacity)
+	}
+
+	// Vérifier l'état enregistré en base
+	var registrations, waitlist int
+	err = db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM registrations WHERE activity_id = ?),
+		       (SELECT COUNT(*) FROM waitlist WHERE activity_id = ?)
+	`, activityID, activityID).Scan(&registrations, &waitlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if registrations != capacity {
+		t.Errorf("%d inscriptions en base, %d attendues", registrations, capacity)
+	}
+	if waitlist != users-capacity {
+		t.Errorf("%d entrées de liste d'attente en base, %d attendues", waitlist, users-capacity)
+	}
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"bdd-website/internal/models"
+)
+
+// Catégories d'activités
+const (
+	CategoryCleanup    = "cleanup"
+	CategoryWorkshop   = "workshop"
+	CategoryConference = "conference"
+	CategoryPlanting   = "planting"
+	CategoryOuting     = "outing"
+	CategoryOther      = "other"
+)
+
+// ActivityCategories liste les catégories d'activités autorisées
+var ActivityCategories = []string{
+	CategoryCleanup,
+	CategoryWorkshop,
+	CategoryConference,
+	CategoryPlanting,
+	CategoryOuting,
+	CategoryOther,
+}
+
+// Limites des tags d'une activité
+const (
+	MaxActivityTags   = 10
+	MaxActivityTagLen = 30
+)
+
+// IsValidActivityCategory vérifie qu'une catégorie fait partie de la liste autorisée
+func IsValidActivityCategory(category string) bool {
+	for _, c := range ActivityCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeActivityTags met les tags en minuscules, supprime les doublons et les tags vides,
+// et vérifie les limites de nombre et de longueur
+func NormalizeActivityTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := make(map[string]bool)
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		if utf8.RuneCountInString(tag) > MaxActivityTagLen {
+			return nil, fmt.Errorf("le tag \"%s\" dépasse %d caractères", tag, MaxActivityTagLen)
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > MaxActivityTags {
+		return nil, fmt.Errorf("une activité ne peut pas avoir plus de %d tags", MaxActivityTags)
+	}
+
+	return normalized, nil
+}
+
+// setActivityTagsTx remplace les tags d'une activité dans une transaction
+func setActivityTagsTx(tx *sql.Tx, activityID int64, tags []string) error {
+	_, err := tx.Exec("DELETE FROM activity_tags WHERE activity_id = ?", activityID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = tx.Exec("INSERT OR IGNORE INTO activity_tags (activity_id, tag) VALUES (?, ?)", activityID, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getActivityTags récupère les tags d'une activité
+func getActivityTags(db *sql.DB, activityID int64) ([]string, error) {
+	rows, err := db.Query("SELECT tag FROM activity_tags WHERE activity_id = ? ORDER BY tag", activityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// loadActivityTags renseigne les tags d'une liste d'activités en une seule requête
+func loadActivityTags(db *sql.DB, activities []models.Activity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+
+	// Indexer les activités par ID
+	index := make(map[int64]int, len(activities))
+	placeholders := make([]string, len(activities))
+	args := make([]interface{}, len(activities))
+	for i := range activities {
+		activities[i].Tags = []string{}
+		index[activities[i].ID] = i
+		placeholders[i] = "?"
+		args[i] = activities[i].ID
+	}
+
+	rows, err := db.Query(
+		"SELECT activity_id, tag FROM activity_tags WHERE activity_id IN ("+strings.Join(placeholders, ", ")+") ORDER BY tag",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var activityID int64
+		var tag string
+		if err := rows.Scan(&activityID, &tag); err != nil {
+			return err
+		}
+		if i, ok := index[activityID]; ok {
+			activities[i].Tags = append(activities[i].Tags, tag)
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,372 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// Statuts de présence possibles
+const (
+	AttendancePresent  = "present"
+	AttendanceAbsent   = "absent"
+	AttendanceUnmarked = "unmarked"
+)
+
+// GetActivityAttendance récupère les inscrits d'une activité avec leur statut de présence
+func GetActivityAttendance(db *sql.DB, activityID int64) ([]models.Attendance, error) {
+	// Vérifier si l'activité existe
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM activities WHERE id = ?)", activityID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, errors.New("activité non trouvée")
+	}
+
+	// Récupérer les inscrits et leur présence éventuelle
+	rows, err := db.Query(`
+		SELECT u.id, u.username, u.email, r.activity_id,
+		       at.status, at.eco_point_id, at.marked_at
+		FROM registrations r
+		JOIN users u ON r.user_id = u.id
+		LEFT JOIN attendances at ON at.user_id = r.user_id AND at.activity_id = r.activity_id
+		WHERE r.activity_id = ?
+		ORDER BY u.username ASC
+	`, activityID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parcourir les résultats
+	attendances := []models.Attendance{}
+	for rows.Next() {
+		var attendance models.Attendance
+		var status sql.NullString
+		var ecoPointID sql.NullInt64
+		var markedAt sql.NullTime
+
+		err := rows.Scan(
+			&attendance.UserID, &attendance.Username, &attendance.Email, &attendance.ActivityID,
+			&status, &ecoPointID, &markedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Convertir les valeurs nullables
+		if status.Valid {
+			attendance.Status = status.String
+		} else {
+			attendance.Status = AttendanceUnmarked
+		}
+
+		attendance.PointsAwarded = ecoPointID.Valid
+
+		if markedAt.Valid {
+			attendance.MarkedAt = markedAt.Time
+		}
+
+		attendances = append(attendances, attendance)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attendances, nil
+}
+
+// MarkAttendance enregistre la présence ou l'absence des inscrits une fois l'activité terminée.
+// Les points de l'activité sont crédités dans la même transaction, au plus une fois par participant.
+// Retourne la liste des utilisateurs nouvellement crédités.
+func MarkAttendance(db *sql.DB, activityID, markedBy int64, updates []models.AttendanceUpdate) ([]int64, error) {
+	// Valider les statuts avant d'ouvrir la transaction
+	for _, update := range updates {
+		if update.Status != AttendancePresent && update.Status != AttendanceAbsent {
+			return nil, fmt.Errorf("statut de présence invalide: %s", update.Status)
+		}
+	}
+
+	// Commencer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// Récupérer l'activité
+	var title string
+	var endDate time.Time
+	var ecoPoints int
+
+	err = tx.QueryRow(
+		"SELECT title, end_date, eco_points FROM activities WHERE id = ?",
+		activityID,
+	).Scan(&title, &endDate, &ecoPoints)
+
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, errors.New("activité non trouvée")
+		}
+		return nil, err
+	}
+
+	// La présence ne peut être saisie qu'après la fin de l'activité
+	if time.Now().Before(endDate) {
+		tx.Rollback()
+		return nil, errors.New("la présence ne peut être saisie qu'après la fin de l'activité")
+	}
+
+	credited := []int64{}
+	for _, update := range updates {
+		// Vérifier que l'utilisateur est bien inscrit
+		var isRegistered bool
+		err = tx.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = ?)",
+			update.UserID, activityID,
+		).Scan(&isRegistered)
+
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if !isRegistered {
+			tx.Rollback()
+			return nil, fmt.Errorf("l'utilisateur %d n'est pas inscrit à cette activité", update.UserID)
+		}
+
+		awarded, err := markAttendanceTx(tx, update.UserID, activityID, markedBy, update.Status, ecoPoints, title)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if awarded {
+			credited = append(credited, update.UserID)
+		}
+	}
+
+	// Commit de la transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return credited, nil
+}
+
+// markAttendanceTx enregistre le statut de présence d'un inscrit et, s'il est présent,
+// crédite les points de l'activité si cela n'a pas déjà été fait. Une présence qui n'est plus
+// confirmée annule les points crédités.
+// Retourne true si des points ont été crédités.
+func markAttendanceTx(tx *sql.Tx, userID, activityID, markedBy int64, status string, ecoPoints int, activityTitle string) (bool, error) {
+	// Statut précédent, pour ne signaler une présence confirmée qu'une fois
+	var previousStatus sql.NullString
+	err := tx.QueryRow(
+		"SELECT status FROM attendances WHERE user_id = ? AND activity_id = ?",
+		userID, activityID,
+	).Scan(&previousStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+
+	// Enregistrer ou mettre à jour la présence
+	_, err = tx.Exec(`
+		INSERT INTO attendances (user_id, activity_id, status, marked_by, marked_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, activity_id) DO UPDATE
+		SET status = excluded.status, marked_by = excluded.marked_by, marked_at = excluded.marked_at
+	`, userID, activityID, status, nullIfZero(markedBy), time.Now())
+
+	if err != nil {
+		return false, err
+	}
+
+	if status != AttendancePresent {
+		// Retirer l'impact d'une présence qui n'est plus confirmée
+		if previousStatus.String == AttendancePresent {
+			_, err = tx.Exec("DELETE FROM impact_entries WHERE user_id = ? AND activity_id = ?", userID, activityID)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		// Annuler les points déjà crédités pour cette présence
+		if err = reverseAttendancePointsTx(tx, userID, activityID, markedBy, activityTitle); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	if previousStatus.String != AttendancePresent {
+		err = recordEventTx(tx, EventAttendanceConfirmed, userID, map[string]int64{"activity_id": activityID})
+		if err != nil {
+			return false, err
+		}
+
+		// Enregistrer l'impact fixe de la participation
+		if err = recordFixedImpactTx(tx, userID, "activity_id", activityID, time.Now()); err != nil {
+			return false, err
+		}
+	}
+
+	if ecoPoints <= 0 {
+		return false, nil
+	}
+
+	// Vérifier si les points ont déjà été crédités
+	var ecoPointID sql.NullInt64
+	err = tx.QueryRow(
+		"SELECT eco_point_id FROM attendances WHERE user_id = ? AND activity_id = ?",
+		userID, activityID,
+	).Scan(&ecoPointID)
+
+	if err != nil {
+		return false, err
+	}
+
+	if ecoPointID.Valid {
+		return false, nil
+	}
+
+	// Créditer les points de l'activité
+	pointID, err := AddEcoPointsTx(tx, userID, activityID, 0, ecoPoints, "Participation à l'activité : "+activityTitle)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE attendances SET eco_point_id = ? WHERE user_id = ? AND activity_id = ?",
+		pointID, userID, activityID,
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// reverseAttendancePointsTx annule par une écriture opposée les points crédités pour une présence
+// qui n'est plus confirmée. Une nouvelle présence pourra ensuite créditer les points à nouveau.
+func reverseAttendancePointsTx(tx *sql.Tx, userID, activityID, markedBy int64, activityTitle string) error {
+	var ecoPointID sql.NullInt64
+	err := tx.QueryRow(
+		"SELECT eco_point_id FROM attendances WHERE user_id = ? AND activity_id = ?",
+		userID, activityID,
+	).Scan(&ecoPointID)
+	if err != nil || !ecoPointID.Valid {
+		return err
+	}
+
+	// L'écriture a pu être annulée entre-temps par un administrateur
+	var points int
+	var reversed bool
+	err = tx.QueryRow(
+		"SELECT points, EXISTS(SELECT 1 FROM eco_points WHERE reverses_id = ep.id) FROM eco_points ep WHERE id = ?",
+		ecoPointID.Int64,
+	).Scan(&points, &reversed)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if err == nil && !reversed {
+		_, err = insertLedgerEntryTx(tx, userID, markedBy, -points, "Absence à l'activité : "+activityTitle, PointsReversal, ecoPointID.Int64)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		"UPDATE attendances SET eco_point_id = NULL WHERE user_id = ? AND activity_id = ?",
+		userID, activityID,
+	)
+	return err
+}
+
+// CheckInToActivity enregistre la présence d'un inscrit qui pointe lui-même pendant l'activité
+func CheckInToActivity(db *sql.DB, userID, activityID int64) error {
+	// Commencer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Récupérer l'activité
+	var title string
+	var startDate, endDate time.Time
+	var ecoPoints int
+
+	err = tx.QueryRow(
+		"SELECT title, start_date, end_date, eco_points FROM activities WHERE id = ?",
+		activityID,
+	).Scan(&title, &startDate, &endDate, &ecoPoints)
+
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errors.New("activité non trouvée")
+		}
+		return err
+	}
+
+	// Le pointage n'est possible que pendant l'activité
+	now := time.Now()
+	if now.Before(startDate) || now.After(endDate) {
+		tx.Rollback()
+		return errors.New("le pointage n'est possible que pendant l'activité")
+	}
+
+	// Vérifier que l'utilisateur est inscrit
+	var isRegistered bool
+	err = tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM registrations WHERE user_id = ? AND activity_id = ?)",
+		userID, activityID,
+	).Scan(&isRegistered)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if !isRegistered {
+		tx.Rollback()
+		return errors.New("vous n'êtes pas inscrit à cette activité")
+	}
+
+	// Vérifier que la présence n'est pas déjà enregistrée
+	var alreadyPresent bool
+	err = tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM attendances WHERE user_id = ? AND activity_id = ? AND status = ?)",
+		userID, activityID, AttendancePresent,
+	).Scan(&alreadyPresent)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if alreadyPresent {
+		tx.Rollback()
+		return errors.New("votre présence est déjà enregistrée")
+	}
+
+	// Enregistrer la présence et créditer les points
+	_, err = markAttendanceTx(tx, userID, activityID, userID, AttendancePresent, ecoPoints, title)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit de la transaction
+	return tx.Commit()
+}
@@ -0,0 +1,485 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// Types de critères d'attribution des badges
+const (
+	BadgeRuleTotalPoints         = "total_points"         // Points écologiques cumulés
+	BadgeRuleChallengesCompleted = "challenges_completed" // Défis complétés
+	BadgeRuleActivitiesAttended  = "activities_attended"  // Activités avec présence confirmée
+	BadgeRulePointsInWindow      = "points_in_window"     // Points gagnés sur les derniers window_days jours
+	BadgeRuleStreak              = "streak"               // Meilleure série de pointages quotidiens
+	BadgeRuleCategoryActivities  = "category_activities"  // Activités d'une catégorie avec présence confirmée
+)
+
+// BadgeRuleTypes liste les types de critères acceptés
+var BadgeRuleTypes = []string{
+	BadgeRuleTotalPoints,
+	BadgeRuleChallengesCompleted,
+	BadgeRuleActivitiesAttended,
+	BadgeRulePointsInWindow,
+	BadgeRuleStreak,
+	BadgeRuleCategoryActivities,
+}
+
+// Catégories de badges
+var BadgeCategories = []string{"participation", "challenge", "special"}
+
+// Limites des critères de badge
+const (
+	MaxBadgeRules         = 5
+	MaxBadgeWindowDays    = 365
+	MaxBadgeRuleThreshold = 1000000
+)
+
+// ValidateBadgeRules vérifie la cohérence des critères d'un badge
+func ValidateBadgeRules(rules []models.BadgeRule) error {
+	if len(rules) == 0 {
+		return errors.New("un badge doit avoir au moins un critère")
+	}
+	if len(rules) > MaxBadgeRules {
+		return fmt.Errorf("un badge ne peut pas avoir plus de %d critères", MaxBadgeRules)
+	}
+
+	for _, rule := range rules {
+		known := false
+		for _, ruleType := range BadgeRuleTypes {
+			if rule.Type == ruleType {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("type de critère inconnu: %q", rule.Type)
+		}
+
+		// Seuls les points cumulés acceptent un seuil nul (badge de bienvenue)
+		minThreshold := 1
+		if rule.Type == BadgeRuleTotalPoints {
+			minThreshold = 0
+		}
+		if rule.Threshold < minThreshold || rule.Threshold > MaxBadgeRuleThreshold {
+			return fmt.Errorf("seuil invalide pour le critère %s", rule.Type)
+		}
+
+		if rule.Type == BadgeRulePointsInWindow {
+			if rule.WindowDays < 1 || rule.WindowDays > MaxBadgeWindowDays {
+				return fmt.Errorf("le critère %s exige une fenêtre entre 1 et %d jours", rule.Type, MaxBadgeWindowDays)
+			}
+		} else if rule.WindowDays != 0 {
+			return fmt.Errorf("le critère %s n'accepte pas de fenêtre", rule.Type)
+		}
+
+		if rule.Type == BadgeRuleCategoryActivities {
+			if !IsValidActivityCategory(rule.Category) {
+				return fmt.Errorf("catégorie d'activité invalide pour le critère %s", rule.Type)
+			}
+		} else if rule.Category != "" {
+			return fmt.Errorf("le critère %s n'accepte pas de catégorie", rule.Type)
+		}
+	}
+
+	return nil
+}
+
+// queryRower est implémenté par *sql.DB et *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// queryer est implémenté par *sql.DB et *sql.Tx
+type queryer interface {
+	queryRower
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// badgeEvaluator calcule les valeurs des critères de badge pour un utilisateur.
+// Les valeurs sont mises en cache: plusieurs badges partageant un critère ne déclenchent qu'une requête.
+type badgeEvaluator struct {
+	q      queryRower
+	userID int64
+	now    time.Time
+	cache  map[models.BadgeRule]int
+}
+
+// newBadgeEvaluator crée un évaluateur de critères pour un utilisateur
+func newBadgeEvaluator(q queryRower, userID int64, now time.Time) *badgeEvaluator {
+	return &badgeEvaluator{q: q, userID: userID, now: now, cache: make(map[models.BadgeRule]int)}
+}
+
+// value calcule la valeur actuelle de l'utilisateur pour un critère
+func (e *badgeEvaluator) value(rule models.BadgeRule) (int, error) {
+	// Le seuil n'influence pas la valeur
+	key := rule
+	key.Threshold = 0
+	if value, ok := e.cache[key]; ok {
+		return value, nil
+	}
+
+	var value int
+	var err error
+
+	switch rule.Type {
+	case BadgeRuleTotalPoints:
+		err = e.q.QueryRow(
+			"SELECT COALESCE(SUM(points), 0) FROM eco_points WHERE user_id = ? AND "+earnedPointsFilter,
+			e.userID,
+		).Scan(&value)
+	case BadgeRuleChallengesCompleted:
+		err = e.q.QueryRow(
+			"SELECT COUNT(*) FROM challenge_attempts WHERE user_id = ? AND status = ?",
+			e.userID, ChallengeCompleted,
+		).Scan(&value)
+	case BadgeRuleActivitiesAttended:
+		err = e.q.QueryRow(
+			"SELECT COUNT(*) FROM attendances WHERE user_id = ? AND status = 'present'",
+			e.userID,
+		).Scan(&value)
+	case BadgeRulePointsInWindow:
+		// Les dates du registre sont en UTC (CURRENT_TIMESTAMP)
+		err = e.q.QueryRow(
+			"SELECT COALESCE(SUM(points), 0) FROM eco_points WHERE user_id = ? AND date >= ? AND "+earnedPointsFilter,
+			e.userID, e.now.AddDate(0, 0, -rule.WindowDays).UTC(),
+		).Scan(&value)
+	case BadgeRuleStreak:
+		err = e.q.QueryRow(
+			"SELECT COALESCE(MAX(best_streak), 0) FROM user_streaks WHERE user_id = ?",
+			e.userID,
+		).Scan(&value)
+	case BadgeRuleCategoryActivities:
+		err = e.q.QueryRow(`
+			SELECT COUNT(*) FROM attendances a
+			JOIN activities act ON a.activity_id = act.id
+			WHERE a.user_id = ? AND a.status = 'present' AND act.category = ?
+		`, e.userID, rule.Category).Scan(&value)
+	default:
+		return 0, fmt.Errorf("type de critère inconnu: %q", rule.Type)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	e.cache[key] = value
+	return value, nil
+}
+
+// progress évalue tous les critères d'un badge et indique s'ils sont tous remplis
+func (e *badgeEvaluator) progress(rules []models.BadgeRule) ([]models.BadgeRuleProgress, bool, error) {
+	progress := make([]models.BadgeRuleProgress, 0, len(rules))
+	allMet := len(rules) > 0
+
+	for _, rule := range rules {
+		value, err := e.value(rule)
+		if err != nil {
+			return nil, false, err
+		}
+
+		met := value >= rule.Threshold
+		if !met {
+			allMet = false
+		}
+
+		progress = append(progress, models.BadgeRuleProgress{BadgeRule: rule, Current: value, Met: met})
+	}
+
+	return progress, allMet, nil
+}
+
+// loadBadgeRules récupère les critères de tous les badges, indexés par badge
+func loadBadgeRules(q queryer) (map[int64][]models.BadgeRule, error) {
+	rows, err := q.Query(
+		"SELECT badge_id, rule_type, threshold, window_days, category FROM badge_rules ORDER BY badge_id, id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make(map[int64][]models.BadgeRule)
+	for rows.Next() {
+		var badgeID int64
+		var rule models.BadgeRule
+		var windowDays sql.NullInt64
+		var category sql.NullString
+
+		if err := rows.Scan(&badgeID, &rule.Type, &rule.Threshold, &windowDays, &category); err != nil {
+			return nil, err
+		}
+
+		rule.WindowDays = int(windowDays.Int64)
+		rule.Category = category.String
+		rules[badgeID] = append(rules[badgeID], rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// GetBadges récupère tous les badges avec leurs critères
+func GetBadges(db *sql.DB) ([]models.Badge, error) {
+	return getBadges(db)
+}
+
+// getBadges récupère tous les badges avec leurs critères, hors ou dans une transaction
+func getBadges(q queryer) ([]models.Badge, error) {
+	rows, err := q.Query("SELECT id, name, description, image_path, category FROM badges ORDER BY category, id")
+	if err != nil {
+		return nil, err
+	}
+
+	badges := []models.Badge{}
+	for rows.Next() {
+		var badge models.Badge
+		if err := rows.Scan(&badge.ID, &badge.Name, &badge.Description, &badge.ImagePath, &badge.Category); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		badges = append(badges, badge)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rules, err := loadBadgeRules(q)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range badges {
+		badges[i].Rules = rules[badges[i].ID]
+		if badges[i].Rules == nil {
+			badges[i].Rules = []models.BadgeRule{}
+		}
+	}
+
+	return badges, nil
+}
+
+// CreateBadge crée un badge et ses critères
+func CreateBadge(db *sql.DB, badge models.BadgeCreate) (int64, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkBadgeNameTx(tx, badge.Name, 0); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO badges (name, description, image_path, category) VALUES (?, ?, ?, ?)",
+		badge.Name, badge.Description, badge.ImagePath, badge.Category,
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	badgeID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := setBadgeRulesTx(tx, badgeID, badge.Rules); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Attribuer le nouveau badge aux utilisateurs qui remplissent déjà ses critères
+	if err := recordEventTx(tx, EventBadgeRulesChanged, 0, map[string]int64{"badge_id": badgeID}); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return badgeID, nil
+}
+
+// UpdateBadge met à jour un badge et remplace ses critères.
+// Les badges déjà obtenus sont conservés même si les nouveaux critères ne sont plus remplis.
+func UpdateBadge(db *sql.DB, badgeID int64, badge models.BadgeCreate) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := checkBadgeNameTx(tx, badge.Name, badgeID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	result, err := tx.Exec(
+		"UPDATE badges SET name = ?, description = ?, image_path = ?, category = ? WHERE id = ?",
+		badge.Name, badge.Description, badge.ImagePath, badge.Category, badgeID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("badge non trouvé")
+	}
+
+	if err := setBadgeRulesTx(tx, badgeID, badge.Rules); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := recordEventTx(tx, EventBadgeRulesChanged, 0, map[string]int64{"badge_id": badgeID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteBadge supprime un badge, ses critères et les attributions associées
+func DeleteBadge(db *sql.DB, badgeID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM badges WHERE id = ?", badgeID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("badge non trouvé")
+	}
+
+	if _, err := tx.Exec("DELETE FROM badge_rules WHERE badge_id = ?", badgeID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_badges WHERE badge_id = ?", badgeID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setBadgeRulesTx remplace les critères d'un badge
+func setBadgeRulesTx(tx *sql.Tx, badgeID int64, rules []models.BadgeRule) error {
+	if _, err := tx.Exec("DELETE FROM badge_rules WHERE badge_id = ?", badgeID); err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		var windowDays, category interface{}
+		if rule.WindowDays != 0 {
+			windowDays = rule.WindowDays
+		}
+		if rule.Category != "" {
+			category = rule.Category
+		}
+
+		_, err := tx.Exec(
+			"INSERT INTO badge_rules (badge_id, rule_type, threshold, window_days, category) VALUES (?, ?, ?, ?, ?)",
+			badgeID, rule.Type, rule.Threshold, windowDays, category,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkBadgeNameTx vérifie qu'aucun autre badge ne porte déjà ce nom
+func checkBadgeNameTx(tx *sql.Tx, name string, badgeID int64) error {
+	var exists bool
+	err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM badges WHERE name = ? AND id != ?)",
+		name, badgeID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.New("un badge porte déjà ce nom")
+	}
+
+	return nil
+}
+
+// awardBadgesToAllUsersTx réévalue les badges de tous les utilisateurs,
+// par exemple après la création d'un badge dont les critères sont déjà remplis par certains
+func awardBadgesToAllUsersTx(tx *sql.Tx, event models.DomainEvent, now time.Time) error {
+	rows, err := tx.Query("SELECT id FROM users")
+	if err != nil {
+		return err
+	}
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err := awardBadgesTx(tx, userID, now); err != nil {
+			return fmt.Errorf("utilisateur %d: %w", userID, err)
+		}
+	}
+
+	return nil
+}
+
+// awardBadgesForEvent vérifie les badges de l'utilisateur concerné par un événement
+func awardBadgesForEvent(tx *sql.Tx, event models.DomainEvent, now time.Time) error {
+	if event.UserID == 0 {
+		return errors.New("événement sans utilisateur")
+	}
+	return awardBadgesTx(tx, event.UserID, now)
+}
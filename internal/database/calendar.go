@@ -0,0 +1,68 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"bdd-website/internal/utils"
+)
+
+// Taille (en octets) des jetons de flux iCalendar
+const calendarTokenSize = 24
+
+// GetCalendarToken récupère le jeton du flux iCalendar personnel d'un utilisateur, en le créant si nécessaire
+func GetCalendarToken(db *sql.DB, userID int64) (string, error) {
+	var token sql.NullString
+	err := db.QueryRow("SELECT calendar_token FROM users WHERE id = ?", userID).Scan(&token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.New("utilisateur non trouvé")
+		}
+		return "", err
+	}
+
+	if token.Valid && token.String != "" {
+		return token.String, nil
+	}
+
+	return RegenerateCalendarToken(db, userID)
+}
+
+// RegenerateCalendarToken remplace le jeton du flux iCalendar personnel d'un utilisateur.
+// L'ancien lien d'abonnement cesse immédiatement de fonctionner.
+func RegenerateCalendarToken(db *sql.DB, userID int64) (string, error) {
+	token, err := utils.GenerateRandomToken(calendarTokenSize)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := db.Exec("UPDATE users SET calendar_token = ? WHERE id = ?", token, userID)
+	if err != nil {
+		return "", err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+
+	if rowsAffected == 0 {
+		return "", errors.New("utilisateur non trouvé")
+	}
+
+	return token, nil
+}
+
+// GetUserIDByCalendarToken récupère l'utilisateur correspondant à un jeton de flux iCalendar
+func GetUserIDByCalendarToken(db *sql.DB, token string) (int64, error) {
+	var userID int64
+	err := db.QueryRow("SELECT id FROM users WHERE calendar_token = ?", token).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("flux d'agenda introuvable")
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
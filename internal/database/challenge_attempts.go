@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// AbandonChallenge permet à un utilisateur d'abandonner un défi en cours.
+// Le défi pourra être rejoint à nouveau, ce qui ouvrira une nouvelle tentative.
+func AbandonChallenge(db *sql.DB, userID, challengeID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Vérifier la tentative en cours
+	var attemptID int64
+	var status string
+	err = tx.QueryRow(`
+		SELECT a.id, a.status
+		FROM challenge_participants cp
+		JOIN challenge_attempts a ON cp.attempt_id = a.id
+		WHERE cp.user_id = ? AND cp.challenge_id = ?
+	`, userID, challengeID).Scan(&attemptID, &status)
+
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errors.New("vous ne participez pas à ce défi")
+		}
+		return err
+	}
+
+	switch status {
+	case ChallengeInProgress:
+	case ChallengePendingReview:
+		tx.Rollback()
+		return errors.New("votre preuve est en cours de validation")
+	case ChallengeCompleted:
+		tx.Rollback()
+		return errors.New("vous avez déjà terminé ce défi")
+	default:
+		tx.Rollback()
+		return errors.New("vous avez déjà abandonné ce défi")
+	}
+
+	// Abandonner la tentative en cours
+	_, err = tx.Exec(
+		"UPDATE challenge_attempts SET status = ?, abandoned_at = ? WHERE id = ?",
+		ChallengeAbandoned, time.Now(), attemptID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetChallengeAttempts récupère l'historique des tentatives sur un défi, tentative en cours incluse.
+// Si userID vaut 0, les tentatives de tous les utilisateurs sont retournées.
+func GetChallengeAttempts(db *sql.DB, challengeID, userID int64) ([]models.ChallengeAttempt, error) {
+	// Vérifier si le défi existe
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM eco_challenges WHERE id = ?)", challengeID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, errors.New("défi non trouvé")
+	}
+
+	rows, err := db.Query(`
+		SELECT a.id, a.user_id, u.username, a.challenge_id, a.attempt, a.status, a.joined_at, a.completed_at, a.abandoned_at
+		FROM challenge_attempts a
+		JOIN users u ON a.user_id = u.id
+		WHERE a.challenge_id = ? AND (? = 0 OR a.user_id = ?)
+		ORDER BY u.username, a.attempt
+	`, challengeID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.ChallengeAttempt{}
+	for rows.Next() {
+		var attempt models.ChallengeAttempt
+		var completedAt, abandonedAt sql.NullTime
+
+		err := rows.Scan(
+			&attempt.ID, &attempt.UserID, &attempt.Username, &attempt.ChallengeID,
+			&attempt.Attempt, &attempt.Status, &attempt.JoinedAt, &completedAt, &abandonedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Une tentative se termine par une validation ou un abandon
+		if completedAt.Valid {
+			attempt.EndedAt = completedAt.Time
+		} else if abandonedAt.Valid {
+			attempt.EndedAt = abandonedAt.Time
+		}
+
+		attempts = append(attempts, attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
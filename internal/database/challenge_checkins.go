@@ -0,0 +1,265 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// checkinDateLayout est le format des jours de pointage (heure locale du serveur)
+const checkinDateLayout = "2006-01-02"
+
+// StreakMilestones liste les paliers de série de pointages mis en avant sur le tableau de bord
+var StreakMilestones = []int{3, 7, 14, 30, 100}
+
+// CheckInChallenge enregistre le pointage du jour d'un utilisateur sur un défi en cours
+// et met à jour sa série de jours consécutifs dans la même transaction
+func CheckInChallenge(db *sql.DB, userID, challengeID int64, note string, now time.Time) (*models.UserStreak, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// Vérifier la tentative en cours
+	var attemptID int64
+	var status string
+	var joinedAt time.Time
+	var durationDays int
+	var endDate sql.NullTime
+	err = tx.QueryRow(`
+		SELECT a.id, a.status, a.joined_at, c.duration_days, c.end_date
+		FROM challenge_participants cp
+		JOIN challenge_attempts a ON cp.attempt_id = a.id
+		JOIN eco_challenges c ON cp.challenge_id = c.id
+		WHERE cp.user_id = ? AND cp.challenge_id = ?
+	`, userID, challengeID).Scan(&attemptID, &status, &joinedAt, &durationDays, &endDate)
+
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, errors.New("vous ne participez pas à ce défi")
+		}
+		return nil, err
+	}
+
+	if status != ChallengeInProgress {
+		tx.Rollback()
+		return nil, errors.New("ce défi n'est pas en cours")
+	}
+
+	if _, deadline := challengeSchedule(joinedAt, durationDays, endDate); now.After(deadline) {
+		tx.Rollback()
+		return nil, errors.New("le délai pour terminer ce défi est dépassé")
+	}
+
+	// Un seul pointage par jour et par tentative
+	today := now.Format(checkinDateLayout)
+	var exists bool
+	err = tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM challenge_checkins WHERE attempt_id = ? AND checkin_date = ?)",
+		attemptID, today,
+	).Scan(&exists)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if exists {
+		tx.Rollback()
+		return nil, errors.New("vous avez déjà pointé aujourd'hui pour ce défi")
+	}
+
+	// Enregistrer le pointage
+	_, err = tx.Exec(
+		"INSERT INTO challenge_checkins (attempt_id, checkin_date, note, created_at) VALUES (?, ?, ?, ?)",
+		attemptID, today, note, now,
+	)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Mettre à jour la série
+	streak, err := updateStreakTx(tx, userID, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Les badges de série seront vérifiés à partir de cet événement
+	err = recordEventTx(tx, EventCheckinRecorded, userID, map[string]interface{}{
+		"challenge_id":   challengeID,
+		"current_streak": streak.CurrentStreak,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return streak, nil
+}
+
+// updateStreakTx prolonge la série de l'utilisateur pour le jour donné.
+// Plusieurs pointages le même jour, sur des défis différents, ne comptent qu'une fois.
+func updateStreakTx(tx *sql.Tx, userID int64, now time.Time) (*models.UserStreak, error) {
+	streak := &models.UserStreak{}
+	var lastCheckinDate sql.NullString
+
+	err := tx.QueryRow(
+		"SELECT current_streak, best_streak, last_checkin_date FROM user_streaks WHERE user_id = ?",
+		userID,
+	).Scan(&streak.CurrentStreak, &streak.BestStreak, &lastCheckinDate)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	today := now.Format(checkinDateLayout)
+	yesterday := now.AddDate(0, 0, -1).Format(checkinDateLayout)
+
+	switch lastCheckinDate.String {
+	case today:
+		// Déjà compté aujourd'hui
+	case yesterday:
+		streak.CurrentStreak++
+	default:
+		streak.CurrentStreak = 1
+	}
+
+	if streak.CurrentStreak > streak.BestStreak {
+		streak.BestStreak = streak.CurrentStreak
+	}
+	streak.LastCheckinDate = today
+
+	_, err = tx.Exec(`
+		INSERT INTO user_streaks (user_id, current_streak, best_streak, last_checkin_date)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			current_streak = excluded.current_streak,
+			best_streak = excluded.best_streak,
+			last_checkin_date = excluded.last_checkin_date
+	`, userID, streak.CurrentStreak, streak.BestStreak, streak.LastCheckinDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return streak, nil
+}
+
+// GetUserStreak récupère la série de pointages d'un utilisateur.
+// La série en cours retombe à zéro si aucun pointage n'a eu lieu hier ni aujourd'hui.
+func GetUserStreak(db *sql.DB, userID int64, now time.Time) (*models.UserStreak, error) {
+	streak := &models.UserStreak{}
+	var lastCheckinDate sql.NullString
+
+	err := db.QueryRow(
+		"SELECT current_streak, best_streak, last_checkin_date FROM user_streaks WHERE user_id = ?",
+		userID,
+	).Scan(&streak.CurrentStreak, &streak.BestStreak, &lastCheckinDate)
+	if err == sql.ErrNoRows {
+		return streak, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	streak.LastCheckinDate = lastCheckinDate.String
+	if streak.LastCheckinDate != now.Format(checkinDateLayout) &&
+		streak.LastCheckinDate != now.AddDate(0, 0, -1).Format(checkinDateLayout) {
+		streak.CurrentStreak = 0
+	}
+
+	return streak, nil
+}
+
+// GetChallengeCheckins récupère les pointages de la tentative en cours d'un utilisateur sur un défi
+func GetChallengeCheckins(db *sql.DB, userID, challengeID int64) ([]models.ChallengeCheckin, error) {
+	var attemptID int64
+	err := db.QueryRow(
+		"SELECT attempt_id FROM challenge_participants WHERE user_id = ? AND challenge_id = ?",
+		userID, challengeID,
+	).Scan(&attemptID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("vous ne participez pas à ce défi")
+		}
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT checkin_date, note, created_at
+		FROM challenge_checkins
+		WHERE attempt_id = ?
+		ORDER BY checkin_date ASC
+	`, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkins := []models.ChallengeCheckin{}
+	for rows.Next() {
+		var checkin models.ChallengeCheckin
+		var note sql.NullString
+
+		if err := rows.Scan(&checkin.Date, &note, &checkin.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		checkin.Note = note.String
+		checkins = append(checkins, checkin)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return checkins, nil
+}
+
+// checkDailyCheckinsTx vérifie qu'une tentative compte un pointage pour chacun des jours
+// de la durée du défi, à partir du jour où il a été rejoint
+func checkDailyCheckinsTx(tx *sql.Tx, attemptID int64, joinedAt time.Time, durationDays int) error {
+	firstDay := joinedAt.Local()
+	lastDay := firstDay.AddDate(0, 0, durationDays-1)
+
+	var count int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM challenge_checkins
+		WHERE attempt_id = ? AND checkin_date BETWEEN ? AND ?
+	`, attemptID, firstDay.Format(checkinDateLayout), lastDay.Format(checkinDateLayout)).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if missing := durationDays - count; missing > 0 {
+		return fmt.Errorf("ce défi exige un pointage chaque jour: il manque %d jour(s) de pointage", missing)
+	}
+
+	return nil
+}
+
+// streakMilestones retourne les paliers atteints par la meilleure série
+// et le prochain palier à atteindre par la série en cours (0 si tous sont atteints)
+func streakMilestones(current, best int) ([]int, int) {
+	reached := []int{}
+	next := 0
+
+	for _, milestone := range StreakMilestones {
+		if best >= milestone {
+			reached = append(reached, milestone)
+		}
+		if next == 0 && current < milestone {
+			next = milestone
+		}
+	}
+
+	return reached, next
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// ChallengeGracePeriod est le délai accordé après la durée minimale d'un défi pour envoyer sa preuve.
+// Passé ce délai, une participation toujours en cours est considérée comme abandonnée.
+// Configurable par la variable d'environnement CHALLENGE_GRACE_PERIOD (voir config.Config).
+var ChallengeGracePeriod = 7 * 24 * time.Hour
+
+// challengeSchedule calcule la date à partir de laquelle un défi peut être terminé
+// et la date limite pour envoyer sa preuve. La date de fin du défi, si elle est définie,
+// raccourcit la date limite sans jamais la placer avant la durée minimale.
+func challengeSchedule(joinedAt time.Time, durationDays int, endDate sql.NullTime) (completableAt, deadline time.Time) {
+	completableAt = joinedAt.AddDate(0, 0, durationDays)
+	deadline = completableAt.Add(ChallengeGracePeriod)
+
+	if endDate.Valid && endDate.Time.Before(deadline) {
+		deadline = endDate.Time
+		if deadline.Before(completableAt) {
+			deadline = completableAt
+		}
+	}
+
+	return completableAt, deadline
+}
+
+// applyChallengeSchedule renseigne l'échéance et les jours restants d'une participation en cours
+func applyChallengeSchedule(challenge *models.Challenge, endDate sql.NullTime, now time.Time) {
+	if challenge.UserStatus != ChallengeInProgress && challenge.UserStatus != ChallengePendingReview {
+		return
+	}
+
+	completableAt, deadline := challengeSchedule(challenge.JoinedAt, challenge.DurationDays, endDate)
+	challenge.CompletableAt = completableAt
+	challenge.Deadline = deadline
+
+	// Jours entamés restants avant l'échéance
+	remaining := 0
+	if deadline.After(now) {
+		remaining = int(math.Ceil(deadline.Sub(now).Hours() / 24))
+	}
+	challenge.RemainingDays = &remaining
+}
+
+// checkChallengeWindow vérifie qu'un défi peut être rejoint à la date donnée:
+// la période du défi doit être ouverte et laisser le temps d'atteindre la durée minimale
+func checkChallengeWindow(startDate, endDate sql.NullTime, durationDays int, now time.Time) error {
+	if startDate.Valid && now.Before(startDate.Time) {
+		return fmt.Errorf("ce défi commence le %s", startDate.Time.Format("02/01/2006"))
+	}
+
+	if endDate.Valid {
+		if !now.Before(endDate.Time) {
+			return errors.New("ce défi est terminé")
+		}
+
+		if now.AddDate(0, 0, durationDays).After(endDate.Time) {
+			return errors.New("il ne reste pas assez de temps pour relever ce défi avant sa date de fin")
+		}
+	}
+
+	return nil
+}
+
+// AbandonOverdueChallenges passe en abandon les participations en cours dont l'échéance est dépassée.
+// Les participations en attente de validation ne sont volontairement pas concernées: la preuve a été
+// envoyée avant l'échéance et c'est à l'administrateur de l'approuver ou de la refuser, quel que soit
+// le temps pris par la validation.
+// Retourne le nombre de participations abandonnées.
+func AbandonOverdueChallenges(db *sql.DB, now time.Time) (int64, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT a.id, a.joined_at, c.duration_days, c.end_date
+		FROM challenge_attempts a
+		JOIN eco_challenges c ON a.challenge_id = c.id
+		WHERE a.status = ?
+	`, ChallengeInProgress)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	var overdueIDs []int64
+	for rows.Next() {
+		var attemptID int64
+		var joinedAt time.Time
+		var durationDays int
+		var endDate sql.NullTime
+
+		if err := rows.Scan(&attemptID, &joinedAt, &durationDays, &endDate); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return 0, err
+		}
+
+		if _, deadline := challengeSchedule(joinedAt, durationDays, endDate); now.After(deadline) {
+			overdueIDs = append(overdueIDs, attemptID)
+		}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Abandonner les participations en retard
+	for _, attemptID := range overdueIDs {
+		_, err = tx.Exec(
+			"UPDATE challenge_attempts SET status = ?, abandoned_at = ? WHERE id = ? AND status = ?",
+			ChallengeAbandoned, now, attemptID, ChallengeInProgress,
+		)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int64(len(overdueIDs)), nil
+}
+
+// StartChallengeSweeper abandonne périodiquement les participations en retard.
+// Un premier passage est effectué immédiatement; la fonction bloque et doit être lancée dans une goroutine.
+func StartChallengeSweeper(db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := AbandonOverdueChallenges(db, time.Now())
+		if err != nil {
+			log.Printf("Erreur lors de l'abandon des défis en retard: %v", err)
+		} else if count > 0 {
+			log.Printf("%d participation(s) à des défis abandonnée(s) pour dépassement de l'échéance", count)
+		}
+
+		<-ticker.C
+	}
+}
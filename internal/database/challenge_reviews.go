@@ -0,0 +1,443 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// Statuts possibles d'une participation à un défi
+const (
+	ChallengeInProgress    = "in_progress"
+	ChallengePendingReview = "pending_review"
+	ChallengeCompleted     = "completed"
+	ChallengeAbandoned     = "abandoned"
+)
+
+// Statuts possibles d'une preuve de défi
+const (
+	SubmissionPending  = "pending"
+	SubmissionApproved = "approved"
+	SubmissionRejected = "rejected"
+)
+
+// SubmitChallengeProof enregistre la preuve d'un utilisateur pour un défi en cours.
+// La participation passe en attente de validation: les points et l'impact ne sont crédités qu'après approbation.
+func SubmitChallengeProof(db *sql.DB, userID, challengeID int64, note string, photos []models.ChallengeProofPhoto, impact map[string]float64) (int64, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	// Vérifier la tentative en cours
+	var attemptID int64
+	var status string
+	var joinedAt time.Time
+	var durationDays int
+	var endDate sql.NullTime
+	var requiresDailyCheckin bool
+	err = tx.QueryRow(`
+		SELECT a.id, a.status, a.joined_at, c.duration_days, c.end_date, c.requires_daily_checkin
+		FROM challenge_participants cp
+		JOIN challenge_attempts a ON cp.attempt_id = a.id
+		JOIN eco_challenges c ON cp.challenge_id = c.id
+		WHERE cp.user_id = ? AND cp.challenge_id = ?
+	`, userID, challengeID).Scan(&attemptID, &status, &joinedAt, &durationDays, &endDate, &requiresDailyCheckin)
+
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return 0, errors.New("vous ne participez pas à ce défi")
+		}
+		return 0, err
+	}
+
+	switch status {
+	case ChallengeInProgress:
+	case ChallengePendingReview:
+		tx.Rollback()
+		return 0, errors.New("votre preuve est déjà en cours de validation")
+	case ChallengeCompleted:
+		tx.Rollback()
+		return 0, errors.New("vous avez déjà terminé ce défi")
+	default:
+		tx.Rollback()
+		return 0, errors.New("vous ne pouvez pas terminer ce défi")
+	}
+
+	// Vérifier que la durée minimale est atteinte et que l'échéance n'est pas dépassée
+	now := time.Now()
+	completableAt, deadline := challengeSchedule(joinedAt, durationDays, endDate)
+	if now.Before(completableAt) {
+		tx.Rollback()
+		return 0, fmt.Errorf("vous pourrez terminer ce défi à partir du %s", completableAt.Format("02/01/2006 à 15h04"))
+	}
+	if now.After(deadline) {
+		tx.Rollback()
+		return 0, errors.New("le délai pour terminer ce défi est dépassé")
+	}
+
+	// Vérifier les pointages quotidiens si le défi les exige
+	if requiresDailyCheckin {
+		if err := checkDailyCheckinsTx(tx, attemptID, joinedAt, durationDays); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	// Vérifier les impacts déclarés
+	if len(impact) > 0 {
+		factors, err := getImpactFactors(tx, "challenge_id", challengeID)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		if err = validateImpactReport(factors, impact); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	// Enregistrer la preuve
+	result, err := tx.Exec(
+		"INSERT INTO challenge_submissions (attempt_id, note, status, submitted_at) VALUES (?, ?, ?, ?)",
+		attemptID, note, SubmissionPending, now,
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	submissionID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Enregistrer les photos
+	for _, photo := range photos {
+		_, err = tx.Exec(
+			"INSERT INTO challenge_proof_photos (submission_id, file_name, content_type) VALUES (?, ?, ?)",
+			submissionID, photo.FileName, photo.ContentType,
+		)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	// Enregistrer les impacts déclarés
+	for metric, amount := range impact {
+		_, err = tx.Exec(
+			"INSERT INTO challenge_submission_impacts (submission_id, metric, amount) VALUES (?, ?, ?)",
+			submissionID, metric, amount,
+		)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	// Passer la tentative en attente de validation
+	_, err = tx.Exec(
+		"UPDATE challenge_attempts SET status = ? WHERE id = ?",
+		ChallengePendingReview, attemptID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return submissionID, nil
+}
+
+// GetChallengeSubmissions récupère les preuves d'un statut donné, les plus anciennes en premier
+func GetChallengeSubmissions(db *sql.DB, status string) ([]models.ChallengeSubmission, error) {
+	rows, err := db.Query(`
+		SELECT s.id, a.user_id, u.username, a.challenge_id, c.title, c.points,
+		       s.note, s.status, s.reason, s.submitted_at, s.reviewed_by, s.reviewed_at
+		FROM challenge_submissions s
+		JOIN challenge_attempts a ON s.attempt_id = a.id
+		JOIN users u ON a.user_id = u.id
+		JOIN eco_challenges c ON a.challenge_id = c.id
+		WHERE s.status = ?
+		ORDER BY s.submitted_at ASC
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	submissions := []models.ChallengeSubmission{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var submission models.ChallengeSubmission
+		var reason sql.NullString
+		var reviewedBy sql.NullInt64
+		var reviewedAt sql.NullTime
+
+		err := rows.Scan(
+			&submission.ID, &submission.UserID, &submission.Username,
+			&submission.ChallengeID, &submission.ChallengeTitle, &submission.Points,
+			&submission.Note, &submission.Status, &reason, &submission.SubmittedAt,
+			&reviewedBy, &reviewedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		submission.Reason = reason.String
+		submission.ReviewedBy = reviewedBy.Int64
+		if reviewedAt.Valid {
+			submission.ReviewedAt = reviewedAt.Time
+		}
+		submission.Photos = []models.ChallengeProofPhoto{}
+
+		index[submission.ID] = len(submissions)
+		submissions = append(submissions, submission)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Récupérer les photos des preuves
+	photoRows, err := db.Query(`
+		SELECT p.id, p.submission_id, p.content_type
+		FROM challenge_proof_photos p
+		JOIN challenge_submissions s ON p.submission_id = s.id
+		WHERE s.status = ?
+		ORDER BY p.id ASC
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer photoRows.Close()
+
+	for photoRows.Next() {
+		var photo models.ChallengeProofPhoto
+		var submissionID int64
+		if err := photoRows.Scan(&photo.ID, &submissionID, &photo.ContentType); err != nil {
+			return nil, err
+		}
+
+		photo.URL = fmt.Sprintf("/api/admin/challenge-submissions/photos/%d", photo.ID)
+		if i, ok := index[submissionID]; ok {
+			submissions[i].Photos = append(submissions[i].Photos, photo)
+		}
+	}
+
+	if err = photoRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Récupérer les impacts déclarés
+	impactRows, err := db.Query(`
+		SELECT i.submission_id, i.metric, i.amount
+		FROM challenge_submission_impacts i
+		JOIN challenge_submissions s ON i.submission_id = s.id
+		WHERE s.status = ?
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer impactRows.Close()
+
+	for impactRows.Next() {
+		var submissionID int64
+		var metric string
+		var amount float64
+		if err := impactRows.Scan(&submissionID, &metric, &amount); err != nil {
+			return nil, err
+		}
+
+		if i, ok := index[submissionID]; ok {
+			if submissions[i].Impact == nil {
+				submissions[i].Impact = make(map[string]float64)
+			}
+			submissions[i].Impact[metric] = amount
+		}
+	}
+
+	if err = impactRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return submissions, nil
+}
+
+// GetChallengeProofPhoto récupère une photo de preuve
+func GetChallengeProofPhoto(db *sql.DB, photoID int64) (*models.ChallengeProofPhoto, error) {
+	photo := &models.ChallengeProofPhoto{}
+	err := db.QueryRow(
+		"SELECT id, file_name, content_type FROM challenge_proof_photos WHERE id = ?",
+		photoID,
+	).Scan(&photo.ID, &photo.FileName, &photo.ContentType)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("photo non trouvée")
+		}
+		return nil, err
+	}
+
+	return photo, nil
+}
+
+// ApproveChallengeSubmission approuve une preuve: la participation est terminée,
+// les points du défi et son impact (fixe et déclaré) sont crédités dans la même transaction
+func ApproveChallengeSubmission(db *sql.DB, submissionID, adminID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Récupérer la preuve en attente
+	submission, err := pendingSubmissionTx(tx, submissionID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	now := time.Now()
+
+	// Terminer la tentative
+	_, err = tx.Exec(
+		"UPDATE challenge_attempts SET status = ?, completed_at = ? WHERE id = ?",
+		ChallengeCompleted, now, submission.attemptID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Créditer les points (un défi peut ne rapporter aucun point)
+	var pointID int64
+	if submission.points > 0 {
+		pointID, err = addEcoPointsTx(tx, submission.userID, 0, submission.challengeID, submission.attemptID, submission.points, "Défi complété")
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Marquer la preuve comme approuvée
+	_, err = tx.Exec(
+		"UPDATE challenge_submissions SET status = ?, reviewed_by = ?, reviewed_at = ?, eco_point_id = ? WHERE id = ?",
+		SubmissionApproved, adminID, now, nullIfZero(pointID), submissionID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Enregistrer l'impact fixe du défi et l'impact déclaré avec la preuve
+	if err = recordFixedImpactTx(tx, submission.userID, "challenge_id", submission.challengeID, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO impact_entries (user_id, challenge_id, metric, mode, amount, created_at)
+		SELECT ?, ?, metric, ?, amount, ? FROM challenge_submission_impacts WHERE submission_id = ?
+	`, submission.userID, submission.challengeID, ImpactReported, now.UTC(), submissionID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = recordEventTx(tx, EventChallengeCompleted, submission.userID, map[string]int64{
+		"challenge_id":  submission.challengeID,
+		"submission_id": submissionID,
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RejectChallengeSubmission refuse une preuve avec un motif: la participation repasse en cours
+// et l'utilisateur peut soumettre une nouvelle preuve
+func RejectChallengeSubmission(db *sql.DB, submissionID, adminID int64, reason string) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Récupérer la preuve en attente
+	submission, err := pendingSubmissionTx(tx, submissionID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Remettre la tentative en cours
+	_, err = tx.Exec(
+		"UPDATE challenge_attempts SET status = ? WHERE id = ?",
+		ChallengeInProgress, submission.attemptID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Marquer la preuve comme refusée
+	_, err = tx.Exec(
+		"UPDATE challenge_submissions SET status = ?, reason = ?, reviewed_by = ?, reviewed_at = ? WHERE id = ?",
+		SubmissionRejected, reason, adminID, time.Now(), submissionID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// pendingSubmission représente la tentative liée à une preuve en attente de validation
+type pendingSubmission struct {
+	attemptID   int64
+	userID      int64
+	challengeID int64
+	points      int
+}
+
+// pendingSubmissionTx récupère la tentative liée à une preuve encore en attente de validation
+func pendingSubmissionTx(tx *sql.Tx, submissionID int64) (*pendingSubmission, error) {
+	submission := &pendingSubmission{}
+	var status string
+
+	err := tx.QueryRow(`
+		SELECT s.status, a.id, a.user_id, a.challenge_id, c.points
+		FROM challenge_submissions s
+		JOIN challenge_attempts a ON s.attempt_id = a.id
+		JOIN eco_challenges c ON a.challenge_id = c.id
+		WHERE s.id = ?
+	`, submissionID).Scan(&status, &submission.attemptID, &submission.userID, &submission.challengeID, &submission.points)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("preuve non trouvée")
+		}
+		return nil, err
+	}
+
+	if status != SubmissionPending {
+		return nil, errors.New("cette preuve a déjà été examinée")
+	}
+
+	return submission, nil
+}
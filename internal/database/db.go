@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 
@@ -25,8 +26,11 @@ func InitDB(dbPath string) (*sql.DB, error) {
 		}
 	}
 
-	// Ouvrir la connexion à la base de données
-	db, err := sql.Open("sqlite3", dbPath)
+	// Ouvrir la connexion à la base de données.
+	// Les transactions démarrent en mode IMMEDIATE: le verrou d'écriture est pris dès le BEGIN,
+	// ce qui sérialise les vérifications de capacité et les écritures concurrentes.
+	// Les connexions en attente du verrou patientent jusqu'à 5 secondes au lieu d'échouer.
+	db, err := sql.Open("sqlite3", dbPath+"?_txlock=immediate&_busy_timeout=5000")
 	if err != nil {
 		return nil, fmt.Errorf("impossible d'ouvrir la base de données: %v", err)
 	}
@@ -43,30 +47,62 @@ func InitDB(dbPath string) (*sql.DB, error) {
 		}
 	}
 
+	// Mettre à jour le schéma d'une base créée par une version précédente
+	if err := upgradeSchema(db); err != nil {
+		return nil, fmt.Errorf("erreur lors de la mise à jour du schéma: %v", err)
+	}
+
+	// Créer et reconstruire les index de recherche plein texte.
+	// Sans FTS5, la recherche est désactivée (voir SearchAvailable): le serveur refuse alors de démarrer.
+	if err := initSearchIndex(db); err != nil {
+		if err != ErrSearchUnavailable {
+			return nil, err
+		}
+		log.Printf("Attention: %v", err)
+	}
+
 	return db, nil
 }
 
-// runMigrations exécute le script SQL de migration initial
-func runMigrations(db *sql.DB) error {
-	migrationFile := "./migrations/init.sql"
+// MigrationsDir est le répertoire des fichiers de migration (relatif au répertoire de travail)
+var MigrationsDir = "./migrations"
+
+// Fichiers de migration: le schéma est idempotent, les données initiales ne sont insérées
+// qu'à la création de la base
+const (
+	schemaFile = "init.sql"
+	seedFile   = "seed.sql"
+)
 
-	// Lire le contenu du fichier de migration
-	migrationSQL, err := os.ReadFile(migrationFile)
+// runMigrations crée le schéma d'une nouvelle base de données et insère les données initiales
+func runMigrations(db *sql.DB) error {
+	// Lire le contenu des fichiers de migration
+	schemaSQL, err := os.ReadFile(filepath.Join(MigrationsDir, schemaFile))
 	if err != nil {
 		return fmt.Errorf("impossible de lire le fichier de migration: %v", err)
 	}
 
+	seedSQL, err := os.ReadFile(filepath.Join(MigrationsDir, seedFile))
+	if err != nil {
+		return fmt.Errorf("impossible de lire le fichier des données initiales: %v", err)
+	}
+
 	// Exécuter les migrations dans une transaction
 	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("impossible de démarrer une transaction: %v", err)
 	}
 
-	if _, err := tx.Exec(string(migrationSQL)); err != nil {
+	if _, err := tx.Exec(string(schemaSQL)); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("erreur lors de l'exécution des migrations: %v", err)
 	}
 
+	if _, err := tx.Exec(string(seedSQL)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("erreur lors de l'insertion des données initiales: %v", err)
+	}
+
 	// Commit de la transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("impossible de committer la transaction: %v", err)
@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"sort"
 	"time"
 
 	"bdd-website/internal/models"
@@ -13,13 +14,15 @@ func GetUserEcoPoints(db *sql.DB, userID int64) ([]models.EcoPoint, int, error)
 	// Récupérer les points
 	rows, err := db.Query(`
 		SELECT ep.id, ep.user_id, ep.activity_id, ep.challenge_id, 
-		       ep.points, ep.description, ep.date,
-		       a.title as activity_title, c.title as challenge_title
+		       ep.points, ep.description, ep.kind, ep.created_by, u.username, ep.reverses_id, rev.id,
+		       ep.date, a.title as activity_title, c.title as challenge_title
 		FROM eco_points ep
 		LEFT JOIN activities a ON ep.activity_id = a.id
 		LEFT JOIN eco_challenges c ON ep.challenge_id = c.id
+		LEFT JOIN users u ON ep.created_by = u.id
+		LEFT JOIN eco_points rev ON rev.reverses_id = ep.id
 		WHERE ep.user_id = ?
-		ORDER BY ep.date DESC
+		ORDER BY ep.date DESC, ep.id DESC
 	`, userID)
 
 	if err != nil {
@@ -32,13 +35,13 @@ func GetUserEcoPoints(db *sql.DB, userID int64) ([]models.EcoPoint, int, error)
 	for rows.Next() {
 		var point models.EcoPoint
 		var date time.Time
-		var activityID, challengeID sql.NullInt64
-		var activityTitle, challengeTitle sql.NullString
+		var activityID, challengeID, createdBy, reversesID, reversedByID sql.NullInt64
+		var activityTitle, challengeTitle, createdByName sql.NullString
 
 		err := rows.Scan(
 			&point.ID, &point.UserID, &activityID, &challengeID,
-			&point.Points, &point.Description, &date,
-			&activityTitle, &challengeTitle,
+			&point.Points, &point.Description, &point.Kind, &createdBy, &createdByName, &reversesID, &reversedByID,
+			&date, &activityTitle, &challengeTitle,
 		)
 
 		if err != nil {
@@ -62,6 +65,11 @@ func GetUserEcoPoints(db *sql.DB, userID int64) ([]models.EcoPoint, int, error)
 			point.ChallengeTitle = challengeTitle.String
 		}
 
+		point.CreatedBy = createdBy.Int64
+		point.CreatedByName = createdByName.String
+		point.ReversesID = reversesID.Int64
+		point.ReversedByID = reversedByID.Int64
+
 		point.Date = date
 		points = append(points, point)
 	}
@@ -82,15 +90,43 @@ func GetUserEcoPoints(db *sql.DB, userID int64) ([]models.EcoPoint, int, error)
 
 // AddEcoPoints ajoute des points écologiques à un utilisateur
 func AddEcoPoints(db *sql.DB, userID int64, activityID, challengeID int64, points int, description string) (int64, error) {
+	// Commencer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	pointID, err := AddEcoPointsTx(tx, userID, activityID, challengeID, points, description)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Commit de la transaction
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return pointID, nil
+}
+
+// AddEcoPointsTx ajoute des points écologiques dans une transaction existante
+// et enregistre l'événement qui déclenchera la vérification des badges.
+func AddEcoPointsTx(tx *sql.Tx, userID int64, activityID, challengeID int64, points int, description string) (int64, error) {
+	return addEcoPointsTx(tx, userID, activityID, challengeID, 0, points, description)
+}
+
+// addEcoPointsTx ajoute des points écologiques, en les rattachant le cas échéant à la tentative de défi récompensée
+func addEcoPointsTx(tx *sql.Tx, userID int64, activityID, challengeID, attemptID int64, points int, description string) (int64, error) {
 	// Vérifier que les points sont positifs
 	if points <= 0 {
 		return 0, errors.New("les points doivent être positifs")
 	}
 
 	// Insérer les points
-	result, err := db.Exec(
-		"INSERT INTO eco_points (user_id, activity_id, challenge_id, points, description) VALUES (?, ?, ?, ?, ?)",
-		userID, nullIfZero(activityID), nullIfZero(challengeID), points, description,
+	result, err := tx.Exec(
+		"INSERT INTO eco_points (user_id, activity_id, challenge_id, attempt_id, points, description) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, nullIfZero(activityID), nullIfZero(challengeID), nullIfZero(attemptID), points, description,
 	)
 
 	if err != nil {
@@ -103,8 +139,15 @@ func AddEcoPoints(db *sql.DB, userID int64, activityID, challengeID int64, point
 		return 0, err
 	}
 
-	// Vérifier et attribuer les badges basés sur les points totaux
-	go checkAndAwardBadges(db, userID)
+	err = recordEventTx(tx, EventPointsCredited, userID, map[string]interface{}{
+		"point_id":     pointID,
+		"points":       points,
+		"activity_id":  activityID,
+		"challenge_id": challengeID,
+	})
+	if err != nil {
+		return 0, err
+	}
 
 	return pointID, nil
 }
@@ -122,10 +165,17 @@ func GetChallenges(db *sql.DB, userID int64, activeOnly bool) ([]models.Challeng
 	// Construire la requête
 	query := `
 		SELECT c.id, c.title, c.description, c.points, c.duration_days, 
-		       c.start_date, c.end_date, c.is_active, c.created_at,
-		       cp.status, cp.joined_at, cp.completed_at
+		       c.start_date, c.end_date, c.is_active, c.requires_daily_checkin, c.is_team_challenge, c.created_at,
+		       a.status, a.joined_at, a.completed_at, a.attempt, a.team_id,
+		       (SELECT COUNT(*) FROM challenge_checkins ck WHERE ck.attempt_id = a.id) as checkin_count,
+		       EXISTS(SELECT 1 FROM challenge_checkins ck
+		        WHERE ck.attempt_id = a.id AND ck.checkin_date = ?) as checked_in_today,
+		       (SELECT s.reason FROM challenge_submissions s
+		        WHERE s.attempt_id = a.id AND s.status = 'rejected'
+		        ORDER BY s.id DESC LIMIT 1) as review_reason
 		FROM eco_challenges c
 		LEFT JOIN challenge_participants cp ON c.id = cp.challenge_id AND cp.user_id = ?
+		LEFT JOIN challenge_attempts a ON cp.attempt_id = a.id
 	`
 
 	// Ajouter les filtres
@@ -141,7 +191,8 @@ func GetChallenges(db *sql.DB, userID int64, activeOnly bool) ([]models.Challeng
 	query = query + whereClause + orderClause
 
 	// Exécuter la requête
-	rows, err := db.Query(query, userID)
+	now := time.Now()
+	rows, err := db.Query(query, now.Format(checkinDateLayout), userID)
 	if err != nil {
 		return nil, err
 	}
@@ -154,11 +205,15 @@ func GetChallenges(db *sql.DB, userID int64, activeOnly bool) ([]models.Challeng
 		var startDate, endDate, createdAt sql.NullTime
 		var status sql.NullString
 		var joinedAt, completedAt sql.NullTime
+		var attempts sql.NullInt64
+		var teamID sql.NullInt64
+		var checkinCount int
+		var reviewReason sql.NullString
 
 		err := rows.Scan(
 			&challenge.ID, &challenge.Title, &challenge.Description, &challenge.Points,
-			&challenge.DurationDays, &startDate, &endDate, &challenge.IsActive, &createdAt,
-			&status, &joinedAt, &completedAt,
+			&challenge.DurationDays, &startDate, &endDate, &challenge.IsActive, &challenge.RequiresDailyCheckin, &challenge.IsTeamChallenge, &createdAt,
+			&status, &joinedAt, &completedAt, &attempts, &teamID, &checkinCount, &challenge.CheckedInToday, &reviewReason,
 		)
 
 		if err != nil {
@@ -192,6 +247,17 @@ func GetChallenges(db *sql.DB, userID int64, activeOnly bool) ([]models.Challeng
 			challenge.CompletedAt = completedAt.Time
 		}
 
+		challenge.Attempts = int(attempts.Int64)
+		challenge.TeamID = teamID.Int64
+		challenge.CheckinCount = checkinCount
+
+		// Motif du refus de la dernière preuve, tant qu'une nouvelle n'a pas été soumise
+		if challenge.UserStatus == ChallengeInProgress {
+			challenge.ReviewReason = reviewReason.String
+		}
+
+		applyChallengeSchedule(&challenge, endDate, now)
+
 		challenges = append(challenges, challenge)
 	}
 
@@ -199,20 +265,33 @@ func GetChallenges(db *sql.DB, userID int64, activeOnly bool) ([]models.Challeng
 		return nil, err
 	}
 
+	// Récupérer les facteurs d'impact
+	if err = loadChallengeImpactFactors(db, challenges); err != nil {
+		return nil, err
+	}
+
 	return challenges, nil
 }
 
 // GetUserChallenges récupère les défis auxquels un utilisateur participe
 func GetUserChallenges(db *sql.DB, userID int64) ([]models.Challenge, error) {
 	// Exécuter la requête
+	now := time.Now()
 	rows, err := db.Query(`
 		SELECT c.id, c.title, c.description, c.points, c.duration_days, 
-		       c.start_date, c.end_date, c.is_active, c.created_at,
-		       cp.status, cp.joined_at, cp.completed_at
+		       c.start_date, c.end_date, c.is_active, c.requires_daily_checkin, c.is_team_challenge, c.created_at,
+		       a.status, a.joined_at, a.completed_at, a.attempt, a.team_id,
+		       (SELECT COUNT(*) FROM challenge_checkins ck WHERE ck.attempt_id = a.id) as checkin_count,
+		       EXISTS(SELECT 1 FROM challenge_checkins ck
+		        WHERE ck.attempt_id = a.id AND ck.checkin_date = ?) as checked_in_today,
+		       (SELECT s.reason FROM challenge_submissions s
+		        WHERE s.attempt_id = a.id AND s.status = 'rejected'
+		        ORDER BY s.id DESC LIMIT 1) as review_reason
 		FROM eco_challenges c
 		JOIN challenge_participants cp ON c.id = cp.challenge_id AND cp.user_id = ?
-		ORDER BY cp.joined_at DESC
-	`, userID)
+		JOIN challenge_attempts a ON cp.attempt_id = a.id
+		ORDER BY a.joined_at DESC
+	`, now.Format(checkinDateLayout), userID)
 
 	if err != nil {
 		return nil, err
@@ -227,11 +306,15 @@ func GetUserChallenges(db *sql.DB, userID int64) ([]models.Challenge, error) {
 		var status string
 		var joinedAt time.Time
 		var completedAt sql.NullTime
+		var attempts sql.NullInt64
+		var teamID sql.NullInt64
+		var checkinCount int
+		var reviewReason sql.NullString
 
 		err := rows.Scan(
 			&challenge.ID, &challenge.Title, &challenge.Description, &challenge.Points,
-			&challenge.DurationDays, &startDate, &endDate, &challenge.IsActive, &createdAt,
-			&status, &joinedAt, &completedAt,
+			&challenge.DurationDays, &startDate, &endDate, &challenge.IsActive, &challenge.RequiresDailyCheckin, &challenge.IsTeamChallenge, &createdAt,
+			&status, &joinedAt, &completedAt, &attempts, &teamID, &checkinCount, &challenge.CheckedInToday, &reviewReason,
 		)
 
 		if err != nil {
@@ -258,6 +341,17 @@ func GetUserChallenges(db *sql.DB, userID int64) ([]models.Challenge, error) {
 			challenge.CompletedAt = completedAt.Time
 		}
 
+		challenge.Attempts = int(attempts.Int64)
+		challenge.TeamID = teamID.Int64
+		challenge.CheckinCount = checkinCount
+
+		// Motif du refus de la dernière preuve, tant qu'une nouvelle n'a pas été soumise
+		if challenge.UserStatus == ChallengeInProgress {
+			challenge.ReviewReason = reviewReason.String
+		}
+
+		applyChallengeSchedule(&challenge, endDate, now)
+
 		challenges = append(challenges, challenge)
 	}
 
@@ -265,6 +359,11 @@ func GetUserChallenges(db *sql.DB, userID int64) ([]models.Challenge, error) {
 		return nil, err
 	}
 
+	// Récupérer les facteurs d'impact
+	if err = loadChallengeImpactFactors(db, challenges); err != nil {
+		return nil, err
+	}
+
 	return challenges, nil
 }
 
@@ -285,21 +384,45 @@ func CreateChallenge(db *sql.DB, challenge models.ChallengeCreate) (int64, error
 		endDateArg = nil
 	}
 
+	// Créer le défi et ses facteurs d'impact dans une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
 	// Insérer le défi
-	result, err := db.Exec(
+	result, err := tx.Exec(
 		`INSERT INTO eco_challenges 
-		(title, description, points, duration_days, start_date, end_date, is_active) 
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		(title, description, points, duration_days, start_date, end_date, is_active, requires_daily_checkin, is_team_challenge) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		challenge.Title, challenge.Description, challenge.Points,
-		challenge.DurationDays, startDateArg, endDateArg, challenge.IsActive,
+		challenge.DurationDays, startDateArg, endDateArg, challenge.IsActive, challenge.RequiresDailyCheckin,
+		challenge.IsTeamChallenge,
 	)
 
 	if err != nil {
+		tx.Rollback()
 		return 0, err
 	}
 
 	// Récupérer l'ID généré
-	return result.LastInsertId()
+	challengeID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Enregistrer les facteurs d'impact
+	if err = setImpactFactorsTx(tx, "challenge_id", challengeID, challenge.Impact); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return challengeID, nil
 }
 
 // UpdateChallenge met à jour un défi écologique
@@ -330,18 +453,38 @@ func UpdateChallenge(db *sql.DB, challengeID int64, challenge models.ChallengeUp
 		endDateArg = nil
 	}
 
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
 	// Mettre à jour le défi
-	_, err = db.Exec(
+	_, err = tx.Exec(
 		`UPDATE eco_challenges 
 		SET title = ?, description = ?, points = ?, 
-		    duration_days = ?, start_date = ?, end_date = ?, is_active = ?
+		    duration_days = ?, start_date = ?, end_date = ?, is_active = ?, requires_daily_checkin = ?, is_team_challenge = ?
 		WHERE id = ?`,
 		challenge.Title, challenge.Description, challenge.Points,
-		challenge.DurationDays, startDateArg, endDateArg, challenge.IsActive,
+		challenge.DurationDays, startDateArg, endDateArg, challenge.IsActive, challenge.RequiresDailyCheckin,
+		challenge.IsTeamChallenge,
 		challengeID,
 	)
 
-	return err
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Remplacer les facteurs d'impact s'ils sont fournis
+	if challenge.Impact != nil {
+		if err = setImpactFactorsTx(tx, "challenge_id", challengeID, *challenge.Impact); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 // DeleteChallenge supprime un défi écologique
@@ -363,15 +506,75 @@ func DeleteChallenge(db *sql.DB, challengeID int64) error {
 		return err
 	}
 
-	// Supprimer les participations liées
+	// Supprimer les preuves, leurs photos et impacts déclarés, les pointages et les tentatives
+	_, err = tx.Exec(`
+		DELETE FROM challenge_proof_photos WHERE submission_id IN (
+			SELECT s.id FROM challenge_submissions s
+			JOIN challenge_attempts a ON s.attempt_id = a.id
+			WHERE a.challenge_id = ?
+		)`, challengeID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM challenge_submission_impacts WHERE submission_id IN (
+			SELECT s.id FROM challenge_submissions s
+			JOIN challenge_attempts a ON s.attempt_id = a.id
+			WHERE a.challenge_id = ?
+		)`, challengeID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM challenge_submissions WHERE attempt_id IN (SELECT id FROM challenge_attempts WHERE challenge_id = ?)",
+		challengeID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM challenge_checkins WHERE attempt_id IN (SELECT id FROM challenge_attempts WHERE challenge_id = ?)",
+		challengeID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Supprimer les participations liées et leurs tentatives
 	_, err = tx.Exec("DELETE FROM challenge_participants WHERE challenge_id = ?", challengeID)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	_, err = tx.Exec("DELETE FROM challenge_attempts WHERE challenge_id = ?", challengeID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Mettre à null les références dans eco_points
-	_, err = tx.Exec("UPDATE eco_points SET challenge_id = NULL WHERE challenge_id = ?", challengeID)
+	_, err = tx.Exec("UPDATE eco_points SET challenge_id = NULL, attempt_id = NULL WHERE challenge_id = ?", challengeID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Supprimer les facteurs d'impact et conserver les impacts déjà enregistrés
+	_, err = tx.Exec("DELETE FROM impact_factors WHERE challenge_id = ?", challengeID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE impact_entries SET challenge_id = NULL WHERE challenge_id = ?", challengeID)
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -391,7 +594,13 @@ func DeleteChallenge(db *sql.DB, challengeID int64) error {
 func JoinChallenge(db *sql.DB, userID, challengeID int64) error {
 	// Vérifier si le défi existe et est actif
 	var isActive bool
-	err := db.QueryRow("SELECT is_active FROM eco_challenges WHERE id = ?", challengeID).Scan(&isActive)
+	var durationDays int
+	var startDate, endDate sql.NullTime
+	var isTeamChallenge bool
+	err := db.QueryRow(
+		"SELECT is_active, duration_days, start_date, end_date, is_team_challenge FROM eco_challenges WHERE id = ?",
+		challengeID,
+	).Scan(&isActive, &durationDays, &startDate, &endDate, &isTeamChallenge)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return errors.New("défi non trouvé")
@@ -403,209 +612,149 @@ func JoinChallenge(db *sql.DB, userID, challengeID int64) error {
 		return errors.New("ce défi n'est pas actif")
 	}
 
-	// Vérifier si l'utilisateur participe déjà
-	var status string
-	err = db.QueryRow(
-		"SELECT status FROM challenge_participants WHERE user_id = ? AND challenge_id = ?",
-		userID, challengeID,
-	).Scan(&status)
-
-	if err == nil {
-		// L'utilisateur participe déjà
-		if status == "in_progress" || status == "completed" {
-			return errors.New("vous participez déjà à ce défi")
-		}
-
-		// Si abandonné, mettre à jour le statut
-		_, err = db.Exec(
-			"UPDATE challenge_participants SET status = 'in_progress', joined_at = ?, completed_at = NULL WHERE user_id = ? AND challenge_id = ?",
-			time.Now(), userID, challengeID,
-		)
-		return err
-	} else if err != sql.ErrNoRows {
+	// Vérifier que la période du défi est ouverte
+	if err := checkChallengeWindow(startDate, endDate, durationDays, time.Now()); err != nil {
 		return err
 	}
 
-	// Sinon, créer une nouvelle participation
-	_, err = db.Exec(
-		"INSERT INTO challenge_participants (user_id, challenge_id, status, joined_at) VALUES (?, ?, 'in_progress', ?)",
-		userID, challengeID, time.Now(),
-	)
-
-	return err
-}
-
-// CompleteChallenge marque un défi comme terminé pour un utilisateur
-func CompleteChallenge(db *sql.DB, userID, challengeID int64) error {
-	// Vérifier si l'utilisateur participe au défi
-	var status string
-	var joinedAt time.Time
-	var points int
-
-	err := db.QueryRow(`
-		SELECT cp.status, cp.joined_at, c.points
-		FROM challenge_participants cp
-		JOIN eco_challenges c ON cp.challenge_id = c.id
-		WHERE cp.user_id = ? AND cp.challenge_id = ?
-	`, userID, challengeID).Scan(&status, &joinedAt, &points)
-
+	// Démarrer une transaction
+	tx, err := db.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return errors.New("vous ne participez pas à ce défi")
-		}
 		return err
 	}
 
-	if status != "in_progress" {
-		if status == "completed" {
-			return errors.New("vous avez déjà terminé ce défi")
+	// Un défi en équipe se joue pour l'équipe de l'utilisateur
+	var teamID interface{}
+	if isTeamChallenge {
+		userTeamID, err := userTeamIDTx(tx, userID)
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			return errors.New("ce défi se joue en équipe: rejoignez une équipe d'abord")
+		} else if err != nil {
+			tx.Rollback()
+			return err
 		}
-		return errors.New("vous ne pouvez pas terminer ce défi")
+		teamID = userTeamID
 	}
 
-	// Commencer une transaction
-	tx, err := db.Begin()
-	if err != nil {
+	// Vérifier si l'utilisateur participe déjà
+	var attempt int
+	var status string
+	err = tx.QueryRow(`
+		SELECT a.attempt, a.status
+		FROM challenge_participants cp
+		JOIN challenge_attempts a ON cp.attempt_id = a.id
+		WHERE cp.user_id = ? AND cp.challenge_id = ?
+	`, userID, challengeID).Scan(&attempt, &status)
+
+	if err == nil {
+		// Un défi abandonné peut être rejoint pour une nouvelle tentative
+		if status != ChallengeAbandoned {
+			tx.Rollback()
+			return errors.New("vous participez déjà à ce défi")
+		}
+	} else if err != sql.ErrNoRows {
+		tx.Rollback()
 		return err
 	}
 
-	// Mettre à jour le statut
-	now := time.Now()
-	_, err = tx.Exec(
-		"UPDATE challenge_participants SET status = 'completed', completed_at = ? WHERE user_id = ? AND challenge_id = ?",
-		now, userID, challengeID,
+	// Créer la nouvelle tentative
+	result, err := tx.Exec(
+		"INSERT INTO challenge_attempts (user_id, challenge_id, attempt, status, team_id, joined_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, challengeID, attempt+1, ChallengeInProgress, teamID, time.Now(),
 	)
-
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	// Ajouter les points
-	_, err = tx.Exec(
-		"INSERT INTO eco_points (user_id, challenge_id, points, description) VALUES (?, ?, ?, ?)",
-		userID, challengeID, points, "Défi complété",
-	)
-
+	attemptID, err := result.LastInsertId()
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	// Commit de la transaction
-	if err = tx.Commit(); err != nil {
+	// En faire la tentative en cours de la participation
+	_, err = tx.Exec(`
+		INSERT INTO challenge_participants (user_id, challenge_id, attempt_id) VALUES (?, ?, ?)
+		ON CONFLICT(user_id, challenge_id) DO UPDATE SET attempt_id = excluded.attempt_id
+	`, userID, challengeID, attemptID)
+	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	// Vérifier et attribuer les badges basés sur les points totaux
-	go checkAndAwardBadges(db, userID)
-
-	return nil
+	return tx.Commit()
 }
 
-// GetUserBadges récupère les badges d'un utilisateur
+// GetUserBadges récupère les badges d'un utilisateur: ceux obtenus et ceux restant à obtenir,
+// avec l'avancement sur chacun de leurs critères
 func GetUserBadges(db *sql.DB, userID int64) ([]models.Badge, []models.Badge, error) {
-	// Récupérer les points totaux de l'utilisateur
-	var totalPoints int
-	err := db.QueryRow("SELECT COALESCE(SUM(points), 0) FROM eco_points WHERE user_id = ?", userID).Scan(&totalPoints)
+	badges, err := GetBadges(db)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Récupérer les badges déjà obtenus
-	earnedBadgesRows, err := db.Query(`
-		SELECT b.id, b.name, b.description, b.image_path, b.required_points, b.category, ub.earned_at
-		FROM badges b
-		JOIN user_badges ub ON b.id = ub.badge_id
-		WHERE ub.user_id = ?
-		ORDER BY ub.earned_at DESC
-	`, userID)
-
+	// Récupérer les dates d'obtention
+	rows, err := db.Query("SELECT badge_id, earned_at FROM user_badges WHERE user_id = ?", userID)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer earnedBadgesRows.Close()
-
-	// Parcourir les badges obtenus
-	earnedBadges := []models.Badge{}
-	for earnedBadgesRows.Next() {
-		var badge models.Badge
-		var earnedAt time.Time
-
-		err := earnedBadgesRows.Scan(
-			&badge.ID, &badge.Name, &badge.Description, &badge.ImagePath,
-			&badge.RequiredPoints, &badge.Category, &earnedAt,
-		)
 
-		if err != nil {
+	earnedAt := make(map[int64]time.Time)
+	for rows.Next() {
+		var badgeID int64
+		var date time.Time
+		if err := rows.Scan(&badgeID, &date); err != nil {
+			rows.Close()
 			return nil, nil, err
 		}
-
-		badge.IsEarned = true
-		badge.EarnedAt = earnedAt
-		earnedBadges = append(earnedBadges, badge)
-	}
-
-	if err = earnedBadgesRows.Err(); err != nil {
-		return nil, nil, err
+		earnedAt[badgeID] = date
 	}
+	rows.Close()
 
-	// Récupérer les badges disponibles mais non obtenus
-	availableBadgesRows, err := db.Query(`
-		SELECT b.id, b.name, b.description, b.image_path, b.required_points, b.category
-		FROM badges b
-		WHERE b.id NOT IN (SELECT badge_id FROM user_badges WHERE user_id = ?)
-		ORDER BY b.required_points ASC
-	`, userID)
-
-	if err != nil {
+	if err = rows.Err(); err != nil {
 		return nil, nil, err
 	}
-	defer availableBadgesRows.Close()
 
-	// Parcourir les badges disponibles
+	// Répartir les badges et calculer l'avancement
+	evaluator := newBadgeEvaluator(db, userID, time.Now())
+	earnedBadges := []models.Badge{}
 	availableBadges := []models.Badge{}
-	for availableBadgesRows.Next() {
-		var badge models.Badge
-
-		err := availableBadgesRows.Scan(
-			&badge.ID, &badge.Name, &badge.Description, &badge.ImagePath,
-			&badge.RequiredPoints, &badge.Category,
-		)
-
+	for _, badge := range badges {
+		badge.Progress, _, err = evaluator.progress(badge.Rules)
 		if err != nil {
 			return nil, nil, err
 		}
 
-		badge.IsEarned = false
-		badge.PointsToGo = badge.RequiredPoints - totalPoints
-		if badge.PointsToGo < 0 {
-			badge.PointsToGo = 0
+		if date, ok := earnedAt[badge.ID]; ok {
+			badge.IsEarned = true
+			badge.EarnedAt = date
+			earnedBadges = append(earnedBadges, badge)
+		} else {
+			availableBadges = append(availableBadges, badge)
 		}
-
-		availableBadges = append(availableBadges, badge)
 	}
 
-	if err = availableBadgesRows.Err(); err != nil {
-		return nil, nil, err
-	}
+	// Les badges obtenus les plus récents en premier
+	sort.SliceStable(earnedBadges, func(i, j int) bool {
+		return earnedBadges[i].EarnedAt.After(earnedBadges[j].EarnedAt)
+	})
 
 	return earnedBadges, availableBadges, nil
 }
 
-// checkAndAwardBadges vérifie et attribue les badges en fonction des points
-func checkAndAwardBadges(db *sql.DB, userID int64) {
-	// Récupérer les points totaux de l'utilisateur
-	var totalPoints int
-	err := db.QueryRow("SELECT COALESCE(SUM(points), 0) FROM eco_points WHERE user_id = ?", userID).Scan(&totalPoints)
+// awardBadgesTx attribue à un utilisateur, dans une transaction, les badges dont tous les critères sont remplis
+func awardBadgesTx(tx *sql.Tx, userID int64, now time.Time) error {
+	badges, err := getBadges(tx)
 	if err != nil {
-		return
+		return err
 	}
 
 	// Récupérer les badges déjà obtenus
-	rows, err := db.Query("SELECT badge_id FROM user_badges WHERE user_id = ?", userID)
+	rows, err := tx.Query("SELECT badge_id FROM user_badges WHERE user_id = ?", userID)
 	if err != nil {
-		return
+		return err
 	}
 
 	earnedBadgeIDs := make(map[int64]bool)
@@ -613,41 +762,40 @@ func checkAndAwardBadges(db *sql.DB, userID int64) {
 		var badgeID int64
 		if err := rows.Scan(&badgeID); err != nil {
 			rows.Close()
-			return
+			return err
 		}
 		earnedBadgeIDs[badgeID] = true
 	}
 	rows.Close()
 
-	// Récupérer les badges disponibles
-	badgeRows, err := db.Query(
-		"SELECT id FROM badges WHERE required_points <= ? ORDER BY required_points ASC",
-		totalPoints,
-	)
-	if err != nil {
-		return
+	if err = rows.Err(); err != nil {
+		return err
 	}
-	defer badgeRows.Close()
 
-	// Attribuer les nouveaux badges
-	for badgeRows.Next() {
-		var badgeID int64
-		if err := badgeRows.Scan(&badgeID); err != nil {
-			return
+	evaluator := newBadgeEvaluator(tx, userID, now)
+	for _, badge := range badges {
+		if earnedBadgeIDs[badge.ID] {
+			continue
+		}
+
+		_, met, err := evaluator.progress(badge.Rules)
+		if err != nil {
+			return err
+		}
+		if !met {
+			continue
 		}
 
-		// Si le badge n'a pas déjà été attribué
-		if !earnedBadgeIDs[badgeID] {
-			_, err := db.Exec(
-				"INSERT INTO user_badges (user_id, badge_id) VALUES (?, ?)",
-				userID, badgeID,
-			)
-			if err != nil {
-				// Ignorer les erreurs d'insertion (comme les tentatives en double)
-				continue
-			}
+		_, err = tx.Exec(
+			"INSERT OR IGNORE INTO user_badges (user_id, badge_id) VALUES (?, ?)",
+			userID, badge.ID,
+		)
+		if err != nil {
+			return err
 		}
 	}
+
+	return nil
 }
 
 // GetEcoDashboardSummary récupère un résumé du tableau de bord écologique
@@ -660,14 +808,14 @@ func GetEcoDashboardSummary(db *sql.DB, userID int64) (*models.EcoDashboardSumma
 		return nil, err
 	}
 
-	// Récupérer le nombre d'activités auxquelles l'utilisateur a participé
-	err = db.QueryRow("SELECT COUNT(DISTINCT activity_id) FROM registrations WHERE user_id = ?", userID).Scan(&summary.ActivitiesAttended)
+	// Récupérer le nombre d'activités auxquelles l'utilisateur a réellement participé
+	err = db.QueryRow("SELECT COUNT(*) FROM attendances WHERE user_id = ? AND status = 'present'", userID).Scan(&summary.ActivitiesAttended)
 	if err != nil {
 		return nil, err
 	}
 
 	// Récupérer le nombre de défis complétés
-	err = db.QueryRow("SELECT COUNT(*) FROM challenge_participants WHERE user_id = ? AND status = 'completed'", userID).Scan(&summary.ChallengesCompleted)
+	err = db.QueryRow("SELECT COUNT(*) FROM challenge_attempts WHERE user_id = ? AND status = 'completed'", userID).Scan(&summary.ChallengesCompleted)
 	if err != nil {
 		return nil, err
 	}
@@ -679,31 +827,30 @@ func GetEcoDashboardSummary(db *sql.DB, userID int64) (*models.EcoDashboardSumma
 	}
 
 	// Récupérer le classement de l'utilisateur et le nombre total d'utilisateurs
-	err = db.QueryRow(`
-		SELECT ranking, total_users
-		FROM (
-			SELECT 
-				user_id, 
-				COALESCE(SUM(points), 0) as total_points,
-				RANK() OVER (ORDER BY COALESCE(SUM(points), 0) DESC) as ranking,
-				COUNT(*) OVER () as total_users
-			FROM eco_points
-			GROUP BY user_id
-		) rankings
-		WHERE user_id = ?
-	`, userID).Scan(&summary.Ranking, &summary.TotalUsers)
-
-	if err == sql.ErrNoRows {
-		// L'utilisateur n'a pas encore de points, donc dernier du classement
-		var totalUsers int
-		err = db.QueryRow("SELECT COUNT(*) FROM users").Scan(&totalUsers)
-		if err != nil {
-			return nil, err
-		}
+	entry, err := GetLeaderboardEntry(db, models.LeaderboardPeriod{Name: LeaderboardAllTime}, userID)
+	if err != nil {
+		return nil, err
+	}
+	summary.Ranking = entry.Rank
+
+	err = db.QueryRow("SELECT COUNT(*) FROM users").Scan(&summary.TotalUsers)
+	if err != nil {
+		return nil, err
+	}
 
-		summary.Ranking = totalUsers
-		summary.TotalUsers = totalUsers
-	} else if err != nil {
+	// Récupérer la série de pointages quotidiens et les paliers atteints
+	streak, err := GetUserStreak(db, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	summary.CurrentStreak = streak.CurrentStreak
+	summary.BestStreak = streak.BestStreak
+	summary.StreakMilestones, summary.NextStreakMilestone = streakMilestones(streak.CurrentStreak, streak.BestStreak)
+
+	// Récupérer l'impact environnemental et son évolution mensuelle
+	summary.Impact, summary.ImpactByMonth, err = getMonthlyImpact(db, userID)
+	if err != nil {
 		return nil, err
 	}
 
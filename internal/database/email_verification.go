@@ -0,0 +1,91 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"bdd-website/internal/utils"
+)
+
+// ErrEmailNotVerified est retournée lorsqu'une action exige une adresse email confirmée
+var ErrEmailNotVerified = errors.New("vous devez confirmer votre adresse email avant de vous inscrire à une activité ou de rejoindre un défi")
+
+// IsEmailVerified indique si l'utilisateur a confirmé son adresse email
+func IsEmailVerified(db *sql.DB, userID int64) (bool, error) {
+	var verified bool
+	err := db.QueryRow("SELECT email_verified_at IS NOT NULL FROM users WHERE id = ?", userID).Scan(&verified)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, errors.New("utilisateur non trouvé")
+		}
+		return false, err
+	}
+
+	return verified, nil
+}
+
+// GetEmailVerificationState récupère l'adresse email d'un utilisateur, si elle est confirmée,
+// et la date du dernier envoi du lien de confirmation (zéro si aucun envoi)
+func GetEmailVerificationState(db *sql.DB, userID int64) (string, bool, time.Time, error) {
+	var email string
+	var verified bool
+	var sentAt sql.NullTime
+	err := db.QueryRow(
+		"SELECT email, email_verified_at IS NOT NULL, verification_sent_at FROM users WHERE id = ?",
+		userID,
+	).Scan(&email, &verified, &sentAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, time.Time{}, errors.New("utilisateur non trouvé")
+		}
+		return "", false, time.Time{}, err
+	}
+
+	return email, verified, sentAt.Time, nil
+}
+
+// MarkVerificationEmailSent enregistre l'envoi d'un lien de confirmation
+func MarkVerificationEmailSent(db *sql.DB, userID int64, sentAt time.Time) error {
+	_, err := db.Exec("UPDATE users SET verification_sent_at = ? WHERE id = ?", sentAt, userID)
+	return err
+}
+
+// VerifyUserEmail confirme l'adresse email de l'utilisateur désigné par un jeton de vérification.
+// Le jeton doit correspondre à l'adresse actuelle; confirmer une adresse déjà confirmée n'a pas d'effet.
+// Retourne l'ID de l'utilisateur.
+func VerifyUserEmail(db *sql.DB, token, secret string) (int64, error) {
+	userID, err := utils.ParseEmailVerificationToken(token)
+	if err != nil {
+		return 0, err
+	}
+
+	var email string
+	var verified bool
+	err = db.QueryRow("SELECT email, email_verified_at IS NOT NULL FROM users WHERE id = ?", userID).Scan(&email, &verified)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, utils.ErrInvalidVerificationToken
+		}
+		return 0, err
+	}
+
+	if err = utils.ValidateEmailVerificationToken(token, email, secret); err != nil {
+		return 0, err
+	}
+
+	if verified {
+		return userID, nil
+	}
+
+	// L'adresse est comparée à nouveau pour ne pas confirmer une adresse modifiée entre-temps
+	_, err = db.Exec(
+		"UPDATE users SET email_verified_at = ? WHERE id = ? AND email = ? AND email_verified_at IS NULL",
+		time.Now(), userID, email,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,302 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// Types d'événements métier enregistrés dans la file (outbox)
+const (
+	EventPointsCredited      = "points_credited"      // Des points ont été crédités à un utilisateur
+	EventPointsAdjusted      = "points_adjusted"      // Un administrateur a ajusté ou annulé des points
+	EventChallengeCompleted  = "challenge_completed"  // La preuve d'un défi a été approuvée
+	EventAttendanceConfirmed = "attendance_confirmed" // La présence à une activité a été confirmée
+	EventCheckinRecorded     = "checkin_recorded"     // Un pointage quotidien a prolongé une série
+	EventBadgeRulesChanged   = "badge_rules_changed"  // Un badge a été créé ou ses critères modifiés
+)
+
+// Statuts des événements
+const (
+	EventPending   = "pending"
+	EventProcessed = "processed"
+	EventFailed    = "failed" // Abandonné après MaxEventAttempts tentatives
+)
+
+// Paramètres de traitement des événements
+const (
+	MaxEventAttempts = 8
+	EventBatchSize   = 50
+	eventBaseBackoff = 10 * time.Second
+	eventMaxBackoff  = time.Hour
+)
+
+// eventHandler traite un événement dans la transaction qui le marque comme traité
+type eventHandler func(tx *sql.Tx, event models.DomainEvent, now time.Time) error
+
+// eventHandlers associe chaque type d'événement à son traitement
+var eventHandlers = map[string]eventHandler{
+	EventPointsCredited:      awardBadgesForEvent,
+	EventPointsAdjusted:      awardBadgesForEvent,
+	EventChallengeCompleted:  awardBadgesForEvent,
+	EventAttendanceConfirmed: awardBadgesForEvent,
+	EventCheckinRecorded:     awardBadgesForEvent,
+	EventBadgeRulesChanged:   awardBadgesToAllUsersTx,
+}
+
+// recordEventTx enregistre un événement dans la transaction de l'opération qui le produit:
+// l'événement n'existe que si l'opération est validée, et il sera traité même en cas d'arrêt du serveur
+func recordEventTx(tx *sql.Tx, eventType string, userID int64, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(
+		`INSERT INTO domain_events (event_type, user_id, payload, status, created_at, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		eventType, nullIfZero(userID), string(data), EventPending, now, now,
+	)
+	return err
+}
+
+// ProcessEvents traite les événements en attente dont l'échéance de tentative est atteinte.
+// Chaque événement est traité dans sa propre transaction; un échec est journalisé et retenté plus tard.
+// Retourne le nombre d'événements traités et en échec.
+func ProcessEvents(db *sql.DB, now time.Time) (int, int, error) {
+	rows, err := db.Query(`
+		SELECT id, event_type, user_id, payload, attempts, created_at
+		FROM domain_events
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY id ASC
+		LIMIT ?
+	`, EventPending, now, EventBatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	events := []models.DomainEvent{}
+	for rows.Next() {
+		var event models.DomainEvent
+		var userID sql.NullInt64
+		var payload string
+
+		if err := rows.Scan(&event.ID, &event.Type, &userID, &payload, &event.Attempts, &event.CreatedAt); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+
+		event.UserID = userID.Int64
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	processed, failed := 0, 0
+	for _, event := range events {
+		if err := processEvent(db, event, now); err != nil {
+			failed++
+			if err := recordEventFailure(db, event, err, now); err != nil {
+				return processed, failed, err
+			}
+			continue
+		}
+		processed++
+	}
+
+	return processed, failed, nil
+}
+
+// processEvent exécute le traitement d'un événement et le marque comme traité dans la même transaction
+func processEvent(db *sql.DB, event models.DomainEvent, now time.Time) error {
+	handler, ok := eventHandlers[event.Type]
+	if !ok {
+		return fmt.Errorf("type d'événement inconnu: %q", event.Type)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := handler(tx, event, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE domain_events SET status = ?, attempts = attempts + 1, processed_at = ?, last_error = NULL WHERE id = ?",
+		EventProcessed, now, event.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// recordEventFailure journalise l'échec d'un événement et planifie une nouvelle tentative
+// avec un délai exponentiel, ou l'abandonne après MaxEventAttempts tentatives
+func recordEventFailure(db *sql.DB, event models.DomainEvent, cause error, now time.Time) error {
+	attempts := event.Attempts + 1
+	status := EventPending
+
+	backoff := eventBaseBackoff << uint(attempts-1)
+	if backoff > eventMaxBackoff || backoff <= 0 {
+		backoff = eventMaxBackoff
+	}
+
+	if attempts >= MaxEventAttempts {
+		status = EventFailed
+		log.Printf("Événement %d (%s) abandonné après %d tentatives: %v", event.ID, event.Type, attempts, cause)
+	} else {
+		log.Printf("Échec du traitement de l'événement %d (%s), tentative %d/%d: %v", event.ID, event.Type, attempts, MaxEventAttempts, cause)
+	}
+
+	_, err := db.Exec(
+		"UPDATE domain_events SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		status, attempts, cause.Error(), now.Add(backoff), event.ID,
+	)
+	return err
+}
+
+// StartEventWorker traite périodiquement la file des événements.
+// La fonction bloque et doit être lancée dans une goroutine.
+func StartEventWorker(db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		processed, failed, err := ProcessEvents(db, time.Now())
+		if err != nil {
+			log.Printf("Erreur lors du traitement des événements: %v", err)
+		} else if failed > 0 {
+			log.Printf("Événements: %d traité(s), %d en échec", processed, failed)
+		}
+
+		<-ticker.C
+	}
+}
+
+// GetEventStats récupère l'état de la file des événements
+func GetEventStats(db *sql.DB) (*models.EventStats, error) {
+	stats := &models.EventStats{}
+
+	rows, err := db.Query("SELECT status, COUNT(*) FROM domain_events GROUP BY status")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+
+		switch status {
+		case EventPending:
+			stats.Pending = count
+		case EventProcessed:
+			stats.Processed = count
+		case EventFailed:
+			stats.Failed = count
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ancienneté du plus vieil événement en attente (retard du traitement)
+	err = db.QueryRow(
+		"SELECT created_at FROM domain_events WHERE status = ? ORDER BY id ASC LIMIT 1",
+		EventPending,
+	).Scan(&stats.OldestPendingAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetEvents récupère les derniers événements d'un statut donné
+func GetEvents(db *sql.DB, status string, limit int) ([]models.DomainEvent, error) {
+	rows, err := db.Query(`
+		SELECT id, event_type, user_id, payload, status, attempts, last_error, created_at, processed_at, next_attempt_at
+		FROM domain_events
+		WHERE status = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.DomainEvent{}
+	for rows.Next() {
+		var event models.DomainEvent
+		var userID sql.NullInt64
+		var payload string
+		var lastError sql.NullString
+		var processedAt sql.NullTime
+
+		err := rows.Scan(
+			&event.ID, &event.Type, &userID, &payload, &event.Status, &event.Attempts,
+			&lastError, &event.CreatedAt, &processedAt, &event.NextAttemptAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		event.UserID = userID.Int64
+		event.Payload = json.RawMessage(payload)
+		event.LastError = lastError.String
+		if processedAt.Valid {
+			event.ProcessedAt = processedAt.Time
+		}
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// RetryEvent remet en file un événement abandonné
+func RetryEvent(db *sql.DB, eventID int64) error {
+	result, err := db.Exec(
+		"UPDATE domain_events SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND status = ?",
+		EventPending, time.Now(), eventID, EventFailed,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("événement non trouvé ou non abandonné")
+	}
+
+	return nil
+}
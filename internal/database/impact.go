@@ -0,0 +1,436 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// Indicateurs d'impact environnemental
+const (
+	ImpactCO2   = "co2_kg"   // Kilos de CO2 évités
+	ImpactWaste = "waste_kg" // Kilos de déchets collectés
+	ImpactWater = "water_l"  // Litres d'eau économisés
+)
+
+// ImpactMetrics liste les indicateurs d'impact autorisés
+var ImpactMetrics = []string{ImpactCO2, ImpactWaste, ImpactWater}
+
+// Modes de calcul d'un facteur d'impact
+const (
+	ImpactFixed    = "fixed"    // Montant fixe crédité à chaque participation
+	ImpactReported = "reported" // Montant déclaré par le participant
+)
+
+// MaxImpactValue limite un facteur d'impact et un montant déclaré
+const MaxImpactValue = 100000
+
+// Intervalles des séries temporelles d'impact
+const (
+	ImpactDaily   = "day"
+	ImpactWeekly  = "week"
+	ImpactMonthly = "month"
+)
+
+// impactSeriesFormats associe chaque intervalle au format strftime de ses périodes
+var impactSeriesFormats = map[string]string{
+	ImpactDaily:   "%Y-%m-%d",
+	ImpactWeekly:  "%Y-W%W",
+	ImpactMonthly: "%Y-%m",
+}
+
+// MaxImpactSeriesDays limite la durée d'une série temporelle journalière
+const MaxImpactSeriesDays = 366
+
+// impactSumColumns totalise les montants par indicateur
+const impactSumColumns = `
+	COALESCE(SUM(CASE WHEN metric = 'co2_kg' THEN amount END), 0),
+	COALESCE(SUM(CASE WHEN metric = 'waste_kg' THEN amount END), 0),
+	COALESCE(SUM(CASE WHEN metric = 'water_l' THEN amount END), 0)`
+
+// IsValidImpactMetric vérifie qu'un indicateur fait partie de la liste autorisée
+func IsValidImpactMetric(metric string) bool {
+	for _, m := range ImpactMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeImpactFactors valide les facteurs d'impact d'une activité ou d'un défi:
+// un seul facteur par indicateur, un montant positif pour un facteur fixe
+func NormalizeImpactFactors(factors []models.ImpactFactor) ([]models.ImpactFactor, error) {
+	normalized := []models.ImpactFactor{}
+	seen := make(map[string]bool)
+
+	for _, factor := range factors {
+		factor.Metric = strings.TrimSpace(factor.Metric)
+		if !IsValidImpactMetric(factor.Metric) {
+			return nil, fmt.Errorf("indicateur d'impact invalide (%s)", strings.Join(ImpactMetrics, ", "))
+		}
+
+		if seen[factor.Metric] {
+			return nil, fmt.Errorf("l'indicateur %s est défini plusieurs fois", factor.Metric)
+		}
+		seen[factor.Metric] = true
+
+		if factor.Mode == "" {
+			factor.Mode = ImpactFixed
+		}
+
+		switch factor.Mode {
+		case ImpactFixed:
+			if factor.Value <= 0 || factor.Value > MaxImpactValue {
+				return nil, fmt.Errorf("le montant fixe de %s doit être compris entre 0 et %d", factor.Metric, MaxImpactValue)
+			}
+		case ImpactReported:
+			if factor.Value < 0 || factor.Value > MaxImpactValue {
+				return nil, fmt.Errorf("le plafond déclarable de %s doit être compris entre 0 (sans plafond) et %d", factor.Metric, MaxImpactValue)
+			}
+		default:
+			return nil, errors.New("mode d'impact invalide (fixed ou reported)")
+		}
+
+		normalized = append(normalized, factor)
+	}
+
+	return normalized, nil
+}
+
+// setImpactFactorsTx remplace les facteurs d'impact d'une activité (column = "activity_id")
+// ou d'un défi (column = "challenge_id") dans une transaction.
+// Les impacts déjà enregistrés ne sont pas recalculés.
+func setImpactFactorsTx(tx *sql.Tx, column string, sourceID int64, factors []models.ImpactFactor) error {
+	_, err := tx.Exec("DELETE FROM impact_factors WHERE "+column+" = ?", sourceID)
+	if err != nil {
+		return err
+	}
+
+	for _, factor := range factors {
+		_, err = tx.Exec(
+			"INSERT INTO impact_factors ("+column+", metric, mode, value) VALUES (?, ?, ?, ?)",
+			sourceID, factor.Metric, factor.Mode, factor.Value,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getImpactFactors récupère les facteurs d'impact d'une activité ou d'un défi
+func getImpactFactors(q queryer, column string, sourceID int64) ([]models.ImpactFactor, error) {
+	rows, err := q.Query("SELECT metric, mode, value FROM impact_factors WHERE "+column+" = ? ORDER BY metric", sourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	factors := []models.ImpactFactor{}
+	for rows.Next() {
+		var factor models.ImpactFactor
+		if err := rows.Scan(&factor.Metric, &factor.Mode, &factor.Value); err != nil {
+			return nil, err
+		}
+		factors = append(factors, factor)
+	}
+
+	return factors, rows.Err()
+}
+
+// loadChallengeImpactFactors renseigne les facteurs d'impact d'une liste de défis en une seule requête
+func loadChallengeImpactFactors(db *sql.DB, challenges []models.Challenge) error {
+	if len(challenges) == 0 {
+		return nil
+	}
+
+	// Indexer les défis par ID
+	index := make(map[int64][]int, len(challenges))
+	for i := range challenges {
+		challenges[i].Impact = []models.ImpactFactor{}
+		index[challenges[i].ID] = append(index[challenges[i].ID], i)
+	}
+
+	rows, err := db.Query("SELECT challenge_id, metric, mode, value FROM impact_factors WHERE challenge_id IS NOT NULL ORDER BY metric")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var challengeID int64
+		var factor models.ImpactFactor
+		if err := rows.Scan(&challengeID, &factor.Metric, &factor.Mode, &factor.Value); err != nil {
+			return err
+		}
+		for _, i := range index[challengeID] {
+			challenges[i].Impact = append(challenges[i].Impact, factor)
+		}
+	}
+
+	return rows.Err()
+}
+
+// validateImpactReport vérifie des montants déclarés par un participant:
+// chaque indicateur doit être déclarable pour l'activité ou le défi et respecter son plafond
+func validateImpactReport(factors []models.ImpactFactor, amounts map[string]float64) error {
+	for metric, amount := range amounts {
+		var factor *models.ImpactFactor
+		for i := range factors {
+			if factors[i].Metric == metric && factors[i].Mode == ImpactReported {
+				factor = &factors[i]
+				break
+			}
+		}
+
+		if factor == nil {
+			return fmt.Errorf("l'indicateur %s ne peut pas être déclaré ici", metric)
+		}
+
+		if amount <= 0 || math.IsNaN(amount) || amount > MaxImpactValue {
+			return fmt.Errorf("le montant déclaré pour %s doit être positif", metric)
+		}
+
+		if factor.Value > 0 && amount > factor.Value {
+			return fmt.Errorf("le montant déclaré pour %s ne peut pas dépasser %g", metric, factor.Value)
+		}
+	}
+
+	return nil
+}
+
+// recordFixedImpactTx enregistre les impacts fixes d'une participation à une activité ou à un défi
+func recordFixedImpactTx(tx *sql.Tx, userID int64, column string, sourceID int64, now time.Time) error {
+	factors, err := getImpactFactors(tx, column, sourceID)
+	if err != nil {
+		return err
+	}
+
+	for _, factor := range factors {
+		if factor.Mode != ImpactFixed {
+			continue
+		}
+
+		err = insertImpactEntryTx(tx, userID, column, sourceID, factor.Metric, ImpactFixed, factor.Value, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertImpactEntryTx enregistre l'impact d'une participation.
+// La date est enregistrée en UTC pour être comparable aux bornes de impactPeriod.
+func insertImpactEntryTx(tx *sql.Tx, userID int64, column string, sourceID int64, metric, mode string, amount float64, now time.Time) error {
+	_, err := tx.Exec(
+		"INSERT INTO impact_entries (user_id, "+column+", metric, mode, amount, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, sourceID, metric, mode, amount, now.UTC(),
+	)
+	return err
+}
+
+// ReportActivityImpact enregistre les montants déclarés par un participant présent à une activité
+// (ex: kilos de déchets collectés). Une nouvelle déclaration remplace la précédente pour chaque indicateur.
+func ReportActivityImpact(db *sql.DB, userID, activityID int64, amounts map[string]float64) error {
+	if len(amounts) == 0 {
+		return errors.New("aucun montant déclaré")
+	}
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Seuls les participants dont la présence est confirmée peuvent déclarer
+	var status string
+	err = tx.QueryRow(
+		"SELECT status FROM attendances WHERE user_id = ? AND activity_id = ?",
+		userID, activityID,
+	).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return err
+	}
+
+	if status != AttendancePresent {
+		tx.Rollback()
+		return errors.New("votre présence à cette activité n'a pas été confirmée")
+	}
+
+	factors, err := getImpactFactors(tx, "activity_id", activityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = validateImpactReport(factors, amounts); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	now := time.Now()
+	for metric, amount := range amounts {
+		_, err = tx.Exec(
+			"DELETE FROM impact_entries WHERE user_id = ? AND activity_id = ? AND metric = ? AND mode = ?",
+			userID, activityID, metric, ImpactReported,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err = insertImpactEntryTx(tx, userID, "activity_id", activityID, metric, ImpactReported, amount, now)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetActivityImpactReport récupère les montants déclarés par un participant pour une activité
+func GetActivityImpactReport(db *sql.DB, userID, activityID int64) (map[string]float64, error) {
+	rows, err := db.Query(
+		"SELECT metric, amount FROM impact_entries WHERE user_id = ? AND activity_id = ? AND mode = ?",
+		userID, activityID, ImpactReported,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	amounts := make(map[string]float64)
+	for rows.Next() {
+		var metric string
+		var amount float64
+		if err := rows.Scan(&metric, &amount); err != nil {
+			return nil, err
+		}
+		amounts[metric] = amount
+	}
+
+	return amounts, rows.Err()
+}
+
+// impactScope restreint les impacts à un utilisateur (0 = toute l'association)
+func impactScope(userID int64, where string, args []interface{}) (string, []interface{}) {
+	if userID != 0 {
+		where += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	return where, args
+}
+
+// GetImpactTotals calcule l'impact cumulé d'un utilisateur (0 = toute l'association) sur une période.
+// Des bornes nulles ne restreignent pas la période.
+func GetImpactTotals(db *sql.DB, userID int64, from, to time.Time) (models.ImpactTotals, error) {
+	where, args := impactPeriod(from, to)
+	where, args = impactScope(userID, where, args)
+
+	var totals models.ImpactTotals
+	err := db.QueryRow("SELECT "+impactSumColumns+" FROM impact_entries WHERE "+where, args...).
+		Scan(&totals.CO2Kg, &totals.WasteKg, &totals.WaterL)
+
+	return totals, err
+}
+
+// GetImpactSeries calcule l'impact d'un utilisateur (0 = toute l'association) par jour, semaine ou mois.
+// Les périodes sans impact ne figurent pas dans la série.
+func GetImpactSeries(db *sql.DB, userID int64, interval string, from, to time.Time) ([]models.ImpactSeriesPoint, error) {
+	format, ok := impactSeriesFormats[interval]
+	if !ok {
+		return nil, errors.New("intervalle invalide (day, week ou month)")
+	}
+
+	where, args := impactPeriod(from, to)
+	where, args = impactScope(userID, where, args)
+
+	rows, err := db.Query(`
+		SELECT strftime('`+format+`', created_at) as period,`+impactSumColumns+`
+		FROM impact_entries
+		WHERE `+where+`
+		GROUP BY period
+		ORDER BY period
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []models.ImpactSeriesPoint{}
+	for rows.Next() {
+		var point models.ImpactSeriesPoint
+		if err := rows.Scan(&point.Period, &point.CO2Kg, &point.WasteKg, &point.WaterL); err != nil {
+			return nil, err
+		}
+		series = append(series, point)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// impactPeriod construit le filtre de période des impacts.
+// Les dates des impacts sont enregistrées en UTC (voir insertImpactEntryTx): les bornes sont converties
+// en UTC pour que la comparaison des textes suive l'ordre chronologique.
+func impactPeriod(from, to time.Time) (string, []interface{}) {
+	where := "1 = 1"
+	args := []interface{}{}
+
+	if !from.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		where += " AND created_at <= ?"
+		args = append(args, to.UTC())
+	}
+
+	return where, args
+}
+
+// ImpactSeriesStart calcule le début par défaut d'une série temporelle:
+// les 30 derniers jours, les 12 dernières semaines ou les 12 derniers mois
+func ImpactSeriesStart(interval string, now time.Time) time.Time {
+	year, month, day := now.Date()
+
+	switch interval {
+	case ImpactDaily:
+		return time.Date(year, month, day-29, 0, 0, 0, 0, now.Location())
+	case ImpactWeekly:
+		// La semaine commence le lundi
+		offset := (int(now.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset-11*7, 0, 0, 0, 0, now.Location())
+	default:
+		return time.Date(year, month-11, 1, 0, 0, 0, 0, now.Location())
+	}
+}
+
+// getMonthlyImpact calcule l'impact cumulé et son évolution sur les douze derniers mois
+func getMonthlyImpact(db *sql.DB, userID int64) (models.ImpactTotals, []models.ImpactSeriesPoint, error) {
+	totals, err := GetImpactTotals(db, userID, time.Time{}, time.Time{})
+	if err != nil {
+		return totals, nil, err
+	}
+
+	now := time.Now()
+	series, err := GetImpactSeries(db, userID, ImpactMonthly, ImpactSeriesStart(ImpactMonthly, now), now)
+	if err != nil {
+		return totals, nil, err
+	}
+
+	return totals, series, nil
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// Périodes de classement
+const (
+	LeaderboardAllTime = "all"
+	LeaderboardMonth   = "month"
+	LeaderboardWeek    = "week"
+	LeaderboardCustom  = "custom"
+)
+
+// MaxLeaderboardRadius limite le nombre de voisins affichés de part et d'autre de l'utilisateur
+const MaxLeaderboardRadius = 25
+
+// LeaderboardPeriodFor calcule les bornes d'une période prédéfinie (mois et semaine calendaires en cours)
+func LeaderboardPeriodFor(name string, now time.Time) (models.LeaderboardPeriod, error) {
+	period := models.LeaderboardPeriod{Name: name}
+	year, month, day := now.Date()
+
+	switch name {
+	case LeaderboardAllTime:
+	case LeaderboardMonth:
+		period.From = time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+	case LeaderboardWeek:
+		// La semaine commence le lundi
+		offset := (int(now.Weekday()) + 6) % 7
+		period.From = time.Date(year, month, day-offset, 0, 0, 0, 0, now.Location())
+	default:
+		return period, errors.New("période invalide (all, month, week ou custom)")
+	}
+
+	return period, nil
+}
+
+// rankedUsersQuery classe tous les utilisateurs selon leurs points sur la période.
+// Les utilisateurs sans points sur la période sont ex aequo en fin de classement.
+// pos départage les ex aequo de façon stable pour la pagination et le voisinage.
+func rankedUsersQuery(period models.LeaderboardPeriod) (string, []interface{}) {
+	join := "LEFT JOIN eco_points p ON p.user_id = u.id AND p." + earnedPointsFilter
+	args := []interface{}{}
+
+	// Les dates sont stockées en UTC
+	if !period.From.IsZero() {
+		join += " AND p.date >= ?"
+		args = append(args, period.From.UTC())
+	}
+	if !period.To.IsZero() {
+		join += " AND p.date <= ?"
+		args = append(args, period.To.UTC())
+	}
+
+	return `
+		WITH ranked AS (
+			SELECT id, username, hide_from_leaderboard, points,
+			       RANK() OVER (ORDER BY points DESC) as ranking,
+			       ROW_NUMBER() OVER (ORDER BY points DESC, id ASC) as pos
+			FROM (
+				SELECT u.id, u.username, u.hide_from_leaderboard, COALESCE(SUM(p.points), 0) as points
+				FROM users u
+				` + join + `
+				GROUP BY u.id
+			) totals
+		)`, args
+}
+
+// scanLeaderboardEntries lit les lignes du classement en masquant le nom des utilisateurs
+// qui l'ont demandé, sauf pour eux-mêmes
+func scanLeaderboardEntries(rows *sql.Rows, viewerID int64) ([]models.LeaderboardEntry, error) {
+	entries := []models.LeaderboardEntry{}
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		if err := rows.Scan(&entry.Rank, &entry.UserID, &entry.Username, &entry.Hidden, &entry.Points); err != nil {
+			return nil, err
+		}
+
+		entry.IsMe = viewerID != 0 && entry.UserID == viewerID
+		if entry.Hidden && !entry.IsMe {
+			entry.UserID = 0
+			entry.Username = ""
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetLeaderboard récupère une page du classement des utilisateurs sur une période
+func GetLeaderboard(db *sql.DB, period models.LeaderboardPeriod, viewerID int64, page, pageSize int) ([]models.LeaderboardEntry, int, error) {
+	ranked, args := rankedUsersQuery(period)
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	rows, err := db.Query(ranked+`
+		SELECT ranking, id, username, hide_from_leaderboard, points
+		FROM ranked
+		ORDER BY pos
+		LIMIT ? OFFSET ?
+	`, append(args, pageSize, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries, err := scanLeaderboardEntries(rows, viewerID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// GetLeaderboardEntry récupère la position d'un utilisateur dans le classement d'une période
+func GetLeaderboardEntry(db *sql.DB, period models.LeaderboardPeriod, userID int64) (*models.LeaderboardEntry, error) {
+	ranked, args := rankedUsersQuery(period)
+
+	entry := &models.LeaderboardEntry{IsMe: true}
+	err := db.QueryRow(ranked+`
+		SELECT ranking, id, username, hide_from_leaderboard, points
+		FROM ranked
+		WHERE id = ?
+	`, append(args, userID)...).Scan(&entry.Rank, &entry.UserID, &entry.Username, &entry.Hidden, &entry.Points)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("utilisateur non trouvé")
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// GetLeaderboardNeighborhood récupère les utilisateurs classés juste avant et juste après un utilisateur
+func GetLeaderboardNeighborhood(db *sql.DB, period models.LeaderboardPeriod, userID int64, radius int) ([]models.LeaderboardEntry, error) {
+	ranked, args := rankedUsersQuery(period)
+
+	rows, err := db.Query(ranked+`,
+		me AS (SELECT pos FROM ranked WHERE id = ?)
+		SELECT ranking, id, username, hide_from_leaderboard, points
+		FROM ranked, me
+		WHERE ranked.pos BETWEEN me.pos - ? AND me.pos + ?
+		ORDER BY ranked.pos
+	`, append(args, userID, radius, radius)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries, err := scanLeaderboardEntries(rows, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("utilisateur non trouvé")
+	}
+
+	return entries, nil
+}
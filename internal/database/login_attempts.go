@@ -0,0 +1,288 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// Protection contre les essais de mots de passe: au-delà des échecs tolérés, chaque nouvel échec
+// verrouille le compte (ou l'adresse IP) pendant une durée qui double à chaque fois
+const (
+	LoginAccountFreeFailures = 5              // Échecs consécutifs tolérés par compte
+	LoginIPFreeFailures      = 20             // Échecs consécutifs tolérés par adresse IP
+	LoginLockoutBase         = time.Minute    // Durée du premier verrouillage
+	LoginLockoutMax          = time.Hour      // Durée maximale d'un verrouillage
+	LoginFailuresReset       = 24 * time.Hour // Les échecs plus anciens sont oubliés
+)
+
+// ReserveLoginAttempt autorise une tentative de connexion à un compte depuis une adresse IP.
+// Si le compte ou l'adresse IP est verrouillé, retourne la fin du verrouillage le plus long sans rien compter.
+// Sinon, la tentative est comptée comme un échec avant même la vérification du mot de passe, dans la
+// transaction qui a vérifié le verrouillage: des tentatives simultanées ne peuvent pas dépasser les échecs
+// tolérés. RecordLoginSuccess annule ce décompte si le mot de passe est correct.
+func ReserveLoginAttempt(db *sql.DB, email, ip string) (time.Time, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now()
+
+	// Supprimer les compteurs oubliés (les verrouillages sont plus courts que LoginFailuresReset)
+	_, err = tx.Exec("DELETE FROM login_throttles WHERE last_failure_at < ?", now.Add(-LoginFailuresReset))
+	if err != nil {
+		tx.Rollback()
+		return time.Time{}, err
+	}
+
+	accountKey, ipKey := loginThrottleKeys(email, ip)
+	lockout, err := loginLockoutTx(tx, accountKey, ipKey, now)
+	if err != nil {
+		tx.Rollback()
+		return time.Time{}, err
+	}
+
+	if !lockout.IsZero() {
+		tx.Rollback()
+		return lockout, nil
+	}
+
+	if err = addLoginFailureTx(tx, accountKey, LoginAccountFreeFailures, now); err != nil {
+		tx.Rollback()
+		return time.Time{}, err
+	}
+
+	if err = addLoginFailureTx(tx, ipKey, LoginIPFreeFailures, now); err != nil {
+		tx.Rollback()
+		return time.Time{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Time{}, nil
+}
+
+// RecordLoginFailure enregistre dans l'historique une connexion refusée, pour un mot de passe erroné
+// ou pendant un verrouillage (userID à 0 si l'adresse ne correspond à aucun compte).
+// L'échec a déjà été compté par ReserveLoginAttempt.
+func RecordLoginFailure(db *sql.DB, userID int64, email, ip, userAgent string) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = insertLoginAttemptTx(tx, userID, email, ip, userAgent, false, time.Now()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordLoginSuccess enregistre une connexion réussie et remet à zéro les échecs du compte.
+// Pour l'adresse IP, seule la tentative réservée par ReserveLoginAttempt est décomptée: se connecter
+// à son propre compte ne doit pas permettre de continuer à essayer les mots de passe des autres.
+func RecordLoginSuccess(db *sql.DB, userID int64, email, ip, userAgent string) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = insertLoginAttemptTx(tx, userID, email, ip, userAgent, true, time.Now()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	accountKey, ipKey := loginThrottleKeys(email, ip)
+	_, err = tx.Exec("DELETE FROM login_throttles WHERE throttle_key = ?", accountKey)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Le verrouillage posé par la tentative réservée est levé si les échecs restants sont tolérés
+	_, err = tx.Exec(`
+		UPDATE login_throttles
+		SET failures = failures - 1,
+		    locked_until = CASE WHEN failures - 1 <= ? THEN NULL ELSE locked_until END
+		WHERE throttle_key = ? AND failures > 0
+	`, LoginIPFreeFailures, ipKey)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnlockUserLogin lève le verrouillage du compte d'un utilisateur et remet à zéro ses échecs de connexion.
+// Les adresses IP d'où proviennent ses échecs récents sont aussi déverrouillées: sans cela, l'utilisateur
+// resterait bloqué si son adresse IP l'était en même temps que son compte.
+func UnlockUserLogin(db *sql.DB, userID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var email string
+	err = tx.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errors.New("utilisateur non trouvé")
+		}
+		return err
+	}
+
+	accountKey, _ := loginThrottleKeys(email, "")
+	_, err = tx.Exec("DELETE FROM login_throttles WHERE throttle_key = ?", accountKey)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM login_throttles
+		WHERE throttle_key IN (
+			SELECT 'ip:' || ip_address FROM login_attempts
+			WHERE (user_id = ? OR LOWER(TRIM(email)) = ?) AND success = 0 AND created_at >= ?
+		)
+	`, userID, strings.ToLower(strings.TrimSpace(email)), time.Now().Add(-LoginFailuresReset))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetLoginHistory récupère les connexions récentes d'un utilisateur, des plus récentes aux plus anciennes
+func GetLoginHistory(db *sql.DB, userID int64, page, pageSize int) ([]models.LoginAttempt, int, error) {
+	// Calculer l'offset pour la pagination
+	offset := (page - 1) * pageSize
+
+	var total int
+	err := db.QueryRow("SELECT COUNT(*) FROM login_attempts WHERE user_id = ?", userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, ip_address, user_agent, success, created_at
+		FROM login_attempts
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, userID, pageSize, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	attempts := []models.LoginAttempt{}
+	for rows.Next() {
+		var attempt models.LoginAttempt
+		if err := rows.Scan(&attempt.ID, &attempt.IPAddress, &attempt.UserAgent, &attempt.Success, &attempt.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return attempts, total, nil
+}
+
+// insertLoginAttemptTx ajoute une tentative de connexion à l'historique
+func insertLoginAttemptTx(tx *sql.Tx, userID int64, email, ip, userAgent string, success bool, now time.Time) error {
+	if len(userAgent) > MaxSessionUserAgent {
+		userAgent = userAgent[:MaxSessionUserAgent]
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO login_attempts (user_id, email, ip_address, user_agent, success, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		nullIfZero(userID), email, ip, userAgent, success, now,
+	)
+	return err
+}
+
+// loginLockoutTx retourne la fin du verrouillage le plus long encore en cours parmi des clés (zéro si aucune n'est verrouillée)
+func loginLockoutTx(tx *sql.Tx, accountKey, ipKey string, now time.Time) (time.Time, error) {
+	rows, err := tx.Query(
+		"SELECT locked_until FROM login_throttles WHERE throttle_key IN (?, ?) AND locked_until IS NOT NULL",
+		accountKey, ipKey,
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer rows.Close()
+
+	var lockout time.Time
+	for rows.Next() {
+		var lockedUntil time.Time
+		if err := rows.Scan(&lockedUntil); err != nil {
+			return time.Time{}, err
+		}
+		if lockedUntil.After(now) && lockedUntil.After(lockout) {
+			lockout = lockedUntil
+		}
+	}
+
+	return lockout, rows.Err()
+}
+
+// addLoginFailureTx compte un échec de connexion et verrouille la clé au-delà des échecs tolérés
+func addLoginFailureTx(tx *sql.Tx, key string, freeFailures int, now time.Time) error {
+	var failures int
+	var lastFailureAt time.Time
+	err := tx.QueryRow("SELECT failures, last_failure_at FROM login_throttles WHERE throttle_key = ?", key).Scan(&failures, &lastFailureAt)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	// Oublier les échecs anciens
+	if err == sql.ErrNoRows || now.Sub(lastFailureAt) > LoginFailuresReset {
+		failures = 0
+	}
+	failures++
+
+	// Verrouillage exponentiel: 1 min, 2 min, 4 min... jusqu'à LoginLockoutMax
+	var lockedUntil interface{}
+	if failures > freeFailures {
+		lockout := LoginLockoutMax
+		if exponent := failures - freeFailures - 1; exponent < 16 {
+			if d := LoginLockoutBase << uint(exponent); d < lockout {
+				lockout = d
+			}
+		}
+		lockedUntil = now.Add(lockout)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO login_throttles (throttle_key, failures, locked_until, last_failure_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(throttle_key) DO UPDATE SET
+			failures = excluded.failures,
+			locked_until = excluded.locked_until,
+			last_failure_at = excluded.last_failure_at
+	`, key, failures, lockedUntil, now)
+	return err
+}
+
+// loginThrottleKeys retourne les clés des échecs de connexion d'un compte et d'une adresse IP.
+// Le compte est désigné par son adresse, pour traiter de la même façon les adresses inconnues.
+func loginThrottleKeys(email, ip string) (string, string) {
+	return "email:" + strings.ToLower(strings.TrimSpace(email)), "ip:" + ip
+}
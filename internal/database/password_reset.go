@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"bdd-website/internal/utils"
+)
+
+// PasswordResetDelay est le délai minimum entre deux demandes de réinitialisation pour un même compte
+const PasswordResetDelay = time.Minute
+
+// ErrInvalidResetToken est retournée pour un lien de réinitialisation inconnu, expiré ou déjà utilisé
+var ErrInvalidResetToken = errors.New("lien de réinitialisation invalide ou expiré")
+
+// CreatePasswordResetToken émet un jeton de réinitialisation du mot de passe pour le compte d'une adresse email.
+// Les jetons précédents du compte sont invalidés; seule l'empreinte du jeton est conservée.
+// Retourne un jeton vide, sans erreur, si aucun compte n'utilise cette adresse ou si une demande
+// vient d'être faite (voir PasswordResetDelay).
+func CreatePasswordResetToken(db *sql.DB, email string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+
+	var userID int64
+	err = tx.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&userID)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	now := time.Now()
+
+	// Limiter la fréquence des demandes
+	var recent bool
+	err = tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM password_reset_tokens WHERE user_id = ? AND created_at > ?)",
+		userID, now.Add(-PasswordResetDelay),
+	).Scan(&recent)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if recent {
+		tx.Rollback()
+		return "", nil
+	}
+
+	// Invalider les jetons précédents
+	_, err = tx.Exec("DELETE FROM password_reset_tokens WHERE user_id = ?", userID)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		utils.HashToken(token), userID, now, now.Add(ttl),
+	)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ResetPassword remplace le mot de passe du compte d'un jeton de réinitialisation.
+// Le jeton est consommé, le compte déverrouillé et l'utilisateur déconnecté de tous ses appareils.
+func ResetPassword(db *sql.DB, token, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var tokenID, userID int64
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRow(
+		"SELECT id, user_id, expires_at, used_at FROM password_reset_tokens WHERE token_hash = ?",
+		utils.HashToken(token),
+	).Scan(&tokenID, &userID, &expiresAt, &usedAt)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	now := time.Now()
+	if usedAt.Valid || !now.Before(expiresAt) {
+		tx.Rollback()
+		return ErrInvalidResetToken
+	}
+
+	_, err = tx.Exec("UPDATE password_reset_tokens SET used_at = ? WHERE id = ?", now, tokenID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE users SET password_hash = ? WHERE id = ?", hashedPassword, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Lever le verrouillage du compte après des échecs de connexion
+	var email string
+	if err = tx.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	accountKey, _ := loginThrottleKeys(email, "")
+	_, err = tx.Exec("DELETE FROM login_throttles WHERE throttle_key = ?", accountKey)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Déconnecter les appareils ouverts avec l'ancien mot de passe
+	if err = revokeUserSessionsTx(tx, userID, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Natures des écritures du registre des points
+const (
+	PointsEarned     = "earned"     // Points gagnés (activité, défi)
+	PointsAdjustment = "adjustment" // Crédit ou débit manuel d'un administrateur
+	PointsReversal   = "reversal"   // Annulation d'une écriture précédente
+)
+
+// Limites des ajustements manuels
+const (
+	MaxPointsAdjustment = 10000
+	MaxAdjustmentReason = 500
+)
+
+// ValidateAdjustmentReason vérifie le motif obligatoire d'un ajustement ou d'une annulation
+func ValidateAdjustmentReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", errors.New("le motif est obligatoire")
+	}
+	if utf8.RuneCountInString(reason) > MaxAdjustmentReason {
+		return "", fmt.Errorf("le motif ne peut pas dépasser %d caractères", MaxAdjustmentReason)
+	}
+	return reason, nil
+}
+
+// AdjustEcoPoints crédite (points positifs) ou débite (points négatifs) manuellement un utilisateur.
+// L'ajustement est une nouvelle écriture du registre; un débit ne peut pas rendre le solde négatif.
+func AdjustEcoPoints(db *sql.DB, userID, adminID int64, points int, reason string) (int64, error) {
+	if points == 0 || points > MaxPointsAdjustment || points < -MaxPointsAdjustment {
+		return 0, fmt.Errorf("les points doivent être non nuls et compris entre -%d et %d", MaxPointsAdjustment, MaxPointsAdjustment)
+	}
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if !exists {
+		tx.Rollback()
+		return 0, errors.New("utilisateur non trouvé")
+	}
+
+	pointID, err := insertLedgerEntryTx(tx, userID, adminID, points, reason, PointsAdjustment, 0)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return pointID, nil
+}
+
+// ReverseEcoPoints annule une écriture par une écriture opposée, sans modifier l'écriture d'origine.
+// Une écriture ne peut être annulée qu'une fois et une annulation ne peut pas être annulée.
+func ReverseEcoPoints(db *sql.DB, pointID, adminID int64, reason string) (int64, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var userID int64
+	var points int
+	var kind string
+	var reversed bool
+	err = tx.QueryRow(`
+		SELECT user_id, points, kind, EXISTS(SELECT 1 FROM eco_points WHERE reverses_id = ep.id)
+		FROM eco_points ep
+		WHERE id = ?
+	`, pointID).Scan(&userID, &points, &kind, &reversed)
+
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return 0, errors.New("écriture non trouvée")
+		}
+		return 0, err
+	}
+
+	if kind == PointsReversal {
+		tx.Rollback()
+		return 0, errors.New("une annulation ne peut pas être annulée")
+	}
+
+	if kind == PointsRedemption || kind == PointsRefund {
+		tx.Rollback()
+		return 0, errors.New("un échange de récompense s'annule depuis la liste des échanges")
+	}
+
+	if reversed {
+		tx.Rollback()
+		return 0, errors.New("cette écriture a déjà été annulée")
+	}
+
+	reversalID, err := insertLedgerEntryTx(tx, userID, adminID, -points, reason, PointsReversal, pointID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return reversalID, nil
+}
+
+// insertLedgerEntryTx ajoute une écriture d'ajustement ou d'annulation au registre
+// et enregistre l'événement qui déclenchera la réévaluation des badges
+func insertLedgerEntryTx(tx *sql.Tx, userID, adminID int64, points int, reason, kind string, reversesID int64) (int64, error) {
+	// Un débit ne peut pas rendre le solde négatif
+	if points < 0 {
+		var balance int
+		err := tx.QueryRow("SELECT COALESCE(SUM(points), 0) FROM eco_points WHERE user_id = ?", userID).Scan(&balance)
+		if err != nil {
+			return 0, err
+		}
+
+		if balance+points < 0 {
+			return 0, fmt.Errorf("solde insuffisant: l'utilisateur n'a que %d points", balance)
+		}
+	}
+
+	// Une annulation reprend l'activité, le défi et la tentative de l'écriture annulée,
+	// pour être déduite des mêmes totaux (classements d'équipe, progression des défis)
+	var activityID, challengeID, attemptID sql.NullInt64
+	if reversesID != 0 {
+		err := tx.QueryRow(
+			"SELECT activity_id, challenge_id, attempt_id FROM eco_points WHERE id = ?",
+			reversesID,
+		).Scan(&activityID, &challengeID, &attemptID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO eco_points (user_id, activity_id, challenge_id, attempt_id, points, description, kind, created_by, reverses_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, activityID, challengeID, attemptID, points, reason, kind, nullIfZero(adminID), nullIfZero(reversesID))
+	if err != nil {
+		return 0, err
+	}
+
+	pointID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	err = recordEventTx(tx, EventPointsAdjusted, userID, map[string]interface{}{
+		"point_id":    pointID,
+		"points":      points,
+		"kind":        kind,
+		"reverses_id": reversesID,
+		"admin_id":    adminID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return pointID, nil
+}
@@ -0,0 +1,252 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
+)
+
+// Erreurs du renouvellement des jetons
+var (
+	ErrInvalidRefreshToken = errors.New("jeton de renouvellement invalide ou expiré")
+	ErrRefreshTokenReused  = errors.New("jeton de renouvellement déjà utilisé: la session a été révoquée, veuillez vous reconnecter")
+)
+
+// CreateRefreshToken émet le premier jeton de renouvellement d'une connexion (nouvelle famille).
+// Seule l'empreinte du jeton est conservée.
+func CreateRefreshToken(db *sql.DB, userID int64, userAgent string, ttl time.Duration) (string, error) {
+	familyID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	// Supprimer les jetons expirés de l'utilisateur
+	_, err = tx.Exec("DELETE FROM refresh_tokens WHERE user_id = ? AND expires_at <= ?", userID, now)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	token, err := insertRefreshTokenTx(tx, userID, familyID, 0, userAgent, now, ttl)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken échange un jeton de renouvellement contre un nouveau jeton de la même famille.
+// Un jeton ne sert qu'une fois: sa réutilisation (jeton volé ou rejoué) révoque toute la famille.
+// Retourne le nouveau jeton et l'utilisateur, avec son rôle actuel.
+func RotateRefreshToken(db *sql.DB, token, userAgent string, ttl time.Duration) (string, *models.User, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var tokenID, userID int64
+	var familyID string
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = ?
+	`, utils.HashToken(token)).Scan(&tokenID, &userID, &familyID, &expiresAt, &usedAt, &revokedAt)
+
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return "", nil, ErrInvalidRefreshToken
+		}
+		return "", nil, err
+	}
+
+	now := time.Now()
+
+	// Jeton déjà utilisé: révoquer toute la famille
+	if usedAt.Valid && !revokedAt.Valid {
+		if err = revokeRefreshTokenFamilyTx(tx, familyID, now); err != nil {
+			tx.Rollback()
+			return "", nil, err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return "", nil, err
+		}
+
+		log.Printf("Réutilisation d'un jeton de renouvellement de l'utilisateur %d: famille révoquée", userID)
+		return "", nil, ErrRefreshTokenReused
+	}
+
+	if revokedAt.Valid || !now.Before(expiresAt) {
+		tx.Rollback()
+		return "", nil, ErrInvalidRefreshToken
+	}
+
+	// Marquer le jeton comme utilisé et émettre son remplaçant
+	_, err = tx.Exec("UPDATE refresh_tokens SET used_at = ? WHERE id = ?", now, tokenID)
+	if err != nil {
+		tx.Rollback()
+		return "", nil, err
+	}
+
+	newToken, err := insertRefreshTokenTx(tx, userID, familyID, tokenID, userAgent, now, ttl)
+	if err != nil {
+		tx.Rollback()
+		return "", nil, err
+	}
+
+	user := &models.User{}
+	err = tx.QueryRow(
+		"SELECT id, email, username, password_hash, is_admin, created_at, email_verified_at IS NOT NULL FROM users WHERE id = ?",
+		userID,
+	).Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.IsAdmin, &user.CreatedAt, &user.EmailVerified)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return "", nil, ErrInvalidRefreshToken
+		}
+		return "", nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", nil, err
+	}
+
+	return newToken, user, nil
+}
+
+// RevokeRefreshToken révoque la famille d'un jeton de renouvellement (déconnexion de l'appareil).
+// Un jeton inconnu est ignoré.
+func RevokeRefreshToken(db *sql.DB, token string) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var familyID string
+	err = tx.QueryRow("SELECT family_id FROM refresh_tokens WHERE token_hash = ?", utils.HashToken(token)).Scan(&familyID)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	if err = revokeRefreshTokenFamilyTx(tx, familyID, time.Now()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeUserSessions déconnecte un utilisateur de tous ses appareils: les jetons d'accès déjà émis
+// sont refusés, les jetons de renouvellement révoqués et les sessions des navigateurs détruites
+func RevokeUserSessions(db *sql.DB, userID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = revokeUserSessionsTx(tx, userID, time.Now()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// revokeUserSessionsTx révoque les jetons d'accès, les jetons de renouvellement et les sessions d'un utilisateur
+func revokeUserSessionsTx(tx *sql.Tx, userID int64, now time.Time) error {
+	// La date est conservée à la sous-seconde, comme la date d'émission des jetons d'accès:
+	// un jeton émis juste après la révocation (reconnexion) reste valide
+	result, err := tx.Exec("UPDATE users SET tokens_revoked_at = ? WHERE id = ?", now, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("utilisateur non trouvé")
+	}
+
+	_, err = tx.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	return err
+}
+
+// GetUserTokenState récupère le rôle actuel d'un utilisateur et la date jusqu'à laquelle
+// ses jetons d'accès sont révoqués (zéro si aucune révocation)
+func GetUserTokenState(db *sql.DB, userID int64) (bool, time.Time, error) {
+	var isAdmin bool
+	var revokedAt sql.NullTime
+	err := db.QueryRow("SELECT is_admin, tokens_revoked_at FROM users WHERE id = ?", userID).Scan(&isAdmin, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, time.Time{}, errors.New("utilisateur non trouvé")
+		}
+		return false, time.Time{}, err
+	}
+
+	return isAdmin, revokedAt.Time, nil
+}
+
+// insertRefreshTokenTx enregistre un nouveau jeton de renouvellement et retourne sa valeur en clair
+func insertRefreshTokenTx(tx *sql.Tx, userID int64, familyID string, parentID int64, userAgent string, now time.Time, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if len(userAgent) > MaxSessionUserAgent {
+		userAgent = userAgent[:MaxSessionUserAgent]
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO refresh_tokens (token_hash, user_id, family_id, parent_id, user_agent, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		utils.HashToken(token), userID, familyID, nullIfZero(parentID), userAgent, now, now.Add(ttl),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// revokeRefreshTokenFamilyTx révoque tous les jetons d'une famille
+func revokeRefreshTokenFamilyTx(tx *sql.Tx, familyID string, now time.Time) error {
+	_, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", now, familyID)
+	return err
+}
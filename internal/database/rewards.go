@@ -0,0 +1,451 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
+)
+
+// Statuts des échanges de récompenses
+const (
+	RedemptionPending   = "pending"
+	RedemptionDelivered = "delivered"
+	RedemptionCancelled = "cancelled"
+)
+
+// Natures d'écriture propres aux récompenses
+const (
+	PointsRedemption = "redemption" // Débit lors de l'échange d'une récompense
+	PointsRefund     = "refund"     // Remboursement d'un échange annulé
+)
+
+// earnedPointsFilter exclut les points dépensés et remboursés en récompenses:
+// les classements et les badges mesurent les points gagnés, pas le solde
+const earnedPointsFilter = "kind NOT IN ('redemption', 'refund')"
+
+// redemptionCodeAttempts limite les tentatives de génération d'un code unique
+const redemptionCodeAttempts = 5
+
+// GetRewards récupère les récompenses du catalogue, éventuellement uniquement celles actives
+func GetRewards(db *sql.DB, activeOnly bool) ([]models.Reward, error) {
+	query := `
+		SELECT id, name, description, image_path, partner, cost, stock, is_active, created_at, updated_at
+		FROM rewards
+	`
+	if activeOnly {
+		query += " WHERE is_active = 1"
+	}
+	query += " ORDER BY cost ASC, name ASC"
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rewards := []models.Reward{}
+	for rows.Next() {
+		var reward models.Reward
+		var imagePath, partner sql.NullString
+
+		err := rows.Scan(
+			&reward.ID, &reward.Name, &reward.Description, &imagePath, &partner,
+			&reward.Cost, &reward.Stock, &reward.IsActive, &reward.CreatedAt, &reward.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		reward.ImagePath = imagePath.String
+		reward.Partner = partner.String
+		rewards = append(rewards, reward)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rewards, nil
+}
+
+// CreateReward ajoute une récompense au catalogue
+func CreateReward(db *sql.DB, reward models.RewardCreate) (int64, error) {
+	if err := checkRewardName(db, reward.Name, 0); err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec(
+		`INSERT INTO rewards (name, description, image_path, partner, cost, stock, is_active)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		reward.Name, reward.Description, reward.ImagePath, reward.Partner,
+		reward.Cost, reward.Stock, reward.IsActive,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// UpdateReward modifie une récompense du catalogue.
+// Le nouveau coût ne s'applique qu'aux échanges futurs.
+func UpdateReward(db *sql.DB, rewardID int64, reward models.RewardCreate) error {
+	if err := checkRewardName(db, reward.Name, rewardID); err != nil {
+		return err
+	}
+
+	result, err := db.Exec(
+		`UPDATE rewards
+		SET name = ?, description = ?, image_path = ?, partner = ?, cost = ?, stock = ?, is_active = ?, updated_at = ?
+		WHERE id = ?`,
+		reward.Name, reward.Description, reward.ImagePath, reward.Partner,
+		reward.Cost, reward.Stock, reward.IsActive, time.Now(), rewardID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("récompense non trouvée")
+	}
+
+	return nil
+}
+
+// DeleteReward supprime une récompense qui n'a jamais été échangée.
+// Une récompense déjà échangée doit être désactivée pour conserver l'historique.
+func DeleteReward(db *sql.DB, rewardID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var redeemed bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM reward_redemptions WHERE reward_id = ?)", rewardID).Scan(&redeemed)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if redeemed {
+		tx.Rollback()
+		return errors.New("cette récompense a déjà été échangée: désactivez-la plutôt")
+	}
+
+	result, err := tx.Exec("DELETE FROM rewards WHERE id = ?", rewardID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("récompense non trouvée")
+	}
+
+	return tx.Commit()
+}
+
+// checkRewardName vérifie qu'aucune autre récompense ne porte déjà ce nom
+func checkRewardName(db *sql.DB, name string, rewardID int64) error {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM rewards WHERE name = ? AND id != ?)", name, rewardID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.New("une récompense porte déjà ce nom")
+	}
+
+	return nil
+}
+
+// RedeemReward échange des points contre une récompense. Le stock, le solde, le débit
+// et le code de retrait sont vérifiés et enregistrés dans une même transaction
+// exclusive: deux échanges simultanés ne peuvent ni dépasser le stock ni le solde.
+func RedeemReward(db *sql.DB, userID, rewardID int64) (*models.RewardRedemption, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	redemption := &models.RewardRedemption{RewardID: rewardID, UserID: userID, Status: RedemptionPending}
+	var stock int
+	var isActive bool
+	err = tx.QueryRow(
+		"SELECT name, cost, stock, is_active FROM rewards WHERE id = ?",
+		rewardID,
+	).Scan(&redemption.RewardName, &redemption.Cost, &stock, &isActive)
+
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, errors.New("récompense non trouvée")
+		}
+		return nil, err
+	}
+
+	if !isActive {
+		tx.Rollback()
+		return nil, errors.New("cette récompense n'est plus disponible")
+	}
+
+	if stock <= 0 {
+		tx.Rollback()
+		return nil, errors.New("cette récompense est en rupture de stock")
+	}
+
+	// Vérifier le solde
+	var balance int
+	err = tx.QueryRow("SELECT COALESCE(SUM(points), 0) FROM eco_points WHERE user_id = ?", userID).Scan(&balance)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if balance < redemption.Cost {
+		tx.Rollback()
+		return nil, fmt.Errorf("solde insuffisant: cette récompense coûte %d points, vous en avez %d", redemption.Cost, balance)
+	}
+
+	// Décrémenter le stock
+	_, err = tx.Exec("UPDATE rewards SET stock = stock - 1 WHERE id = ?", rewardID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Débiter les points
+	result, err := tx.Exec(
+		"INSERT INTO eco_points (user_id, points, description, kind) VALUES (?, ?, ?, ?)",
+		userID, -redemption.Cost, "Échange : "+redemption.RewardName, PointsRedemption,
+	)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	pointID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Générer un code de retrait unique
+	for attempt := 1; ; attempt++ {
+		redemption.Code, err = utils.GenerateRedemptionCode()
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		var taken bool
+		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM reward_redemptions WHERE code = ?)", redemption.Code).Scan(&taken)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if !taken {
+			break
+		}
+
+		if attempt == redemptionCodeAttempts {
+			tx.Rollback()
+			return nil, errors.New("impossible de générer un code de retrait unique")
+		}
+	}
+
+	// Enregistrer l'échange
+	redemption.CreatedAt = time.Now()
+	result, err = tx.Exec(
+		`INSERT INTO reward_redemptions (reward_id, user_id, code, cost, status, eco_point_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		rewardID, userID, redemption.Code, redemption.Cost, RedemptionPending, pointID, redemption.CreatedAt,
+	)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	redemption.ID, err = result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return redemption, nil
+}
+
+// GetRewardRedemptions récupère les échanges de récompenses, filtrés par utilisateur (0 pour tous),
+// statut et code (vides pour ne pas filtrer)
+func GetRewardRedemptions(db *sql.DB, userID int64, status, code string) ([]models.RewardRedemption, error) {
+	query := `
+		SELECT rr.id, rr.reward_id, r.name, rr.user_id, u.username, rr.code, rr.cost, rr.status,
+		       rr.created_at, rr.handled_by, rr.handled_at
+		FROM reward_redemptions rr
+		JOIN rewards r ON rr.reward_id = r.id
+		JOIN users u ON rr.user_id = u.id
+		WHERE 1 = 1
+	`
+	args := []interface{}{}
+
+	if userID != 0 {
+		query += " AND rr.user_id = ?"
+		args = append(args, userID)
+	}
+	if status != "" {
+		query += " AND rr.status = ?"
+		args = append(args, status)
+	}
+	if code != "" {
+		query += " AND rr.code = ?"
+		args = append(args, strings.ToUpper(code))
+	}
+	query += " ORDER BY rr.created_at DESC, rr.id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	redemptions := []models.RewardRedemption{}
+	for rows.Next() {
+		var redemption models.RewardRedemption
+		var handledBy sql.NullInt64
+		var handledAt sql.NullTime
+
+		err := rows.Scan(
+			&redemption.ID, &redemption.RewardID, &redemption.RewardName, &redemption.UserID, &redemption.Username,
+			&redemption.Code, &redemption.Cost, &redemption.Status, &redemption.CreatedAt, &handledBy, &handledAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		redemption.HandledBy = handledBy.Int64
+		if handledAt.Valid {
+			redemption.HandledAt = handledAt.Time
+		}
+
+		redemptions = append(redemptions, redemption)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return redemptions, nil
+}
+
+// DeliverRewardRedemption marque un échange comme remis à l'utilisateur
+func DeliverRewardRedemption(db *sql.DB, redemptionID, adminID int64) error {
+	result, err := db.Exec(
+		"UPDATE reward_redemptions SET status = ?, handled_by = ?, handled_at = ? WHERE id = ? AND status = ?",
+		RedemptionDelivered, adminID, time.Now(), redemptionID, RedemptionPending,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("échange non trouvé ou déjà traité")
+	}
+
+	return nil
+}
+
+// CancelRewardRedemption annule un échange non remis: les points sont remboursés
+// par une écriture opposée et la récompense est remise en stock
+func CancelRewardRedemption(db *sql.DB, redemptionID, adminID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var rewardID, userID, pointID int64
+	var cost int
+	var status, rewardName string
+	err = tx.QueryRow(`
+		SELECT rr.reward_id, rr.user_id, rr.eco_point_id, rr.cost, rr.status, r.name
+		FROM reward_redemptions rr
+		JOIN rewards r ON rr.reward_id = r.id
+		WHERE rr.id = ?
+	`, redemptionID).Scan(&rewardID, &userID, &pointID, &cost, &status, &rewardName)
+
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errors.New("échange non trouvé")
+		}
+		return err
+	}
+
+	if status != RedemptionPending {
+		tx.Rollback()
+		return errors.New("seul un échange en attente de remise peut être annulé")
+	}
+
+	// Rembourser les points
+	result, err := tx.Exec(
+		"INSERT INTO eco_points (user_id, points, description, kind, created_by, reverses_id) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, cost, "Remboursement : "+rewardName, PointsRefund, adminID, pointID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	refundID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Remettre la récompense en stock
+	if _, err := tx.Exec("UPDATE rewards SET stock = stock + 1 WHERE id = ?", rewardID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE reward_redemptions SET status = ?, refund_point_id = ?, handled_by = ?, handled_at = ? WHERE id = ?",
+		RedemptionCancelled, refundID, adminID, time.Now(), redemptionID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// addedColumn décrit une colonne ajoutée à une table qui existait dans une version précédente
+type addedColumn struct {
+	table      string
+	column     string
+	definition string // Définition compatible avec ALTER TABLE ADD COLUMN
+	uniqueIdx  string // Index unique remplaçant la contrainte UNIQUE, impossible à ajouter avec ALTER TABLE
+}
+
+// addedColumns liste les colonnes ajoutées aux tables existantes, dans l'ordre de leur introduction.
+// Les nouvelles tables sont créées par le schéma (migrations/init.sql), idempotent.
+var addedColumns = []addedColumn{
+	{table: "users", column: "calendar_token", definition: "TEXT", uniqueIdx: "idx_users_calendar_token"},
+	{table: "users", column: "hide_from_leaderboard", definition: "BOOLEAN NOT NULL DEFAULT 0"},
+	{table: "users", column: "tokens_revoked_at", definition: "TIMESTAMP"},
+	{table: "activities", column: "latitude", definition: "REAL"},
+	{table: "activities", column: "longitude", definition: "REAL"},
+	{table: "activities", column: "category", definition: "TEXT NOT NULL DEFAULT 'other'"},
+	{table: "activities", column: "series_id", definition: "INTEGER REFERENCES activity_series(id) ON DELETE SET NULL"},
+	{table: "activities", column: "status", definition: "TEXT NOT NULL DEFAULT 'scheduled'"},
+	{table: "activities", column: "revision", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{table: "eco_challenges", column: "requires_daily_checkin", definition: "BOOLEAN NOT NULL DEFAULT 0"},
+	{table: "eco_challenges", column: "is_team_challenge", definition: "BOOLEAN NOT NULL DEFAULT 0"},
+	{table: "eco_points", column: "attempt_id", definition: "INTEGER REFERENCES challenge_attempts(id) ON DELETE SET NULL"},
+	{table: "eco_points", column: "kind", definition: "TEXT NOT NULL DEFAULT 'earned'"},
+	{table: "eco_points", column: "created_by", definition: "INTEGER REFERENCES users(id) ON DELETE SET NULL"},
+	{table: "eco_points", column: "reverses_id", definition: "INTEGER REFERENCES eco_points(id)", uniqueIdx: "idx_eco_points_reverses"},
+}
+
+// upgradeSchema met à jour le schéma d'une base créée par une version précédente du site.
+// Exécuté à chaque démarrage: chaque étape vérifie d'abord si elle est nécessaire.
+// Les colonnes manquantes sont ajoutées avant d'exécuter le schéma, dont les index en dépendent.
+func upgradeSchema(db *sql.DB) error {
+	schemaSQL, err := os.ReadFile(filepath.Join(MigrationsDir, schemaFile))
+	if err != nil {
+		return fmt.Errorf("impossible de lire le fichier de migration: %v", err)
+	}
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = upgradeEmailVerificationTx(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("mise à jour de la confirmation des emails: %v", err)
+	}
+
+	if err = addMissingColumnsTx(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("ajout des colonnes manquantes: %v", err)
+	}
+
+	if err = renameLegacyChallengeParticipantsTx(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("mise à jour des participations aux défis: %v", err)
+	}
+
+	// Créer les tables, index et triggers manquants
+	if _, err = tx.Exec(string(schemaSQL)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("création des tables manquantes: %v", err)
+	}
+
+	if err = upgradeChallengeAttemptsTx(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("mise à jour des participations aux défis: %v", err)
+	}
+
+	if err = upgradeBadgeRulesTx(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("mise à jour des badges: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// addMissingColumnsTx ajoute les colonnes de addedColumns absentes de la base
+func addMissingColumnsTx(tx *sql.Tx) error {
+	for _, c := range addedColumns {
+		exists, err := columnExistsTx(tx, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition))
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", c.table, c.column, err)
+		}
+
+		if c.uniqueIdx != "" {
+			_, err = tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s(%s)", c.uniqueIdx, c.table, c.column))
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", c.table, c.column, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// legacyChallengeParticipants reçoit les participations aux défis d'une version précédente
+// (une ligne par utilisateur et par défi, avec son statut) le temps de les convertir en tentatives
+const legacyChallengeParticipants = "challenge_participants_legacy"
+
+// renameLegacyChallengeParticipantsTx met de côté l'ancienne table des participations,
+// pour que le schéma crée la table des tentatives et la nouvelle table des participations
+func renameLegacyChallengeParticipantsTx(tx *sql.Tx) error {
+	exists, err := columnExistsTx(tx, "challenge_participants", "status")
+	if err != nil || !exists {
+		return err
+	}
+
+	_, err = tx.Exec("ALTER TABLE challenge_participants RENAME TO " + legacyChallengeParticipants)
+	return err
+}
+
+// upgradeChallengeAttemptsTx convertit chaque ancienne participation en première tentative,
+// pointée par la nouvelle table des participations, puis supprime l'ancienne table.
+// Les points déjà gagnés sur un défi sont rattachés à cette tentative.
+func upgradeChallengeAttemptsTx(tx *sql.Tx) error {
+	exists, err := tableExistsTx(tx, legacyChallengeParticipants)
+	if err != nil || !exists {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO challenge_attempts (user_id, challenge_id, attempt, status, joined_at, completed_at, abandoned_at)
+		SELECT user_id, challenge_id, 1, status, joined_at, completed_at,
+		       CASE WHEN status = 'abandoned' THEN COALESCE(completed_at, joined_at) END
+		FROM ` + legacyChallengeParticipants + `
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO challenge_participants (user_id, challenge_id, attempt_id)
+		SELECT user_id, challenge_id, id FROM challenge_attempts
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE eco_points SET attempt_id = (
+			SELECT a.id FROM challenge_attempts a
+			WHERE a.user_id = eco_points.user_id AND a.challenge_id = eco_points.challenge_id
+		)
+		WHERE attempt_id IS NULL AND challenge_id IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DROP TABLE " + legacyChallengeParticipants)
+	return err
+}
+
+// upgradeBadgeRulesTx remplace le seuil de points des badges (colonne required_points)
+// par un critère d'attribution équivalent, puis supprime la colonne.
+// La table badge_rules est créée par le schéma.
+func upgradeBadgeRulesTx(tx *sql.Tx) error {
+	exists, err := columnExistsTx(tx, "badges", "required_points")
+	if err != nil || !exists {
+		return err
+	}
+
+	// Les badges sans critère restent attribués à partir du même nombre de points
+	_, err = tx.Exec(`
+		INSERT INTO badge_rules (badge_id, rule_type, threshold)
+		SELECT b.id, ?, b.required_points FROM badges b
+		WHERE NOT EXISTS (SELECT 1 FROM badge_rules r WHERE r.badge_id = b.id)
+	`, BadgeRuleTotalPoints)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("ALTER TABLE badges DROP COLUMN required_points")
+	return err
+}
+
+// upgradeEmailVerificationTx ajoute la confirmation des adresses email aux comptes.
+// Les comptes créés avant son introduction sont considérés comme confirmés.
+func upgradeEmailVerificationTx(tx *sql.Tx) error {
+	exists, err := columnExistsTx(tx, "users", "email_verified_at")
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = tx.Exec("ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("ALTER TABLE users ADD COLUMN verification_sent_at TIMESTAMP")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP")
+	return err
+}
+
+// tableExistsTx vérifie qu'une table existe
+func tableExistsTx(tx *sql.Tx, table string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)",
+		table,
+	).Scan(&exists)
+	return exists, err
+}
+
+// columnExistsTx vérifie qu'une table possède une colonne
+func columnExistsTx(tx *sql.Tx, table, column string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM pragma_table_info(?) WHERE name = ?)",
+		table, column,
+	).Scan(&exists)
+	return exists, err
+}
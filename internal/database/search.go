@@ -2,71 +2,237 @@ package database
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"unicode"
 
 	"bdd-website/internal/models"
 )
 
-// SearchUsers recherche des utilisateurs basé sur un terme de recherche
-func SearchUsers(db *sql.DB, query string, limit int) ([]models.UserProfile, error) {
-	// Préparer le terme de recherche
-	searchTerm := "%" + strings.ToLower(query) + "%"
-
-	// Effectuer la recherche
-	rows, err := db.Query(`
-		SELECT u.id, u.email, u.username, u.is_admin, u.created_at,
-		       COALESCE((SELECT SUM(points) FROM eco_points WHERE user_id = u.id), 0) as total_points,
-		       (SELECT COUNT(*) FROM registrations WHERE user_id = u.id) as activity_count,
-		       (SELECT COUNT(*) FROM user_badges WHERE user_id = u.id) as badge_count
-		FROM users u
-		WHERE LOWER(u.username) LIKE ? OR LOWER(u.email) LIKE ?
-		ORDER BY 
-			CASE 
-				WHEN LOWER(u.username) = LOWER(?) THEN 0
-				WHEN LOWER(u.email) = LOWER(?) THEN 0
-				WHEN LOWER(u.username) LIKE ? THEN 1
-				WHEN LOWER(u.email) LIKE ? THEN 1
-				ELSE 2
-			END,
-			u.created_at DESC
-		LIMIT ?
-	`, searchTerm, searchTerm, query, query, searchTerm, searchTerm, limit)
+// Types de résultats de la recherche plein texte
+const (
+	SearchTypeActivity  = "activity"
+	SearchTypeChallenge = "challenge"
+	SearchTypeMessage   = "message"
+)
+
+// ErrSearchUnavailable est retournée lorsque SQLite a été compilé sans FTS5
+var ErrSearchUnavailable = errors.New("recherche plein texte indisponible: compiler avec -tags sqlite_fts5")
+
+// ErrInvalidSearchQuery est retournée lorsque la saisie ne contient aucun mot recherchable
+var ErrInvalidSearchQuery = errors.New("terme de recherche invalide")
+
+// searchIndexFile contient la création des index FTS5 et des triggers de synchronisation
+const searchIndexFile = "search_fts5.sql"
+
+// searchAvailable indique si les index FTS5 ont pu être créés au démarrage
+var searchAvailable bool
+
+// SearchAvailable indique si la recherche plein texte est disponible (binaire compilé avec FTS5)
+func SearchAvailable() bool {
+	return searchAvailable
+}
+
+// Marqueurs de surlignage utilisés par FTS5, remplacés par <mark> après échappement HTML
+const (
+	highlightStart = "\x02"
+	highlightEnd   = "\x03"
+)
+
+// searchTriggers liste les triggers de synchronisation des index FTS5
+var searchTriggers = []string{
+	"activities_fts_insert", "activities_fts_delete", "activities_fts_update",
+	"eco_challenges_fts_insert", "eco_challenges_fts_delete", "eco_challenges_fts_update",
+	"contact_messages_fts_insert", "contact_messages_fts_delete", "contact_messages_fts_update",
+}
+
+// initSearchIndex crée (si nécessaire) et reconstruit les index de recherche plein texte.
+// Si SQLite ne dispose pas du module FTS5, les triggers éventuellement créés par un binaire
+// précédent sont supprimés pour ne pas bloquer les écritures, et la recherche est désactivée.
+func initSearchIndex(db *sql.DB) error {
+	indexSQL, err := os.ReadFile(filepath.Join(MigrationsDir, searchIndexFile))
+	if err != nil {
+		return fmt.Errorf("impossible de lire le fichier d'index de recherche: %v", err)
+	}
 
+	tx, err := db.Begin()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if _, err := tx.Exec(string(indexSQL)); err != nil {
+		tx.Rollback()
+
+		if !strings.Contains(err.Error(), "no such module: fts5") {
+			return fmt.Errorf("erreur lors de la création des index de recherche: %v", err)
+		}
+
+		// FTS5 indisponible: supprimer les triggers qui feraient échouer les écritures
+		for _, trigger := range searchTriggers {
+			if _, err := db.Exec("DROP TRIGGER IF EXISTS " + trigger); err != nil {
+				return err
+			}
+		}
+
+		searchAvailable = false
+		return ErrSearchUnavailable
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	searchAvailable = true
+	return nil
+}
+
+// searchSource décrit la requête FTS5 d'un type de résultat
+type searchSource struct {
+	resultType string
+	query      string
+}
+
+// searchSources associe chaque type de résultat à sa requête.
+// Colonnes: id, titre surligné, extrait, score bm25 (négatif, plus petit = plus pertinent), date.
+var searchSources = map[string]searchSource{
+	SearchTypeActivity: {
+		resultType: SearchTypeActivity,
+		query: `
+			SELECT a.id,
+			       highlight(activities_fts, 0, char(2), char(3)),
+			       snippet(activities_fts, 1, char(2), char(3), '…', 16),
+			       bm25(activities_fts, 10.0, 1.0) as score,
+			       a.start_date
+			FROM activities_fts
+			JOIN activities a ON a.id = activities_fts.rowid
+			WHERE activities_fts MATCH ?
+			ORDER BY score
+			LIMIT ?`,
+	},
+	SearchTypeChallenge: {
+		resultType: SearchTypeChallenge,
+		query: `
+			SELECT c.id,
+			       highlight(eco_challenges_fts, 0, char(2), char(3)),
+			       snippet(eco_challenges_fts, 1, char(2), char(3), '…', 16),
+			       bm25(eco_challenges_fts, 10.0, 1.0) as score,
+			       c.created_at
+			FROM eco_challenges_fts
+			JOIN eco_challenges c ON c.id = eco_challenges_fts.rowid
+			WHERE eco_challenges_fts MATCH ? AND (c.is_active = 1 OR ?)
+			ORDER BY score
+			LIMIT ?`,
+	},
+	SearchTypeMessage: {
+		resultType: SearchTypeMessage,
+		query: `
+			SELECT m.id,
+			       highlight(contact_messages_fts, 0, char(2), char(3)),
+			       snippet(contact_messages_fts, 1, char(2), char(3), '…', 16),
+			       bm25(contact_messages_fts, 5.0, 1.0, 2.0, 2.0) as score,
+			       m.submitted_at
+			FROM contact_messages_fts
+			JOIN contact_messages m ON m.id = contact_messages_fts.rowid
+			WHERE contact_messages_fts MATCH ?
+			ORDER BY score
+			LIMIT ?`,
+	},
+}
+
+// Search effectue une recherche plein texte classée par pertinence sur les types demandés.
+// Les messages de contact et les défis inactifs ne sont accessibles qu'aux administrateurs.
+func Search(db *sql.DB, query string, types []string, isAdmin bool, limit int) ([]models.SearchResult, error) {
+	if !searchAvailable {
+		return nil, ErrSearchUnavailable
+	}
+
+	// Construire la requête FTS5 à partir des mots saisis
+	matchQuery := buildMatchQuery(query)
+	if matchQuery == "" {
+		return nil, ErrInvalidSearchQuery
 	}
-	defer rows.Close()
-
-	users := []models.UserProfile{}
-	for rows.Next() {
-		var user models.UserProfile
-		var createdAt sql.NullTime
-
-		err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.Username,
-			&user.IsAdmin,
-			&createdAt,
-			&user.TotalEcoPoints,
-			&user.ActivityCount,
-			&user.BadgeCount,
-		)
 
+	results := []models.SearchResult{}
+	for _, searchType := range types {
+		source, ok := searchSources[searchType]
+		if !ok {
+			return nil, fmt.Errorf("type de recherche inconnu: %s", searchType)
+		}
+
+		if searchType == SearchTypeMessage && !isAdmin {
+			continue
+		}
+
+		// Préparer les arguments
+		args := []interface{}{matchQuery}
+		if searchType == SearchTypeChallenge {
+			args = append(args, isAdmin)
+		}
+		args = append(args, limit)
+
+		rows, err := db.Query(source.query, args...)
 		if err != nil {
 			return nil, err
 		}
 
-		if createdAt.Valid {
-			user.CreatedAt = createdAt.Time
+		for rows.Next() {
+			result := models.SearchResult{Type: source.resultType}
+			var score float64
+
+			if err := rows.Scan(&result.ID, &result.Title, &result.Snippet, &score, &result.Date); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			result.Title = formatHighlight(result.Title)
+			result.Snippet = formatHighlight(result.Snippet)
+			result.Score = -score
+
+			results = append(results, result)
 		}
+		rows.Close()
 
-		users = append(users, user)
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	// Fusionner les résultats des différents types par pertinence
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
 	}
 
-	return users, nil
+	return results, nil
+}
+
+// buildMatchQuery transforme la saisie utilisateur en requête FTS5 sûre:
+// chaque mot devient un préfixe entre guillemets, et tous les mots doivent être présents.
+// La syntaxe FTS5 (opérateurs, colonnes, parenthèses) n'est donc jamais interprétée.
+func buildMatchQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+word+`"*`)
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// formatHighlight échappe le texte pour l'affichage HTML puis remplace les marqueurs FTS5 par des balises <mark>
+func formatHighlight(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, highlightStart, "<mark>")
+	return strings.ReplaceAll(text, highlightEnd, "</mark>")
 }
@@ -0,0 +1,401 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
+)
+
+// CreateActivitySeries crée une série récurrente et génère une activité par occurrence
+func CreateActivitySeries(db *sql.DB, series models.ActivitySeriesCreate) (int64, error) {
+	// Analyser la règle de récurrence
+	recurrence, err := utils.ParseRRule(series.RRule)
+	if err != nil {
+		return 0, err
+	}
+
+	// Calculer les dates des occurrences dans le fuseau local: chaque occurrence garde
+	// l'heure de début de la première, y compris après un changement d'heure
+	occurrences, err := recurrence.Occurrences(series.StartDate.In(time.Local))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(occurrences) == 0 {
+		return 0, errors.New("la règle de récurrence ne produit aucune occurrence")
+	}
+
+	endDays := localDaysBetween(series.StartDate, series.EndDate)
+
+	// Créer la série et ses occurrences dans une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO activity_series (title, rrule) VALUES (?, ?)",
+		series.Title, recurrence.String(),
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	seriesID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, start := range occurrences {
+		result, err = tx.Exec(
+			`INSERT INTO activities
+			(title, description, image_path, start_date, end_date, location, latitude, longitude, max_participants, eco_points, category, series_id, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			series.Title, series.Description, series.ImagePath,
+			start, atLocalTime(start, endDays, series.EndDate), series.Location, series.Latitude, series.Longitude,
+			series.MaxParticipants, series.EcoPoints, series.Category, seriesID, now,
+		)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		activityID, err := result.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		if err = setActivityTagsTx(tx, activityID, series.Tags); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		if err = setImpactFactorsTx(tx, "activity_id", activityID, series.Impact); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return seriesID, nil
+}
+
+// GetActivitySeries récupère une série récurrente et ses occurrences
+func GetActivitySeries(db *sql.DB, seriesID int64) (*models.ActivitySeries, error) {
+	series := &models.ActivitySeries{}
+
+	err := db.QueryRow(
+		"SELECT id, title, rrule, is_cancelled, created_at, updated_at FROM activity_series WHERE id = ?",
+		seriesID,
+	).Scan(&series.ID, &series.Title, &series.RRule, &series.IsCancelled, &series.CreatedAt, &series.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("série non trouvée")
+		}
+		return nil, err
+	}
+
+	// Récupérer les identifiants des occurrences
+	rows, err := db.Query("SELECT id FROM activities WHERE series_id = ? ORDER BY start_date ASC", seriesID)
+	if err != nil {
+		return nil, err
+	}
+
+	var activityIDs []int64
+	for rows.Next() {
+		var activityID int64
+		if err := rows.Scan(&activityID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		activityIDs = append(activityIDs, activityID)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Récupérer le détail de chaque occurrence
+	series.Occurrences = []models.Activity{}
+	for _, activityID := range activityIDs {
+		activity, err := GetActivity(db, activityID, 0)
+		if err != nil {
+			return nil, err
+		}
+		series.Occurrences = append(series.Occurrences, *activity)
+	}
+
+	return series, nil
+}
+
+// UpdateActivityAndFollowing met à jour une occurrence et toutes les occurrences suivantes de sa série.
+// Le décalage de jours appliqué à l'occurrence est reporté sur les suivantes, qui prennent ses nouvelles
+// heures de début et de fin (heure locale, y compris de l'autre côté d'un changement d'heure).
+func UpdateActivityAndFollowing(db *sql.DB, activityID int64, activity models.ActivityUpdate) error {
+	// Récupérer l'occurrence d'origine
+	var seriesID sql.NullInt64
+	var originalStart time.Time
+
+	err := db.QueryRow(
+		"SELECT series_id, start_date FROM activities WHERE id = ?",
+		activityID,
+	).Scan(&seriesID, &originalStart)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("activité non trouvée")
+		}
+		return err
+	}
+
+	if !seriesID.Valid {
+		return errors.New("cette activité n'appartient à aucune série")
+	}
+
+	dayShift := localDaysBetween(originalStart, activity.StartDate)
+	endDays := localDaysBetween(activity.StartDate, activity.EndDate)
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Récupérer les occurrences concernées (celle-ci et les suivantes, non annulées)
+	rows, err := tx.Query(
+		"SELECT id, start_date FROM activities WHERE series_id = ? AND start_date >= ? AND status = ?",
+		seriesID.Int64, originalStart, ActivityScheduled,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	type occurrence struct {
+		id    int64
+		start time.Time
+	}
+
+	var occurrences []occurrence
+	for rows.Next() {
+		var o occurrence
+		if err := rows.Scan(&o.id, &o.start); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		occurrences = append(occurrences, o)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Mettre à jour chaque occurrence
+	now := time.Now()
+	for _, o := range occurrences {
+		start := atLocalTime(o.start, dayShift, activity.StartDate)
+
+		_, err = tx.Exec(
+			`UPDATE activities
+			SET title = ?, description = ?, image_path = ?,
+			    start_date = ?, end_date = ?, location = ?, latitude = ?, longitude = ?,
+			    max_participants = ?, eco_points = ?, category = ?, revision = revision + 1, updated_at = ?
+			WHERE id = ?`,
+			activity.Title, activity.Description, activity.ImagePath,
+			start, atLocalTime(start, endDays, activity.EndDate), activity.Location, activity.Latitude, activity.Longitude,
+			activity.MaxParticipants, activity.EcoPoints, activity.Category, now,
+			o.id,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if activity.Tags != nil {
+			if err = setActivityTagsTx(tx, o.id, *activity.Tags); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if activity.Impact != nil {
+			if err = setImpactFactorsTx(tx, "activity_id", o.id, *activity.Impact); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		// Promouvoir la liste d'attente si des places se sont libérées
+		if _, err = promoteFromWaitlistTx(tx, o.id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Mettre à jour la série
+	_, err = tx.Exec(
+		"UPDATE activity_series SET title = ?, updated_at = ? WHERE id = ?",
+		activity.Title, now, seriesID.Int64,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CancelActivitySeries annule une série et toutes ses occurrences à venir.
+// Les occurrences passées sont conservées. Retourne le nombre d'occurrences annulées
+// et les inscriptions à prévenir.
+func CancelActivitySeries(db *sql.DB, seriesID int64) (int64, []models.CancelledRegistration, error) {
+	// Vérifier si la série existe
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM activity_series WHERE id = ?)", seriesID).Scan(&exists)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if !exists {
+		return 0, nil, errors.New("série non trouvée")
+	}
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := time.Now()
+
+	// Annuler la série
+	_, err = tx.Exec(
+		"UPDATE activity_series SET is_cancelled = 1, updated_at = ? WHERE id = ?",
+		now, seriesID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+
+	// Récupérer les occurrences à venir
+	rows, err := tx.Query(
+		"SELECT id FROM activities WHERE series_id = ? AND start_date > ? AND status != ?",
+		seriesID, now, ActivityCancelled,
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+
+	var activityIDs []int64
+	for rows.Next() {
+		var activityID int64
+		if err := rows.Scan(&activityID); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return 0, nil, err
+		}
+		activityIDs = append(activityIDs, activityID)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+
+	// Annuler chaque occurrence
+	registrations := []models.CancelledRegistration{}
+	for _, activityID := range activityIDs {
+		cancelled, err := cancelActivityTx(tx, activityID, now)
+		if err != nil {
+			tx.Rollback()
+			return 0, nil, err
+		}
+		registrations = append(registrations, cancelled...)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+
+	return int64(len(activityIDs)), registrations, nil
+}
+
+// cancelActivityTx annule une activité et vide sa liste d'attente.
+// Les inscriptions sont conservées (elles apparaissent comme annulées dans l'historique et
+// les agendas des inscrits) et retournées pour que les inscrits soient prévenus.
+func cancelActivityTx(tx *sql.Tx, activityID int64, now time.Time) ([]models.CancelledRegistration, error) {
+	_, err := tx.Exec(
+		"UPDATE activities SET status = ?, revision = revision + 1, updated_at = ? WHERE id = ?",
+		ActivityCancelled, now, activityID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM waitlist WHERE activity_id = ?", activityID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT u.email, u.username, a.id, a.title, a.start_date
+		FROM registrations r
+		JOIN users u ON r.user_id = u.id
+		JOIN activities a ON r.activity_id = a.id
+		WHERE r.activity_id = ?
+	`, activityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	registrations := []models.CancelledRegistration{}
+	for rows.Next() {
+		var registration models.CancelledRegistration
+		err := rows.Scan(
+			&registration.Email, &registration.Username,
+			&registration.ActivityID, &registration.Title, &registration.StartDate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, registration)
+	}
+
+	return registrations, rows.Err()
+}
+
+// localDaysBetween compte les jours du calendrier local séparant deux dates
+func localDaysBetween(from, to time.Time) int {
+	from, to = from.In(time.Local), to.In(time.Local)
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDay.Sub(fromDay).Hours() / 24)
+}
+
+// atLocalTime place une date, décalée de days jours du calendrier local, à l'heure locale de clock
+func atLocalTime(date time.Time, days int, clock time.Time) time.Time {
+	date, clock = date.In(time.Local), clock.In(time.Local)
+	return time.Date(
+		date.Year(), date.Month(), date.Day()+days,
+		clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(), time.Local,
+	)
+}
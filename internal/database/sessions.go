@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
+)
+
+// MaxSessionUserAgent limite la taille du navigateur enregistré avec une session
+const MaxSessionUserAgent = 255
+
+// CreateSession ouvre une session de navigateur pour un utilisateur.
+// Retourne le jeton du cookie, dont seule l'empreinte est conservée, et la session créée.
+func CreateSession(db *sql.DB, userID int64, userAgent string, ttl time.Duration) (string, *models.Session, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	csrfToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(userAgent) > MaxSessionUserAgent {
+		userAgent = userAgent[:MaxSessionUserAgent]
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		UserID:    userID,
+		CSRFToken: csrfToken,
+		UserAgent: userAgent,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Supprimer les sessions expirées de l'utilisateur
+	_, err = tx.Exec("DELETE FROM sessions WHERE user_id = ? AND expires_at <= ?", userID, now)
+	if err != nil {
+		tx.Rollback()
+		return "", nil, err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO sessions (token_hash, user_id, csrf_token, user_agent, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		utils.HashToken(token), userID, csrfToken, userAgent, session.CreatedAt, session.ExpiresAt,
+	)
+	if err != nil {
+		tx.Rollback()
+		return "", nil, err
+	}
+
+	session.ID, err = result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return "", nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", nil, err
+	}
+
+	return token, session, nil
+}
+
+// GetSession retrouve une session valide à partir du jeton de son cookie.
+// Le rôle administrateur est lu sur l'utilisateur, pour prendre effet sans reconnexion.
+func GetSession(db *sql.DB, token string) (*models.Session, error) {
+	session := &models.Session{}
+	err := db.QueryRow(`
+		SELECT s.id, s.user_id, u.is_admin, s.csrf_token, s.user_agent, s.created_at, s.expires_at
+		FROM sessions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.token_hash = ?
+	`, utils.HashToken(token)).Scan(
+		&session.ID, &session.UserID, &session.IsAdmin, &session.CSRFToken,
+		&session.UserAgent, &session.CreatedAt, &session.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("session non trouvée")
+		}
+		return nil, err
+	}
+
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, errors.New("session expirée")
+	}
+
+	return session, nil
+}
+
+// DeleteSession détruit la session correspondant au jeton d'un cookie
+func DeleteSession(db *sql.DB, token string) error {
+	_, err := db.Exec("DELETE FROM sessions WHERE token_hash = ?", utils.HashToken(token))
+	return err
+}
@@ -0,0 +1,534 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// Rôles des membres d'une équipe
+const (
+	TeamRoleCaptain = "captain"
+	TeamRoleMember  = "member"
+)
+
+// teamPointsExpr calcule la somme des points écologiques des membres actuels d'une équipe
+const teamPointsExpr = `(SELECT COALESCE(SUM(p.points), 0) FROM eco_points p
+	JOIN team_members pm ON p.user_id = pm.user_id WHERE pm.team_id = t.id AND p.` + earnedPointsFilter + `)`
+
+// CreateTeam crée une équipe dont l'utilisateur devient le capitaine
+func CreateTeam(db *sql.DB, userID int64, team models.TeamCreate) (int64, error) {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	// Un utilisateur n'appartient qu'à une seule équipe
+	if _, err := userTeamIDTx(tx, userID); err == nil {
+		tx.Rollback()
+		return 0, errors.New("vous faites déjà partie d'une équipe")
+	} else if err != sql.ErrNoRows {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := checkTeamNameTx(tx, team.Name, 0); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Créer l'équipe
+	result, err := tx.Exec(
+		"INSERT INTO teams (name, description, created_at) VALUES (?, ?, ?)",
+		team.Name, team.Description, time.Now(),
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	teamID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Ajouter le créateur comme capitaine
+	_, err = tx.Exec(
+		"INSERT INTO team_members (team_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)",
+		teamID, userID, TeamRoleCaptain, time.Now(),
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return teamID, nil
+}
+
+// GetTeams récupère toutes les équipes avec leur capitaine, leur nombre de membres et leurs points
+func GetTeams(db *sql.DB) ([]models.Team, error) {
+	rows, err := db.Query(`
+		SELECT t.id, t.name, t.description, t.created_at,
+		       (SELECT COUNT(*) FROM team_members m WHERE m.team_id = t.id) as member_count,
+		       ` + teamPointsExpr + ` as points,
+		       cm.user_id, cu.username
+		FROM teams t
+		LEFT JOIN team_members cm ON cm.team_id = t.id AND cm.role = 'captain'
+		LEFT JOIN users cu ON cm.user_id = cu.id
+		ORDER BY t.name ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []models.Team{}
+	for rows.Next() {
+		team, err := scanTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, *team)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// GetTeam récupère une équipe et ses membres
+func GetTeam(db *sql.DB, teamID int64) (*models.Team, error) {
+	row := db.QueryRow(`
+		SELECT t.id, t.name, t.description, t.created_at,
+		       (SELECT COUNT(*) FROM team_members m WHERE m.team_id = t.id) as member_count,
+		       `+teamPointsExpr+` as points,
+		       cm.user_id, cu.username
+		FROM teams t
+		LEFT JOIN team_members cm ON cm.team_id = t.id AND cm.role = 'captain'
+		LEFT JOIN users cu ON cm.user_id = cu.id
+		WHERE t.id = ?
+	`, teamID)
+
+	team, err := scanTeam(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("équipe non trouvée")
+		}
+		return nil, err
+	}
+
+	// Récupérer les membres et leurs points
+	rows, err := db.Query(`
+		SELECT m.user_id, u.username, m.role, m.joined_at,
+		       (SELECT COALESCE(SUM(p.points), 0) FROM eco_points p WHERE p.user_id = m.user_id AND p.`+earnedPointsFilter+`) as points
+		FROM team_members m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.team_id = ?
+		ORDER BY m.role = 'captain' DESC, points DESC, u.username ASC
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	team.Members = []models.TeamMember{}
+	for rows.Next() {
+		var member models.TeamMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Role, &member.JoinedAt, &member.Points); err != nil {
+			return nil, err
+		}
+		team.Members = append(team.Members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+// scanTeam lit une équipe depuis une ligne de résultat
+func scanTeam(scanner interface{ Scan(...interface{}) error }) (*models.Team, error) {
+	team := &models.Team{}
+	var description sql.NullString
+	var captainID sql.NullInt64
+	var captainName sql.NullString
+
+	err := scanner.Scan(
+		&team.ID, &team.Name, &description, &team.CreatedAt,
+		&team.MemberCount, &team.Points, &captainID, &captainName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	team.Description = description.String
+	team.CaptainID = captainID.Int64
+	team.CaptainName = captainName.String
+
+	return team, nil
+}
+
+// UpdateTeam met à jour le nom et la description d'une équipe (réservé au capitaine)
+func UpdateTeam(db *sql.DB, teamID, userID int64, team models.TeamCreate) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := requireTeamCaptainTx(tx, teamID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := checkTeamNameTx(tx, team.Name, teamID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE teams SET name = ?, description = ? WHERE id = ?",
+		team.Name, team.Description, teamID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// JoinTeam ajoute un utilisateur à une équipe
+func JoinTeam(db *sql.DB, userID, teamID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Vérifier si l'équipe existe
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM teams WHERE id = ?)", teamID).Scan(&exists)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if !exists {
+		tx.Rollback()
+		return errors.New("équipe non trouvée")
+	}
+
+	// Un utilisateur n'appartient qu'à une seule équipe
+	currentTeamID, err := userTeamIDTx(tx, userID)
+	if err == nil {
+		tx.Rollback()
+		if currentTeamID == teamID {
+			return errors.New("vous faites déjà partie de cette équipe")
+		}
+		return errors.New("vous faites déjà partie d'une autre équipe: quittez-la d'abord")
+	} else if err != sql.ErrNoRows {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO team_members (team_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)",
+		teamID, userID, TeamRoleMember, time.Now(),
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// LeaveTeam retire un utilisateur de son équipe.
+// Si le capitaine part, le membre le plus ancien devient capitaine; une équipe vide est supprimée.
+func LeaveTeam(db *sql.DB, userID, teamID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := removeTeamMemberTx(tx, teamID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveTeamMember permet au capitaine de retirer un membre de son équipe
+func RemoveTeamMember(db *sql.DB, teamID, captainID, memberID int64) error {
+	if captainID == memberID {
+		return errors.New("utilisez la route de départ pour quitter votre équipe")
+	}
+
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := requireTeamCaptainTx(tx, teamID, captainID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := removeTeamMemberTx(tx, teamID, memberID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TransferTeamCaptain permet au capitaine de désigner un autre membre comme capitaine
+func TransferTeamCaptain(db *sql.DB, teamID, captainID, newCaptainID int64) error {
+	// Démarrer une transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := requireTeamCaptainTx(tx, teamID, captainID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Le nouveau capitaine doit être membre de l'équipe
+	result, err := tx.Exec(
+		"UPDATE team_members SET role = ? WHERE team_id = ? AND user_id = ? AND role = ?",
+		TeamRoleCaptain, teamID, newCaptainID, TeamRoleMember,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("cet utilisateur n'est pas membre de l'équipe")
+	}
+
+	_, err = tx.Exec(
+		"UPDATE team_members SET role = ? WHERE team_id = ? AND user_id = ?",
+		TeamRoleMember, teamID, captainID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// removeTeamMemberTx retire un membre et désigne un nouveau capitaine si nécessaire
+func removeTeamMemberTx(tx *sql.Tx, teamID, userID int64) error {
+	var role string
+	err := tx.QueryRow(
+		"SELECT role FROM team_members WHERE team_id = ? AND user_id = ?",
+		teamID, userID,
+	).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("cet utilisateur n'est pas membre de l'équipe")
+		}
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM team_members WHERE team_id = ? AND user_id = ?", teamID, userID)
+	if err != nil {
+		return err
+	}
+
+	if role != TeamRoleCaptain {
+		return nil
+	}
+
+	// Le membre le plus ancien devient capitaine
+	var successorID int64
+	err = tx.QueryRow(
+		"SELECT user_id FROM team_members WHERE team_id = ? ORDER BY joined_at ASC, user_id ASC LIMIT 1",
+		teamID,
+	).Scan(&successorID)
+
+	if err == sql.ErrNoRows {
+		// Plus aucun membre: supprimer l'équipe
+		_, err = tx.Exec("UPDATE challenge_attempts SET team_id = NULL WHERE team_id = ?", teamID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM teams WHERE id = ?", teamID)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE team_members SET role = ? WHERE team_id = ? AND user_id = ?",
+		TeamRoleCaptain, teamID, successorID,
+	)
+	return err
+}
+
+// requireTeamCaptainTx vérifie que l'utilisateur est le capitaine de l'équipe
+func requireTeamCaptainTx(tx *sql.Tx, teamID, userID int64) error {
+	var role string
+	err := tx.QueryRow(
+		"SELECT role FROM team_members WHERE team_id = ? AND user_id = ?",
+		teamID, userID,
+	).Scan(&role)
+
+	if err == sql.ErrNoRows || (err == nil && role != TeamRoleCaptain) {
+		return errors.New("seul le capitaine peut gérer l'équipe")
+	}
+
+	return err
+}
+
+// checkTeamNameTx vérifie qu'aucune autre équipe ne porte déjà ce nom
+func checkTeamNameTx(tx *sql.Tx, name string, teamID int64) error {
+	var exists bool
+	err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM teams WHERE name = ? COLLATE NOCASE AND id != ?)",
+		name, teamID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.New("ce nom d'équipe est déjà pris")
+	}
+
+	return nil
+}
+
+// userTeamIDTx récupère l'équipe d'un utilisateur (sql.ErrNoRows s'il n'en a pas)
+func userTeamIDTx(tx *sql.Tx, userID int64) (int64, error) {
+	var teamID int64
+	err := tx.QueryRow("SELECT team_id FROM team_members WHERE user_id = ?", userID).Scan(&teamID)
+	return teamID, err
+}
+
+// GetTeamLeaderboard récupère le classement des équipes.
+// Si challengeID est non nul, seuls les points gagnés sur ce défi pour l'équipe sont comptés.
+func GetTeamLeaderboard(db *sql.DB, challengeID int64, limit int) ([]models.TeamLeaderboardEntry, error) {
+	pointsExpr := teamPointsExpr
+	args := []interface{}{}
+
+	if challengeID != 0 {
+		pointsExpr = `(SELECT COALESCE(SUM(p.points), 0) FROM eco_points p
+			JOIN challenge_attempts a ON p.attempt_id = a.id
+			WHERE a.team_id = t.id AND a.challenge_id = ?)`
+		args = append(args, challengeID)
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(`
+		SELECT RANK() OVER (ORDER BY points DESC) as ranking, id, name, member_count, points
+		FROM (
+			SELECT t.id, t.name,
+			       (SELECT COUNT(*) FROM team_members m WHERE m.team_id = t.id) as member_count,
+			       `+pointsExpr+` as points
+			FROM teams t
+		) team_points
+		ORDER BY points DESC, name ASC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.TeamLeaderboardEntry{}
+	for rows.Next() {
+		var entry models.TeamLeaderboardEntry
+		if err := rows.Scan(&entry.Rank, &entry.TeamID, &entry.Name, &entry.MemberCount, &entry.Points); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetTeamChallengeProgress agrège la progression des membres de chaque équipe sur un défi en équipe
+func GetTeamChallengeProgress(db *sql.DB, challengeID int64) ([]models.TeamChallengeProgress, error) {
+	var isTeamChallenge bool
+	err := db.QueryRow("SELECT is_team_challenge FROM eco_challenges WHERE id = ?", challengeID).Scan(&isTeamChallenge)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("défi non trouvé")
+		}
+		return nil, err
+	}
+
+	if !isTeamChallenge {
+		return nil, errors.New("ce défi ne se joue pas en équipe")
+	}
+
+	rows, err := db.Query(`
+		SELECT t.id, t.name,
+		       COUNT(a.id) as participants,
+		       COALESCE(SUM(a.status IN ('in_progress', 'pending_review')), 0) as in_progress,
+		       COALESCE(SUM(a.status = 'completed'), 0) as completed,
+		       (SELECT COALESCE(SUM(p.points), 0) FROM eco_points p
+		        JOIN challenge_attempts pa ON p.attempt_id = pa.id
+		        WHERE pa.team_id = t.id AND pa.challenge_id = ?) as points
+		FROM teams t
+		JOIN challenge_attempts a ON a.team_id = t.id AND a.challenge_id = ?
+		JOIN challenge_participants cp ON cp.attempt_id = a.id
+		GROUP BY t.id, t.name
+		ORDER BY points DESC, completed DESC, t.name ASC
+	`, challengeID, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []models.TeamChallengeProgress{}
+	for rows.Next() {
+		var entry models.TeamChallengeProgress
+		err := rows.Scan(
+			&entry.TeamID, &entry.Name, &entry.Participants,
+			&entry.InProgress, &entry.Completed, &entry.Points,
+		)
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
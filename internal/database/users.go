@@ -61,9 +61,9 @@ func GetUserByEmail(db *sql.DB, email string) (*models.User, error) {
 	user := &models.User{}
 
 	err := db.QueryRow(
-		"SELECT id, email, username, password_hash, is_admin, created_at FROM users WHERE email = ?",
+		"SELECT id, email, username, password_hash, is_admin, created_at, email_verified_at IS NOT NULL FROM users WHERE email = ?",
 		email,
-	).Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.IsAdmin, &user.CreatedAt)
+	).Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.IsAdmin, &user.CreatedAt, &user.EmailVerified)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -80,9 +80,9 @@ func GetUserByID(db *sql.DB, userID int64) (*models.User, error) {
 	user := &models.User{}
 
 	err := db.QueryRow(
-		"SELECT id, email, username, password_hash, is_admin, created_at FROM users WHERE id = ?",
+		"SELECT id, email, username, password_hash, is_admin, created_at, email_verified_at IS NOT NULL FROM users WHERE id = ?",
 		userID,
-	).Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.IsAdmin, &user.CreatedAt)
+	).Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.IsAdmin, &user.CreatedAt, &user.EmailVerified)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -108,6 +108,8 @@ func GetUserProfile(db *sql.DB, userID int64) (*models.UserProfile, error) {
 		Username:  user.Username,
 		IsAdmin:   user.IsAdmin,
 		CreatedAt: user.CreatedAt,
+
+		EmailVerified: user.EmailVerified,
 	}
 
 	// Récupérer le nombre total de points écologiques
@@ -131,6 +133,12 @@ func GetUserProfile(db *sql.DB, userID int64) (*models.UserProfile, error) {
 		profile.BadgeCount = 0
 	}
 
+	// Récupérer la visibilité dans les classements
+	err = db.QueryRow("SELECT hide_from_leaderboard FROM users WHERE id = ?", userID).Scan(&profile.HideFromLeaderboard)
+	if err != nil {
+		return nil, err
+	}
+
 	return profile, nil
 }
 
@@ -171,6 +179,12 @@ func UpdateUserProfile(db *sql.DB, userID int64, update models.UserProfileUpdate
 			args = append(args, update.Email)
 		}
 
+		// Une nouvelle adresse doit être confirmée à son tour
+		if update.Email != "" {
+			query += ", email_verified_at = CASE WHEN email = ? THEN email_verified_at ELSE NULL END"
+			args = append(args, update.Email)
+		}
+
 		query += " WHERE id = ?"
 		args = append(args, userID)
 
@@ -196,6 +210,15 @@ func UpdateUserProfile(db *sql.DB, userID int64, update models.UserProfileUpdate
 		}
 	}
 
+	// Mise à jour de la visibilité dans les classements si fournie
+	if update.HideFromLeaderboard != nil {
+		_, err = tx.Exec("UPDATE users SET hide_from_leaderboard = ? WHERE id = ?", *update.HideFromLeaderboard, userID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	// Commit de la transaction
 	return tx.Commit()
 }
@@ -293,5 +316,11 @@ func GetAdminStats(db *sql.DB) (*models.AdminStats, error) {
 		return nil, err
 	}
 
+	// Calculer l'impact environnemental de l'association et son évolution mensuelle
+	stats.Impact, stats.ImpactByMonth, err = getMonthlyImpact(db, 0)
+	if err != nil {
+		return nil, err
+	}
+
 	return stats, nil
 }
@@ -3,9 +3,15 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"bdd-website/internal/database"
+	"bdd-website/internal/mailer"
 	"bdd-website/internal/middleware"
 	"bdd-website/internal/models"
 )
@@ -16,17 +22,18 @@ func GetActivities(db *sql.DB) http.HandlerFunc {
 		// Récupérer les paramètres de pagination
 		page, pageSize := getPagination(r)
 
-		// Déterminer si on affiche uniquement les activités à venir
-		upcomingOnly := true
-		if showAll := r.URL.Query().Get("all"); showAll == "true" {
-			upcomingOnly = false
+		// Récupérer les filtres et le tri
+		filter, err := parseActivityFilter(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 
 		// Récupérer l'ID utilisateur (optionnel)
 		userID := middleware.GetUserID(r)
 
 		// Récupérer les activités
-		activities, total, err := database.GetActivities(db, page, pageSize, upcomingOnly, userID)
+		activities, total, err := database.GetActivities(db, page, pageSize, filter, userID)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des activités")
 			return
@@ -83,13 +90,27 @@ func RegisterToActivity(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// L'adresse email doit être confirmée
+		if !requireVerifiedEmail(w, db, userID) {
+			return
+		}
+
 		// Inscrire l'utilisateur à l'activité
-		err = database.RegisterToActivity(db, userID, activityID)
+		position, err := database.RegisterToActivity(db, userID, activityID)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
+		// Activité complète: l'utilisateur est en liste d'attente
+		if position > 0 {
+			respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+				"message":           fmt.Sprintf("Activité complète : vous êtes en position %d sur la liste d'attente", position),
+				"waitlist_position": position,
+			})
+			return
+		}
+
 		// Répondre avec succès
 		respondWithJSON(w, http.StatusOK, map[string]string{
 			"message": "Inscription réussie à l'activité",
@@ -173,6 +194,26 @@ func AdminCreateActivity(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Valider la catégorie et normaliser les tags
+		if err := normalizeActivityClassification(&activityCreate.Category, &activityCreate.Tags); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Valider les coordonnées du lieu
+		if err := validateActivityCoordinates(activityCreate.Latitude, activityCreate.Longitude); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Valider les facteurs d'impact
+		impact, err := database.NormalizeImpactFactors(activityCreate.Impact)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		activityCreate.Impact = impact
+
 		// Créer l'activité
 		activityID, err := database.CreateActivity(db, activityCreate)
 		if err != nil {
@@ -215,8 +256,38 @@ func AdminUpdateActivity(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Mettre à jour l'activité
-		err = database.UpdateActivity(db, activityID, activityUpdate)
+		// Valider la catégorie et normaliser les tags
+		if err := normalizeActivityClassification(&activityUpdate.Category, activityUpdate.Tags); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Valider les coordonnées du lieu
+		if err := validateActivityCoordinates(activityUpdate.Latitude, activityUpdate.Longitude); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Valider les facteurs d'impact s'ils sont fournis
+		if activityUpdate.Impact != nil {
+			impact, err := database.NormalizeImpactFactors(*activityUpdate.Impact)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			*activityUpdate.Impact = impact
+		}
+
+		// Mettre à jour l'activité seule, ou l'occurrence et les suivantes de sa série
+		switch scope := r.URL.Query().Get("scope"); scope {
+		case "", "this":
+			err = database.UpdateActivity(db, activityID, activityUpdate)
+		case "following":
+			err = database.UpdateActivityAndFollowing(db, activityID, activityUpdate)
+		default:
+			respondWithError(w, http.StatusBadRequest, "Portée de modification invalide (this ou following)")
+			return
+		}
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -234,7 +305,8 @@ func AdminUpdateActivity(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// AdminDeleteActivity permet à un administrateur de supprimer une activité
+// AdminDeleteActivity permet à un administrateur de supprimer une activité.
+// Une activité supprimée disparaît des flux iCalendar: pour prévenir les agendas abonnés, l'annuler (AdminCancelActivity).
 func AdminDeleteActivity(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Récupérer l'ID de l'activité
@@ -257,3 +329,145 @@ func AdminDeleteActivity(db *sql.DB) http.HandlerFunc {
 		})
 	}
 }
+
+// AdminCancelActivity permet à un administrateur d'annuler une activité.
+// Contrairement à la suppression, l'activité reste publiée comme annulée dans les flux iCalendar.
+func AdminCancelActivity(db *sql.DB, sender mailer.Sender) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de l'activité
+		activityID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'activité invalide")
+			return
+		}
+
+		// Annuler l'activité
+		registrations, err := database.CancelActivity(db, activityID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Prévenir les inscrits
+		go notifyActivityCancellations(sender, registrations)
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message":              "Activité annulée avec succès",
+			"notified_registrants": len(registrations),
+		})
+	}
+}
+
+// Filtres autorisés sur la liste des activités
+var activityFilters = []string{"all", "category", "tag", "location", "from", "to", "free_seats", "near", "radius_km", "sort"}
+
+// parseActivityFilter extrait et valide les filtres et le tri de la liste des activités
+func parseActivityFilter(r *http.Request) (models.ActivityFilter, error) {
+	filters := extractFilters(r, activityFilters)
+
+	// Par défaut, seules les activités à venir sont affichées
+	filter := models.ActivityFilter{
+		Upcoming: filters["all"] != "true",
+		Tag:      strings.TrimSpace(filters["tag"]),
+		Location: strings.TrimSpace(filters["location"]),
+		Sort:     database.ActivitySortDate,
+	}
+
+	if category := filters["category"]; category != "" {
+		if !database.IsValidActivityCategory(category) {
+			return filter, fmt.Errorf("catégorie invalide (%s)", strings.Join(database.ActivityCategories, ", "))
+		}
+		filter.Category = category
+	}
+
+	if from := filters["from"]; from != "" {
+		date, err := parseFilterDate(from, false)
+		if err != nil {
+			return filter, errors.New("date de début invalide (AAAA-MM-JJ ou RFC 3339)")
+		}
+		filter.From = date
+	}
+
+	if to := filters["to"]; to != "" {
+		date, err := parseFilterDate(to, true)
+		if err != nil {
+			return filter, errors.New("date de fin invalide (AAAA-MM-JJ ou RFC 3339)")
+		}
+		filter.To = date
+	}
+
+	if freeSeats := filters["free_seats"]; freeSeats != "" {
+		hasFreeSeats, err := strconv.ParseBool(freeSeats)
+		if err != nil {
+			return filter, errors.New("valeur de free_seats invalide (true ou false)")
+		}
+		filter.HasFreeSeats = hasFreeSeats
+	}
+
+	if near := filters["near"]; near != "" {
+		point, err := parseGeoPoint(near)
+		if err != nil {
+			return filter, err
+		}
+		filter.Near = &point
+
+		filter.RadiusKm = DefaultRadiusKm
+		if radius := filters["radius_km"]; radius != "" {
+			radiusKm, err := strconv.ParseFloat(radius, 64)
+			if err != nil || radiusKm <= 0 || radiusKm > MaxRadiusKm {
+				return filter, fmt.Errorf("rayon invalide (entre 0 et %d km)", MaxRadiusKm)
+			}
+			filter.RadiusKm = radiusKm
+		}
+	} else if filters["radius_km"] != "" {
+		return filter, errors.New("le paramètre radius_km nécessite near=lat,lng")
+	}
+
+	switch sort := filters["sort"]; sort {
+	case "":
+	case database.ActivitySortDate, database.ActivitySortPopularity, database.ActivitySortPoints:
+		filter.Sort = sort
+	default:
+		return filter, errors.New("tri invalide (date, popularity ou points)")
+	}
+
+	return filter, nil
+}
+
+// parseFilterDate analyse une date de filtre au format AAAA-MM-JJ ou RFC 3339.
+// Une date sans heure utilisée comme borne de fin inclut toute la journée.
+func parseFilterDate(value string, endOfDay bool) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			date = date.Add(24*time.Hour - time.Second)
+		}
+		return date, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// normalizeActivityClassification valide la catégorie d'une activité (par défaut "other") et normalise ses tags
+func normalizeActivityClassification(category *string, tags *[]string) error {
+	if *category == "" {
+		*category = database.CategoryOther
+	}
+
+	if !database.IsValidActivityCategory(*category) {
+		return fmt.Errorf("catégorie invalide (%s)", strings.Join(database.ActivityCategories, ", "))
+	}
+
+	// Tags absents d'une modification: ils restent inchangés
+	if tags == nil {
+		return nil
+	}
+
+	normalized, err := database.NormalizeActivityTags(*tags)
+	if err != nil {
+		return err
+	}
+	*tags = normalized
+
+	return nil
+}
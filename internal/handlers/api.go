@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"bdd-website/internal/database"
 	"bdd-website/internal/middleware"
@@ -16,45 +17,76 @@ func APIHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// APISearchUsers permet de rechercher des utilisateurs (admin uniquement)
-func APISearchUsers(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Vérifier si l'utilisateur est admin
-		if !middleware.IsAdmin(r) {
-			respondWithError(w, http.StatusForbidden, "Accès interdit")
-			return
-		}
+// Limites du nombre de résultats de recherche
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 50
+)
+
+// searchTypes associe les valeurs du paramètre "type" aux types de résultats
+var searchTypes = map[string][]string{
+	"":           {database.SearchTypeActivity, database.SearchTypeChallenge, database.SearchTypeMessage},
+	"all":        {database.SearchTypeActivity, database.SearchTypeChallenge, database.SearchTypeMessage},
+	"activities": {database.SearchTypeActivity},
+	"challenges": {database.SearchTypeChallenge},
+	"messages":   {database.SearchTypeMessage},
+}
 
+// APISearch effectue une recherche plein texte sur les activités, les défis et (pour les administrateurs) les messages de contact
+func APISearch(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		// Récupérer le terme de recherche
-		query := r.URL.Query().Get("q")
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
 		if query == "" {
 			respondWithError(w, http.StatusBadRequest, "Terme de recherche requis")
 			return
 		}
 
+		// Récupérer les types de résultats demandés
+		searchType := r.URL.Query().Get("type")
+		types, ok := searchTypes[searchType]
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Type de recherche invalide (activities, challenges, messages ou all)")
+			return
+		}
+
+		isAdmin := middleware.IsAdmin(r)
+		if searchType == "messages" && !isAdmin {
+			respondWithError(w, http.StatusForbidden, "Accès interdit")
+			return
+		}
+
 		// Récupérer la limite
-		limit := 10
+		limit := DefaultSearchLimit
 		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
 				limit = l
 				// Limiter à un maximum raisonnable
-				if limit > 50 {
-					limit = 50
+				if limit > MaxSearchLimit {
+					limit = MaxSearchLimit
 				}
 			}
 		}
 
 		// Effectuer la recherche
-		users, err := database.SearchUsers(db, query, limit)
+		results, err := database.Search(db, query, types, isAdmin, limit)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la recherche des utilisateurs")
+			switch err {
+			case database.ErrSearchUnavailable:
+				respondWithError(w, http.StatusServiceUnavailable, "La recherche est temporairement indisponible")
+			case database.ErrInvalidSearchQuery:
+				respondWithError(w, http.StatusBadRequest, err.Error())
+			default:
+				respondWithError(w, http.StatusInternalServerError, "Erreur lors de la recherche")
+			}
 			return
 		}
 
 		// Répondre avec les résultats
 		respondWithJSON(w, http.StatusOK, map[string]interface{}{
-			"users": users,
-			"count": len(users),
+			"query":   query,
+			"results": results,
+			"count":   len(results),
 		})
 	}
 }
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
+)
+
+// Taille par défaut (en pixels) des QR codes PNG de pointage
+const (
+	DefaultQRCodeSize = 512
+	MaxQRCodeSize     = 2048
+)
+
+// AdminGetActivityAttendance permet à un administrateur de consulter la présence des inscrits
+func AdminGetActivityAttendance(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de l'activité
+		activityID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'activité invalide")
+			return
+		}
+
+		// Récupérer la liste de présence
+		attendances, err := database.GetActivityAttendance(db, activityID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		// Répondre avec la liste de présence
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"attendances": attendances,
+			"count":       len(attendances),
+		})
+	}
+}
+
+// AdminMarkAttendance permet à un administrateur de saisir la présence des inscrits
+func AdminMarkAttendance(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de l'administrateur
+		adminID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de l'activité
+		activityID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'activité invalide")
+			return
+		}
+
+		// Décoder le corps de la requête
+		var attendanceRequest models.AttendanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&attendanceRequest); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		// Valider les données
+		if len(attendanceRequest.Attendances) == 0 {
+			respondWithError(w, http.StatusBadRequest, "Aucune présence à enregistrer")
+			return
+		}
+
+		// Enregistrer la présence et créditer les points
+		credited, err := database.MarkAttendance(db, activityID, adminID, attendanceRequest.Attendances)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message":        "Présence enregistrée avec succès",
+			"credited_users": credited,
+		})
+	}
+}
+
+// AdminGetCheckinToken permet à un administrateur d'obtenir le jeton de pointage d'une activité
+func AdminGetCheckinToken(db *sql.DB, jwtSecret, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de l'activité
+		activityID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'activité invalide")
+			return
+		}
+
+		// Récupérer l'activité
+		activity, err := database.GetActivity(db, activityID, 0)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Activité non trouvée")
+			return
+		}
+
+		// Générer le jeton, valable jusqu'à la fin de l'activité
+		token := utils.GenerateCheckinToken(activity.ID, activity.EndDate, jwtSecret)
+
+		// Répondre avec le jeton et les liens associés
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"token":       token,
+			"checkin_url": checkinURL(publicURL, activity.ID, token),
+			"valid_from":  activity.StartDate,
+			"expires_at":  activity.EndDate,
+			"qr_png_url":  fmt.Sprintf("/api/admin/activities/%d/checkin-qr?format=png", activity.ID),
+			"qr_svg_url":  fmt.Sprintf("/api/admin/activities/%d/checkin-qr?format=svg", activity.ID),
+		})
+	}
+}
+
+// AdminGetCheckinQRCode génère le QR code de pointage d'une activité au format PNG ou SVG
+func AdminGetCheckinQRCode(db *sql.DB, jwtSecret, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de l'activité
+		activityID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'activité invalide")
+			return
+		}
+
+		// Récupérer l'activité
+		activity, err := database.GetActivity(db, activityID, 0)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Activité non trouvée")
+			return
+		}
+
+		// Contenu du QR code: lien vers la page de pointage
+		token := utils.GenerateCheckinToken(activity.ID, activity.EndDate, jwtSecret)
+		content := checkinURL(publicURL, activity.ID, token)
+
+		// Générer l'image dans le format demandé
+		var image []byte
+		var contentType string
+
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "png":
+			size := DefaultQRCodeSize
+			if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
+				if s, err := strconv.Atoi(sizeStr); err == nil && s > 0 {
+					size = s
+					// Limiter la taille de l'image
+					if size > MaxQRCodeSize {
+						size = MaxQRCodeSize
+					}
+				}
+			}
+			image, err = utils.RenderQRCodePNG(content, size)
+			contentType = "image/png"
+		case "svg":
+			image, err = utils.RenderQRCodeSVG(content)
+			contentType = "image/svg+xml"
+		default:
+			respondWithError(w, http.StatusBadRequest, "Format d'image invalide (png ou svg)")
+			return
+		}
+
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la génération du QR code")
+			return
+		}
+
+		// Répondre avec l'image
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write(image)
+	}
+}
+
+// CheckInToActivity permet à un inscrit de pointer sa présence avec le jeton du QR code
+func CheckInToActivity(db *sql.DB, jwtSecret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de l'activité
+		activityID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'activité invalide")
+			return
+		}
+
+		// Décoder le corps de la requête
+		var req struct {
+			Token string `json:"token"`
+		}
+
+		if err := decodeJSONBody(r, &req); err != nil || req.Token == "" {
+			respondWithError(w, http.StatusBadRequest, "Jeton de pointage requis")
+			return
+		}
+
+		// Vérifier le jeton
+		if err := utils.ValidateCheckinToken(req.Token, activityID, jwtSecret); err != nil {
+			respondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+
+		// Enregistrer la présence
+		err = database.CheckInToActivity(db, userID, activityID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Présence enregistrée, merci de votre participation !",
+		})
+	}
+}
+
+// checkinURL construit le lien de pointage encodé dans le QR code
+func checkinURL(publicURL string, activityID int64, token string) string {
+	return fmt.Sprintf("%s/checkin?activity=%d&token=%s", publicURL, activityID, url.QueryEscape(token))
+}
@@ -3,15 +3,24 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
 
 	"bdd-website/internal/database"
+	"bdd-website/internal/mailer"
+	"bdd-website/internal/middleware"
 	"bdd-website/internal/models"
 	"bdd-website/internal/utils"
 )
 
-// Register gère l'inscription d'un nouvel utilisateur
-func Register(db *sql.DB) http.HandlerFunc {
+// Register gère l'inscription d'un nouvel utilisateur.
+// Un lien de confirmation de l'adresse email, valable pendant verificationTTL, lui est envoyé.
+func Register(db *sql.DB, sender mailer.Sender, jwtSecret, publicURL string, verificationTTL time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Décoder le corps de la requête
 		var userRegister models.UserRegister
@@ -26,6 +35,16 @@ func Register(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		if address, err := mail.ParseAddress(userRegister.Email); err != nil || address.Address != userRegister.Email {
+			respondWithError(w, http.StatusBadRequest, "Adresse email invalide")
+			return
+		}
+
+		if err := utils.ValidatePassword(userRegister.Password); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		// Créer l'utilisateur
 		userID, err := database.CreateUser(db, userRegister)
 		if err != nil {
@@ -40,9 +59,15 @@ func Register(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Envoyer le lien de confirmation (l'inscription ne doit pas échouer si l'envoi échoue:
+		// l'utilisateur peut en demander un nouveau)
+		if err := sendVerificationEmail(db, sender, userID, user.Email, jwtSecret, publicURL, verificationTTL); err != nil {
+			log.Printf("Erreur lors de l'envoi du lien de confirmation à l'utilisateur %d: %v", userID, err)
+		}
+
 		// Répondre avec succès
 		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
-			"message":  "Inscription réussie",
+			"message":  "Inscription réussie, consultez vos emails pour confirmer votre adresse",
 			"user_id":  userID,
 			"email":    user.Email,
 			"username": user.Username,
@@ -50,8 +75,12 @@ func Register(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// Login gère la connexion d'un utilisateur
-func Login(db *sql.DB, jwtSecret string, jwtExpirationHours int) http.HandlerFunc {
+// Login gère la connexion d'un utilisateur.
+// Les tentatives sont enregistrées; après plusieurs échecs, le compte et l'adresse IP sont verrouillés temporairement.
+// Un jeton d'accès de courte durée et un jeton de renouvellement sont retournés pour les clients de l'API;
+// avec "session": true, une session de navigateur est aussi ouverte (cookie HttpOnly, jeton CSRF retourné
+// et déposé dans un cookie lisible par la page).
+func Login(db *sql.DB, jwtSecret string, accessTokenTTL, refreshTokenTTL, sessionTTL time.Duration, secureCookies bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Décoder le corps de la requête
 		var userLogin models.UserLogin
@@ -66,21 +95,58 @@ func Login(db *sql.DB, jwtSecret string, jwtExpirationHours int) http.HandlerFun
 			return
 		}
 
+		ip := middleware.ClientIP(r)
+
+		// Refuser les tentatives pendant un verrouillage du compte ou de l'adresse IP.
+		// Une tentative autorisée est comptée comme un échec jusqu'à la vérification du mot de passe.
+		lockedUntil, err := database.ReserveLoginAttempt(db, userLogin.Email, ip)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la connexion")
+			return
+		}
+
+		if !lockedUntil.IsZero() {
+			var userID int64
+			if user, err := database.GetUserByEmail(db, userLogin.Email); err == nil {
+				userID = user.ID
+			}
+			recordLoginFailure(db, userID, userLogin.Email, ip, r.UserAgent())
+
+			wait := time.Until(lockedUntil)
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			respondWithError(w, http.StatusTooManyRequests, fmt.Sprintf(
+				"Trop de tentatives de connexion échouées, réessayez dans %d minute(s)", int(wait.Minutes())+1,
+			))
+			return
+		}
+
 		// Récupérer l'utilisateur
 		user, err := database.GetUserByEmail(db, userLogin.Email)
 		if err != nil {
+			recordLoginFailure(db, 0, userLogin.Email, ip, r.UserAgent())
 			respondWithError(w, http.StatusUnauthorized, "Email ou mot de passe incorrect")
 			return
 		}
 
 		// Vérifier le mot de passe
 		if !utils.CheckPasswordHash(userLogin.Password, user.Password) {
+			recordLoginFailure(db, user.ID, userLogin.Email, ip, r.UserAgent())
 			respondWithError(w, http.StatusUnauthorized, "Email ou mot de passe incorrect")
 			return
 		}
 
-		// Générer le token JWT
-		token, err := utils.GenerateToken(user, jwtSecret, jwtExpirationHours)
+		if err := database.RecordLoginSuccess(db, user.ID, userLogin.Email, ip, r.UserAgent()); err != nil {
+			log.Printf("Erreur lors de l'enregistrement de la connexion de l'utilisateur %d: %v", user.ID, err)
+		}
+
+		// Générer le token JWT et le jeton de renouvellement
+		token, err := utils.GenerateToken(user, jwtSecret, accessTokenTTL)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la génération du token")
+			return
+		}
+
+		refreshToken, err := database.CreateRefreshToken(db, user.ID, r.UserAgent(), refreshTokenTTL)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la génération du token")
 			return
@@ -93,10 +159,148 @@ func Login(db *sql.DB, jwtSecret string, jwtExpirationHours int) http.HandlerFun
 			return
 		}
 
+		response := models.UserResponse{
+			User:         *profile,
+			Token:        token,
+			RefreshToken: refreshToken,
+			ExpiresIn:    int(accessTokenTTL.Seconds()),
+		}
+
+		// Ouvrir la session du navigateur si le client la demande (les clients d'API s'en passent)
+		if userLogin.Session {
+			sessionToken, session, err := database.CreateSession(db, user.ID, r.UserAgent(), sessionTTL)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Erreur lors de l'ouverture de la session")
+				return
+			}
+			setSessionCookies(w, sessionToken, session.CSRFToken, session.ExpiresAt, secureCookies)
+			response.CSRFToken = session.CSRFToken
+		}
+
 		// Répondre avec le token et les informations utilisateur
-		respondWithJSON(w, http.StatusOK, models.UserResponse{
-			User:  *profile,
-			Token: token,
+		respondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// recordLoginFailure enregistre une connexion refusée dans l'historique (userID à 0 si l'adresse ne correspond à aucun compte)
+func recordLoginFailure(db *sql.DB, userID int64, email, ip, userAgent string) {
+	if err := database.RecordLoginFailure(db, userID, email, ip, userAgent); err != nil {
+		log.Printf("Erreur lors de l'enregistrement d'un échec de connexion: %v", err)
+	}
+}
+
+// RefreshToken échange un jeton de renouvellement contre un nouveau jeton d'accès et un nouveau jeton de renouvellement.
+// L'ancien jeton ne peut plus être utilisé: le rejouer révoque la session.
+func RefreshToken(db *sql.DB, jwtSecret string, accessTokenTTL, refreshTokenTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Décoder le corps de la requête
+		var request models.RefreshTokenRequest
+		if err := decodeJSONBody(r, &request); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		if request.RefreshToken == "" {
+			respondWithError(w, http.StatusBadRequest, "Jeton de renouvellement requis")
+			return
+		}
+
+		// Renouveler le jeton
+		refreshToken, user, err := database.RotateRefreshToken(db, request.RefreshToken, r.UserAgent(), refreshTokenTTL)
+		if err != nil {
+			if err == database.ErrInvalidRefreshToken || err == database.ErrRefreshTokenReused {
+				respondWithError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors du renouvellement du token")
+			return
+		}
+
+		// Générer le nouveau token JWT avec le rôle actuel de l'utilisateur
+		token, err := utils.GenerateToken(user, jwtSecret, accessTokenTTL)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la génération du token")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, models.TokenResponse{
+			Token:        token,
+			RefreshToken: refreshToken,
+			ExpiresIn:    int(accessTokenTTL.Seconds()),
+		})
+	}
+}
+
+// Logout détruit la session du navigateur et efface ses cookies.
+// Le jeton de renouvellement éventuellement fourni ({"refresh_token": "..."}) est révoqué;
+// le jeton d'accès, de courte durée, reste valide jusqu'à son expiration.
+func Logout(db *sql.DB, secureCookies bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Le corps de la requête est facultatif
+		var request models.RefreshTokenRequest
+		if err := decodeJSONBody(r, &request); err != nil && err != io.EOF {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		// Révoquer le jeton de renouvellement
+		if request.RefreshToken != "" {
+			if err := database.RevokeRefreshToken(db, request.RefreshToken); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Erreur lors de la révocation du token")
+				return
+			}
+		}
+
+		// Détruire la session du cookie
+		if cookie, err := r.Cookie(middleware.SessionCookieName); err == nil {
+			if err := database.DeleteSession(db, cookie.Value); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Erreur lors de la fermeture de la session")
+				return
+			}
+		}
+
+		clearSessionCookies(w, secureCookies)
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Déconnexion réussie",
+		})
+	}
+}
+
+// setSessionCookies dépose le cookie de session (HttpOnly) et le cookie du jeton CSRF
+func setSessionCookies(w http.ResponseWriter, sessionToken, csrfToken string, expiresAt time.Time, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.SessionCookieName,
+		Value:    sessionToken,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.CSRFCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		Expires:  expiresAt,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearSessionCookies efface les cookies de session et du jeton CSRF
+func clearSessionCookies(w http.ResponseWriter, secure bool) {
+	for _, name := range []string{middleware.SessionCookieName, middleware.CSRFCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == middleware.SessionCookieName,
+			Secure:   secure,
+			SameSite: http.SameSiteLaxMode,
 		})
 	}
 }
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/models"
+)
+
+// validateBadge nettoie et valide les données d'un badge
+func validateBadge(badge *models.BadgeCreate) string {
+	badge.Name = strings.TrimSpace(badge.Name)
+	badge.Description = strings.TrimSpace(badge.Description)
+	badge.ImagePath = strings.TrimSpace(badge.ImagePath)
+
+	if badge.Name == "" || badge.Description == "" || badge.ImagePath == "" {
+		return "Nom, description et image obligatoires"
+	}
+
+	validCategory := false
+	for _, category := range database.BadgeCategories {
+		if badge.Category == category {
+			validCategory = true
+			break
+		}
+	}
+	if !validCategory {
+		return "Catégorie invalide (participation, challenge ou special)"
+	}
+
+	if err := database.ValidateBadgeRules(badge.Rules); err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
+
+// AdminGetBadges récupère tous les badges avec leurs critères
+func AdminGetBadges(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		badges, err := database.GetBadges(db)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des badges")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, badges)
+	}
+}
+
+// AdminCreateBadge permet à un administrateur de créer un badge et ses critères
+func AdminCreateBadge(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Décoder le corps de la requête
+		var badge models.BadgeCreate
+		if err := decodeJSONBody(r, &badge); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		// Valider les données
+		if msg := validateBadge(&badge); msg != "" {
+			respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		// Créer le badge
+		badgeID, err := database.CreateBadge(db, badge)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message": "Badge créé avec succès",
+			"id":      badgeID,
+		})
+	}
+}
+
+// AdminUpdateBadge permet à un administrateur de modifier un badge et ses critères
+func AdminUpdateBadge(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID du badge
+		badgeID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de badge invalide")
+			return
+		}
+
+		// Décoder le corps de la requête
+		var badge models.BadgeCreate
+		if err := decodeJSONBody(r, &badge); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		// Valider les données
+		if msg := validateBadge(&badge); msg != "" {
+			respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		// Mettre à jour le badge
+		if err := database.UpdateBadge(db, badgeID, badge); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Badge mis à jour avec succès",
+		})
+	}
+}
+
+// AdminDeleteBadge permet à un administrateur de supprimer un badge
+func AdminDeleteBadge(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID du badge
+		badgeID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de badge invalide")
+			return
+		}
+
+		if err := database.DeleteBadge(db, badgeID); err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Badge supprimé avec succès",
+		})
+	}
+}
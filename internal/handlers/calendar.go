@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
+)
+
+// Nombre maximum d'activités incluses dans un flux iCalendar
+const MaxCalendarEvents = 500
+
+// GetActivitiesCalendar génère le flux iCalendar public des activités, avec les mêmes filtres que la liste
+func GetActivitiesCalendar(db *sql.DB, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer les filtres (mêmes critères que la liste des activités)
+		filter, err := parseActivityFilter(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Les activités annulées restent dans le flux pour que les agendas abonnés les retirent
+		filter.IncludeCancelled = true
+
+		// Récupérer les activités
+		activities, _, err := database.GetActivities(db, 1, MaxCalendarEvents, filter, 0)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des activités")
+			return
+		}
+
+		respondWithICal(w, "", utils.RenderICalendar("Activités BDD", publicURL, activities))
+	}
+}
+
+// GetActivityCalendar génère le fichier iCalendar d'une activité
+func GetActivityCalendar(db *sql.DB, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de l'activité
+		activityID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'activité invalide")
+			return
+		}
+
+		// Récupérer l'activité
+		activity, err := database.GetActivity(db, activityID, 0)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Activité non trouvée")
+			return
+		}
+
+		calendar := utils.RenderICalendar(activity.Title, publicURL, []models.Activity{*activity})
+		respondWithICal(w, fmt.Sprintf("activite-%d.ics", activity.ID), calendar)
+	}
+}
+
+// GetUserCalendarFeed génère le flux iCalendar privé des inscriptions d'un utilisateur.
+// L'accès est contrôlé par le jeton présent dans l'URL, les agendas ne pouvant pas envoyer de JWT.
+func GetUserCalendarFeed(db *sql.DB, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'utilisateur correspondant au jeton
+		userID, err := database.GetUserIDByCalendarToken(db, mux.Vars(r)["token"])
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		// Récupérer les inscriptions, historique compris
+		activities, err := database.GetUserRegistrations(db, userID, true)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des inscriptions")
+			return
+		}
+
+		respondWithICal(w, "", utils.RenderICalendar("Mes activités BDD", publicURL, activities))
+	}
+}
+
+// GetUserCalendarLink récupère le lien d'abonnement au flux iCalendar personnel de l'utilisateur
+func GetUserCalendarLink(db *sql.DB, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer (ou créer) le jeton
+		token, err := database.GetCalendarToken(db, userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération du lien d'agenda")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, calendarLinks(publicURL, token))
+	}
+}
+
+// RegenerateUserCalendarLink remplace le lien d'abonnement de l'utilisateur, par exemple s'il a été partagé par erreur
+func RegenerateUserCalendarLink(db *sql.DB, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Générer un nouveau jeton
+		token, err := database.RegenerateCalendarToken(db, userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la régénération du lien d'agenda")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, calendarLinks(publicURL, token))
+	}
+}
+
+// calendarLinks construit les liens d'abonnement au flux iCalendar personnel
+func calendarLinks(publicURL, token string) map[string]string {
+	feedURL := fmt.Sprintf("%s/api/calendar/%s.ics", publicURL, token)
+
+	// Le schéma webcal:// ouvre directement l'abonnement dans les applications d'agenda
+	webcalURL := feedURL
+	if i := strings.Index(webcalURL, "://"); i >= 0 {
+		webcalURL = "webcal" + webcalURL[i:]
+	}
+
+	return map[string]string{
+		"feed_url":   feedURL,
+		"webcal_url": webcalURL,
+	}
+}
+
+// respondWithICal envoie un calendrier iCalendar, en téléchargement si un nom de fichier est fourni
+func respondWithICal(w http.ResponseWriter, filename string, calendar []byte) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if filename != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(calendar)
+}
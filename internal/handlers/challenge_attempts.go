@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"bdd-website/internal/database"
+)
+
+// AbandonChallenge permet à un utilisateur d'abandonner un défi en cours
+func AbandonChallenge(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID du défi
+		challengeID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de défi invalide")
+			return
+		}
+
+		// Abandonner le défi
+		if err := database.AbandonChallenge(db, userID, challengeID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Défi abandonné",
+		})
+	}
+}
+
+// GetChallengeAttempts récupère l'historique des tentatives de l'utilisateur sur un défi
+func GetChallengeAttempts(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID du défi
+		challengeID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de défi invalide")
+			return
+		}
+
+		// Récupérer les tentatives
+		attempts, err := database.GetChallengeAttempts(db, challengeID, userID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, attempts)
+	}
+}
+
+// AdminGetChallengeAttempts récupère les tentatives de tous les utilisateurs sur un défi
+func AdminGetChallengeAttempts(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID du défi
+		challengeID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de défi invalide")
+			return
+		}
+
+		// Récupérer les tentatives
+		attempts, err := database.GetChallengeAttempts(db, challengeID, 0)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, attempts)
+	}
+}
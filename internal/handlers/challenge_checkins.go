@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"bdd-website/internal/database"
+)
+
+// MaxCheckinNoteSize est la longueur maximale de la note d'un pointage
+const MaxCheckinNoteSize = 500
+
+// CheckInChallenge enregistre le pointage du jour d'un utilisateur sur un défi en cours.
+// Le corps JSON {"note": "..."} est facultatif.
+func CheckInChallenge(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID du défi
+		challengeID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de défi invalide")
+			return
+		}
+
+		// Décoder le corps de la requête (facultatif)
+		var body struct {
+			Note string `json:"note"`
+		}
+		if err := decodeJSONBody(r, &body); err != nil && err != io.EOF {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		body.Note = strings.TrimSpace(body.Note)
+		if len(body.Note) > MaxCheckinNoteSize {
+			respondWithError(w, http.StatusBadRequest, "La note ne doit pas dépasser 500 caractères")
+			return
+		}
+
+		// Enregistrer le pointage
+		streak, err := database.CheckInChallenge(db, userID, challengeID, body.Note, time.Now())
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Répondre avec la série mise à jour
+		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message": "Pointage enregistré",
+			"streak":  streak,
+		})
+	}
+}
+
+// GetChallengeCheckins récupère les pointages de l'utilisateur pour sa tentative en cours sur un défi
+func GetChallengeCheckins(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID du défi
+		challengeID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de défi invalide")
+			return
+		}
+
+		// Récupérer les pointages
+		checkins, err := database.GetChallengeCheckins(db, userID, challengeID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, checkins)
+	}
+}
+
+// GetUserStreak récupère la série de pointages quotidiens de l'utilisateur
+func GetUserStreak(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		streak, err := database.GetUserStreak(db, userID, time.Now())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération de la série")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, streak)
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"database/sql"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
+)
+
+// Limites des preuves envoyées pour terminer un défi
+const (
+	MaxProofPhotos    = 3
+	MaxProofPhotoSize = 5 * 1024 * 1024 // 5 Mo par photo
+	MaxProofNoteSize  = 2000
+)
+
+// proofDir retourne le répertoire de stockage des photos de preuves
+func proofDir(uploadDir string) string {
+	return filepath.Join(uploadDir, "proofs")
+}
+
+// CompleteChallenge permet à un utilisateur de soumettre une preuve pour terminer un défi.
+// Accepte un formulaire multipart (champ "note", fichiers "photos" et champs "impact_<indicateur>")
+// ou un JSON {"note": "...", "impact": {"co2_kg": 12.5}}.
+// Les points et l'impact ne sont crédités qu'après validation par un administrateur.
+func CompleteChallenge(db *sql.DB, uploadDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID du défi
+		challengeID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de défi invalide")
+			return
+		}
+
+		var note string
+		var photos []models.ChallengeProofPhoto
+		var impact map[string]float64
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "multipart/form-data" {
+			r.Body = http.MaxBytesReader(w, r.Body, MaxProofPhotos*MaxProofPhotoSize+1024*1024)
+			if err := r.ParseMultipartForm(1024 * 1024); err != nil {
+				respondWithError(w, http.StatusBadRequest, "Formulaire invalide ou trop volumineux")
+				return
+			}
+			defer r.MultipartForm.RemoveAll()
+
+			note = r.FormValue("note")
+
+			// Lire les impacts déclarés
+			impact, err = parseImpactFormValues(r)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			files := r.MultipartForm.File["photos"]
+			if len(files) > MaxProofPhotos {
+				respondWithError(w, http.StatusBadRequest, "3 photos maximum par preuve")
+				return
+			}
+
+			// Enregistrer les photos sur le disque
+			for _, fileHeader := range files {
+				fileName, contentType, err := utils.SaveUploadedImage(fileHeader, proofDir(uploadDir), MaxProofPhotoSize)
+				if err != nil {
+					removeProofPhotos(uploadDir, photos)
+					respondWithError(w, http.StatusBadRequest, err.Error())
+					return
+				}
+				photos = append(photos, models.ChallengeProofPhoto{FileName: fileName, ContentType: contentType})
+			}
+		} else {
+			var body struct {
+				Note   string             `json:"note"`
+				Impact map[string]float64 `json:"impact"`
+			}
+			if err := decodeJSONBody(r, &body); err != nil {
+				respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+				return
+			}
+			note = body.Note
+			impact = body.Impact
+		}
+
+		// Valider la note
+		note = strings.TrimSpace(note)
+		if note == "" {
+			removeProofPhotos(uploadDir, photos)
+			respondWithError(w, http.StatusBadRequest, "Une note décrivant la réalisation du défi est obligatoire")
+			return
+		}
+		if len(note) > MaxProofNoteSize {
+			removeProofPhotos(uploadDir, photos)
+			respondWithError(w, http.StatusBadRequest, "La note ne doit pas dépasser 2000 caractères")
+			return
+		}
+
+		// Enregistrer la preuve
+		submissionID, err := database.SubmitChallengeProof(db, userID, challengeID, note, photos, impact)
+		if err != nil {
+			removeProofPhotos(uploadDir, photos)
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+			"message":       "Preuve envoyée, en attente de validation",
+			"submission_id": submissionID,
+		})
+	}
+}
+
+// removeProofPhotos supprime les photos enregistrées lorsque la preuve n'a pas pu être créée
+func removeProofPhotos(uploadDir string, photos []models.ChallengeProofPhoto) {
+	for _, photo := range photos {
+		os.Remove(filepath.Join(proofDir(uploadDir), photo.FileName))
+	}
+}
+
+// AdminGetChallengeSubmissions récupère la file des preuves à examiner
+func AdminGetChallengeSubmissions(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = database.SubmissionPending
+		}
+
+		if status != database.SubmissionPending && status != database.SubmissionApproved && status != database.SubmissionRejected {
+			respondWithError(w, http.StatusBadRequest, "Statut invalide")
+			return
+		}
+
+		// Récupérer les preuves
+		submissions, err := database.GetChallengeSubmissions(db, status)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des preuves")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, submissions)
+	}
+}
+
+// AdminApproveChallengeSubmission approuve une preuve et crédite les points du défi
+func AdminApproveChallengeSubmission(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de la preuve
+		submissionID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de preuve invalide")
+			return
+		}
+
+		// Approuver la preuve
+		if err := database.ApproveChallengeSubmission(db, submissionID, adminID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Preuve approuvée, points crédités",
+		})
+	}
+}
+
+// AdminRejectChallengeSubmission refuse une preuve avec un motif
+func AdminRejectChallengeSubmission(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de la preuve
+		submissionID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de preuve invalide")
+			return
+		}
+
+		// Décoder le corps de la requête
+		var decision models.ChallengeReviewDecision
+		if err := decodeJSONBody(r, &decision); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		decision.Reason = strings.TrimSpace(decision.Reason)
+		if decision.Reason == "" {
+			respondWithError(w, http.StatusBadRequest, "Le motif du refus est obligatoire")
+			return
+		}
+
+		// Refuser la preuve
+		if err := database.RejectChallengeSubmission(db, submissionID, adminID, decision.Reason); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Preuve refusée",
+		})
+	}
+}
+
+// AdminGetChallengeProofPhoto sert une photo de preuve
+func AdminGetChallengeProofPhoto(db *sql.DB, uploadDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		photoID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de photo invalide")
+			return
+		}
+
+		photo, err := database.GetChallengeProofPhoto(db, photoID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", photo.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeFile(w, r, filepath.Join(proofDir(uploadDir), filepath.Base(photo.FileName)))
+	}
+}
@@ -33,6 +33,27 @@ func GetUserEcoPoints(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// GetEcoDashboardSummary récupère le résumé du tableau de bord écologique
+func GetEcoDashboardSummary(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer le résumé
+		summary, err := database.GetEcoDashboardSummary(db, userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération du tableau de bord")
+			return
+		}
+
+		// Répondre avec le résumé
+		respondWithJSON(w, http.StatusOK, summary)
+	}
+}
+
 // GetUserChallenges récupère les défis écologiques disponibles et en cours
 func GetUserChallenges(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -93,38 +114,13 @@ func JoinChallenge(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Rejoindre le défi
-		err = database.JoinChallenge(db, userID, challengeID)
-		if err != nil {
-			respondWithError(w, http.StatusBadRequest, err.Error())
-			return
-		}
-
-		// Répondre avec succès
-		respondWithJSON(w, http.StatusOK, map[string]string{
-			"message": "Vous avez rejoint le défi avec succès",
-		})
-	}
-}
-
-// CompleteChallenge permet à un utilisateur de marquer un défi comme terminé
-func CompleteChallenge(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Récupérer l'ID utilisateur du contexte
-		userID, ok := getRequiredUserID(w, r)
-		if !ok {
-			return
-		}
-
-		// Récupérer l'ID du défi
-		challengeID, err := getIDParam(r, "id")
-		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "ID de défi invalide")
+		// L'adresse email doit être confirmée
+		if !requireVerifiedEmail(w, db, userID) {
 			return
 		}
 
-		// Terminer le défi
-		err = database.CompleteChallenge(db, userID, challengeID)
+		// Rejoindre le défi
+		err = database.JoinChallenge(db, userID, challengeID)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
@@ -132,7 +128,7 @@ func CompleteChallenge(db *sql.DB) http.HandlerFunc {
 
 		// Répondre avec succès
 		respondWithJSON(w, http.StatusOK, map[string]string{
-			"message": "Défi terminé avec succès",
+			"message": "Vous avez rejoint le défi avec succès",
 		})
 	}
 }
@@ -177,6 +173,24 @@ func AdminCreateChallenge(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		if challengeCreate.DurationDays <= 0 {
+			respondWithError(w, http.StatusBadRequest, "La durée du défi doit être d'au moins un jour")
+			return
+		}
+
+		if !challengeCreate.StartDate.IsZero() && !challengeCreate.EndDate.IsZero() && !challengeCreate.EndDate.After(challengeCreate.StartDate) {
+			respondWithError(w, http.StatusBadRequest, "La date de fin doit être postérieure à la date de début")
+			return
+		}
+
+		// Valider les facteurs d'impact
+		impact, err := database.NormalizeImpactFactors(challengeCreate.Impact)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		challengeCreate.Impact = impact
+
 		// Créer le défi
 		challengeID, err := database.CreateChallenge(db, challengeCreate)
 		if err != nil {
@@ -215,6 +229,26 @@ func AdminUpdateChallenge(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		if challengeUpdate.DurationDays <= 0 {
+			respondWithError(w, http.StatusBadRequest, "La durée du défi doit être d'au moins un jour")
+			return
+		}
+
+		if !challengeUpdate.StartDate.IsZero() && !challengeUpdate.EndDate.IsZero() && !challengeUpdate.EndDate.After(challengeUpdate.StartDate) {
+			respondWithError(w, http.StatusBadRequest, "La date de fin doit être postérieure à la date de début")
+			return
+		}
+
+		// Valider les facteurs d'impact s'ils sont fournis
+		if challengeUpdate.Impact != nil {
+			impact, err := database.NormalizeImpactFactors(*challengeUpdate.Impact)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			*challengeUpdate.Impact = impact
+		}
+
 		// Mettre à jour le défi
 		err = database.UpdateChallenge(db, challengeID, challengeUpdate)
 		if err != nil {
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/mailer"
+	"bdd-website/internal/utils"
+)
+
+// verificationResendDelay est le délai minimum entre deux envois du lien de confirmation à un utilisateur
+const verificationResendDelay = time.Minute
+
+// VerifyEmail confirme l'adresse email d'un utilisateur à partir du lien reçu par email (?token=...)
+func VerifyEmail(db *sql.DB, jwtSecret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			respondWithError(w, http.StatusBadRequest, "Jeton de confirmation requis")
+			return
+		}
+
+		// Confirmer l'adresse
+		_, err := database.VerifyUserEmail(db, token, jwtSecret)
+		if err != nil {
+			if err == utils.ErrInvalidVerificationToken {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la confirmation de l'adresse email")
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Adresse email confirmée avec succès",
+		})
+	}
+}
+
+// ResendVerificationEmail renvoie le lien de confirmation à l'adresse de l'utilisateur connecté
+func ResendVerificationEmail(db *sql.DB, sender mailer.Sender, jwtSecret, publicURL string, ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		email, verified, sentAt, err := database.GetEmailVerificationState(db, userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération du profil")
+			return
+		}
+
+		if verified {
+			respondWithError(w, http.StatusBadRequest, "Votre adresse email est déjà confirmée")
+			return
+		}
+
+		// Limiter la fréquence des envois
+		if wait := time.Until(sentAt.Add(verificationResendDelay)); wait > 0 {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(wait.Seconds())+1))
+			respondWithError(w, http.StatusTooManyRequests, "Un lien de confirmation vient d'être envoyé, veuillez patienter avant d'en demander un nouveau")
+			return
+		}
+
+		if err := sendVerificationEmail(db, sender, userID, email, jwtSecret, publicURL, ttl); err != nil {
+			log.Printf("Erreur lors de l'envoi du lien de confirmation à l'utilisateur %d: %v", userID, err)
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de l'envoi de l'email de confirmation")
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Un nouveau lien de confirmation a été envoyé à " + email,
+		})
+	}
+}
+
+// sendVerificationEmail envoie à l'utilisateur un lien de confirmation de son adresse email, valable pendant ttl
+func sendVerificationEmail(db *sql.DB, sender mailer.Sender, userID int64, email, jwtSecret, publicURL string, ttl time.Duration) error {
+	now := time.Now()
+	token := utils.GenerateEmailVerificationToken(userID, email, now.Add(ttl), jwtSecret)
+	link := publicURL + "/verify-email?token=" + url.QueryEscape(token)
+
+	err := sender.Send(mailer.Message{
+		To:      email,
+		Subject: "Confirmez votre adresse email",
+		Body: fmt.Sprintf(
+			"Bonjour,\n\nPour confirmer votre adresse email sur le site du BDD, ouvrez le lien suivant :\n\n%s\n\n"+
+				"Ce lien est valable %s. Si vous n'êtes pas à l'origine de cette demande, ignorez cet email.\n",
+			link, formatTTL(ttl),
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	return database.MarkVerificationEmailSent(db, userID, now)
+}
+
+// requireVerifiedEmail vérifie que l'utilisateur a confirmé son adresse email.
+// Sinon, une erreur 403 est envoyée et false est retourné.
+func requireVerifiedEmail(w http.ResponseWriter, db *sql.DB, userID int64) bool {
+	verified, err := database.IsEmailVerified(db, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération du profil")
+		return false
+	}
+
+	if !verified {
+		respondWithError(w, http.StatusForbidden, database.ErrEmailNotVerified.Error())
+		return false
+	}
+
+	return true
+}
+
+// formatTTL décrit une durée de validité en heures ou en jours
+func formatTTL(ttl time.Duration) string {
+	hours := int(ttl.Hours())
+	switch {
+	case hours >= 48 && hours%24 == 0:
+		return fmt.Sprintf("%d jours", hours/24)
+	case hours > 1:
+		return fmt.Sprintf("%d heures", hours)
+	default:
+		return fmt.Sprintf("%d minutes", int(ttl.Minutes()))
+	}
+}
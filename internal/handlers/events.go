@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"bdd-website/internal/database"
+)
+
+// AdminGetEvents récupère l'état de la file des événements et les derniers événements d'un statut
+// (par défaut ceux abandonnés après échec)
+func AdminGetEvents(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = database.EventFailed
+		}
+
+		if status != database.EventPending && status != database.EventProcessed && status != database.EventFailed {
+			respondWithError(w, http.StatusBadRequest, "Statut invalide")
+			return
+		}
+
+		stats, err := database.GetEventStats(db)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des événements")
+			return
+		}
+
+		events, err := database.GetEvents(db, status, MaxPageSize)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des événements")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"stats":  stats,
+			"events": events,
+		})
+	}
+}
+
+// AdminRetryEvent remet en file un événement abandonné après échec
+func AdminRetryEvent(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'événement invalide")
+			return
+		}
+
+		if err := database.RetryEvent(db, eventID); err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Événement remis en file",
+		})
+	}
+}
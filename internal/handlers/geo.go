@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
+)
+
+// Rayon de recherche "near" (en kilomètres)
+const (
+	DefaultRadiusKm = 10
+	MaxRadiusKm     = 200
+)
+
+// Nombre maximum d'activités exportées en GeoJSON
+const MaxGeoJSONFeatures = 500
+
+// GetActivitiesGeoJSON exporte les activités géolocalisées au format GeoJSON, avec les mêmes filtres que la liste
+func GetActivitiesGeoJSON(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer les filtres et le tri
+		filter, err := parseActivityFilter(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Récupérer les activités géolocalisées (filtrées avant la limite du nombre de points)
+		filter.Located = true
+		activities, _, err := database.GetActivities(db, 1, MaxGeoJSONFeatures, filter, 0)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des activités")
+			return
+		}
+
+		// Construire la collection
+		collection := models.GeoJSONFeatureCollection{
+			Type:     "FeatureCollection",
+			Features: []models.GeoJSONFeature{},
+		}
+
+		for _, activity := range activities {
+			if activity.Latitude == nil || activity.Longitude == nil {
+				continue
+			}
+
+			properties := map[string]interface{}{
+				"title":                activity.Title,
+				"location":             activity.Location,
+				"start_date":           activity.StartDate,
+				"end_date":             activity.EndDate,
+				"category":             activity.Category,
+				"tags":                 activity.Tags,
+				"eco_points":           activity.EcoPoints,
+				"max_participants":     activity.MaxParticipants,
+				"current_participants": activity.CurrentParticipants,
+				"status":               activity.Status,
+				"image_path":           activity.ImagePath,
+			}
+			if activity.DistanceKm != nil {
+				properties["distance_km"] = *activity.DistanceKm
+			}
+
+			collection.Features = append(collection.Features, models.GeoJSONFeature{
+				Type: "Feature",
+				ID:   activity.ID,
+				Geometry: models.GeoJSONGeometry{
+					Type:        "Point",
+					Coordinates: []float64{*activity.Longitude, *activity.Latitude},
+				},
+				Properties: properties,
+			})
+		}
+
+		// Répondre avec le type MIME GeoJSON
+		response, err := json.Marshal(collection)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la génération du GeoJSON")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// parseGeoPoint analyse des coordonnées au format "lat,lng"
+func parseGeoPoint(value string) (models.GeoPoint, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return models.GeoPoint{}, errors.New("coordonnées invalides (format attendu: near=lat,lng)")
+	}
+
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lng, errLng := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errLat != nil || errLng != nil || !utils.ValidCoordinates(lat, lng) {
+		return models.GeoPoint{}, errors.New("coordonnées invalides (format attendu: near=lat,lng)")
+	}
+
+	return models.GeoPoint{Lat: lat, Lng: lng}, nil
+}
+
+// validateActivityCoordinates vérifie que la latitude et la longitude d'une activité sont fournies ensemble et valides
+func validateActivityCoordinates(lat, lng *float64) error {
+	if lat == nil && lng == nil {
+		return nil
+	}
+
+	if lat == nil || lng == nil {
+		return errors.New("latitude et longitude doivent être fournies ensemble")
+	}
+
+	if !utils.ValidCoordinates(*lat, *lng) {
+		return fmt.Errorf("coordonnées invalides (%g, %g)", *lat, *lng)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/models"
+)
+
+// ReportActivityImpact permet à un participant présent de déclarer l'impact de sa participation
+// (ex: {"impact": {"waste_kg": 3.5}} pour les déchets collectés lors d'un nettoyage)
+func ReportActivityImpact(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de l'activité
+		activityID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'activité invalide")
+			return
+		}
+
+		// Décoder le corps de la requête
+		var report models.ImpactReport
+		if err := decodeJSONBody(r, &report); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		// Enregistrer la déclaration
+		err = database.ReportActivityImpact(db, userID, activityID, report.Impact)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Récupérer les montants déclarés
+		impact, err := database.GetActivityImpactReport(db, userID, activityID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération de l'impact déclaré")
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message": "Impact enregistré avec succès",
+			"impact":  impact,
+		})
+	}
+}
+
+// GetUserImpact récupère l'évolution de l'impact environnemental de l'utilisateur connecté
+func GetUserImpact(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		respondWithImpactSeries(w, r, db, userID)
+	}
+}
+
+// AdminGetImpact récupère l'évolution de l'impact environnemental de toute l'association
+func AdminGetImpact(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondWithImpactSeries(w, r, db, 0)
+	}
+}
+
+// respondWithImpactSeries répond avec la série temporelle d'impact d'un utilisateur (0 = toute l'association).
+// Paramètres: interval (day, week ou month par défaut), from et to (AAAA-MM-JJ ou RFC 3339, to inclus).
+func respondWithImpactSeries(w http.ResponseWriter, r *http.Request, db *sql.DB, userID int64) {
+	response, err := parseImpactSeriesRange(r, time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Totals, err = database.GetImpactTotals(db, userID, response.From, response.To)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération de l'impact")
+		return
+	}
+
+	response.Series, err = database.GetImpactSeries(db, userID, response.Interval, response.From, response.To)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération de l'impact")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// parseImpactSeriesRange lit l'intervalle et la période d'une série temporelle d'impact.
+// Par défaut: les 30 derniers jours, les 12 dernières semaines ou les 12 derniers mois, jusqu'à maintenant.
+func parseImpactSeriesRange(r *http.Request, now time.Time) (*models.ImpactSeriesResponse, error) {
+	query := r.URL.Query()
+
+	response := &models.ImpactSeriesResponse{Interval: query.Get("interval"), To: now}
+	switch response.Interval {
+	case "":
+		response.Interval = database.ImpactMonthly
+	case database.ImpactDaily, database.ImpactWeekly, database.ImpactMonthly:
+	default:
+		return nil, errors.New("intervalle invalide (day, week ou month)")
+	}
+	response.From = database.ImpactSeriesStart(response.Interval, now)
+
+	if value := query.Get("from"); value != "" {
+		from, err := parseFilterDate(value, false)
+		if err != nil {
+			return nil, errors.New("date de début invalide (AAAA-MM-JJ ou RFC 3339)")
+		}
+		response.From = from
+	}
+
+	if value := query.Get("to"); value != "" {
+		to, err := parseFilterDate(value, true)
+		if err != nil {
+			return nil, errors.New("date de fin invalide (AAAA-MM-JJ ou RFC 3339)")
+		}
+		response.To = to
+	}
+
+	if response.To.Before(response.From) {
+		return nil, errors.New("la date de fin doit être postérieure à la date de début")
+	}
+
+	if response.Interval == database.ImpactDaily && response.To.Sub(response.From) > database.MaxImpactSeriesDays*24*time.Hour {
+		return nil, fmt.Errorf("une série journalière ne peut pas dépasser %d jours", database.MaxImpactSeriesDays)
+	}
+
+	return response, nil
+}
+
+// parseImpactFormValues lit les impacts déclarés dans un formulaire (champs impact_co2_kg, impact_waste_kg, impact_water_l)
+func parseImpactFormValues(r *http.Request) (map[string]float64, error) {
+	impact := make(map[string]float64)
+	for _, metric := range database.ImpactMetrics {
+		value := strings.TrimSpace(r.FormValue("impact_" + metric))
+		if value == "" {
+			continue
+		}
+
+		// Accepter la virgule décimale
+		amount, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			return nil, fmt.Errorf("montant déclaré invalide pour %s", metric)
+		}
+		impact[metric] = amount
+	}
+
+	return impact, nil
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/middleware"
+	"bdd-website/internal/models"
+)
+
+// DefaultLeaderboardRadius est le nombre de voisins affichés par défaut de part et d'autre de l'utilisateur
+const DefaultLeaderboardRadius = 5
+
+// GetLeaderboard récupère le classement des utilisateurs sur une période.
+// Si l'utilisateur est connecté, sa propre position est ajoutée à la réponse.
+func GetLeaderboard(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer les paramètres de pagination
+		page, pageSize := getPagination(r)
+
+		period, err := parseLeaderboardPeriod(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Récupérer l'ID utilisateur (optionnel)
+		userID := middleware.GetUserID(r)
+
+		entries, total, err := database.GetLeaderboard(db, period, userID, page, pageSize)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération du classement")
+			return
+		}
+
+		response := models.LeaderboardResponse{
+			Period:   period,
+			Entries:  entries,
+			Total:    total,
+			Page:     page,
+			PageSize: pageSize,
+		}
+
+		if userID != 0 {
+			me, err := database.GetLeaderboardEntry(db, period, userID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération du classement")
+				return
+			}
+			response.Me = me
+		}
+
+		respondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// GetLeaderboardNeighborhood récupère les utilisateurs classés juste avant et juste après l'utilisateur connecté
+func GetLeaderboardNeighborhood(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		period, err := parseLeaderboardPeriod(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		radius := DefaultLeaderboardRadius
+		if radiusStr := r.URL.Query().Get("radius"); radiusStr != "" {
+			radius, err = strconv.Atoi(radiusStr)
+			if err != nil || radius < 1 || radius > database.MaxLeaderboardRadius {
+				respondWithError(w, http.StatusBadRequest, "Rayon invalide (entre 1 et "+strconv.Itoa(database.MaxLeaderboardRadius)+")")
+				return
+			}
+		}
+
+		entries, err := database.GetLeaderboardNeighborhood(db, period, userID, radius)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"period":  period,
+			"entries": entries,
+		})
+	}
+}
+
+// parseLeaderboardPeriod lit la période du classement (tous les temps par défaut).
+// Une période personnalisée exige les dates from et to (AAAA-MM-JJ ou RFC 3339), to étant inclus.
+func parseLeaderboardPeriod(r *http.Request) (models.LeaderboardPeriod, error) {
+	query := r.URL.Query()
+
+	name := query.Get("period")
+	if name == "" {
+		name = database.LeaderboardAllTime
+	}
+
+	if name != database.LeaderboardCustom {
+		return database.LeaderboardPeriodFor(name, time.Now())
+	}
+
+	period := models.LeaderboardPeriod{Name: name}
+
+	from, err := parseFilterDate(query.Get("from"), false)
+	if err != nil {
+		return period, errors.New("date de début invalide (AAAA-MM-JJ ou RFC 3339)")
+	}
+
+	to, err := parseFilterDate(query.Get("to"), true)
+	if err != nil {
+		return period, errors.New("date de fin invalide (AAAA-MM-JJ ou RFC 3339)")
+	}
+
+	if to.Before(from) {
+		return period, errors.New("la date de fin doit être postérieure à la date de début")
+	}
+
+	period.From = from
+	period.To = to
+	return period, nil
+}
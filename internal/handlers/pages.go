@@ -52,11 +52,31 @@ func SignupPage(w http.ResponseWriter, r *http.Request) {
 	serveTemplate(w, r, "signup.html")
 }
 
+// ForgotPasswordPage sert la page de demande de réinitialisation du mot de passe
+func ForgotPasswordPage(w http.ResponseWriter, r *http.Request) {
+	serveTemplate(w, r, "forgot-password.html")
+}
+
+// ResetPasswordPage sert la page de choix d'un nouveau mot de passe, ouverte par le lien reçu par email
+func ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	serveTemplate(w, r, "reset-password.html")
+}
+
+// VerifyEmailPage sert la page de confirmation de l'adresse email, ouverte par le lien reçu par email
+func VerifyEmailPage(w http.ResponseWriter, r *http.Request) {
+	serveTemplate(w, r, "verify-email.html")
+}
+
 // ProfilePage sert la page de profil utilisateur
 func ProfilePage(w http.ResponseWriter, r *http.Request) {
 	serveTemplate(w, r, "profile.html")
 }
 
+// CheckinPage sert la page de pointage ouverte par le QR code d'une activité
+func CheckinPage(w http.ResponseWriter, r *http.Request) {
+	serveTemplate(w, r, "checkin.html")
+}
+
 // AdminDashboardPage sert la page de tableau de bord admin
 func AdminDashboardPage(w http.ResponseWriter, r *http.Request) {
 	serveTemplate(w, r, "admin/dashboard.html")
@@ -77,6 +97,11 @@ func AdminNewActivityPage(w http.ResponseWriter, r *http.Request) {
 	serveTemplate(w, r, "admin/new-activity.html")
 }
 
+// AdminCheckinQRCodePage sert la page d'impression du QR code de pointage d'une activité
+func AdminCheckinQRCodePage(w http.ResponseWriter, r *http.Request) {
+	serveTemplate(w, r, "admin/checkin-qr.html")
+}
+
 // AdminChallengesPage sert la page de gestion des défis admin
 func AdminChallengesPage(w http.ResponseWriter, r *http.Request) {
 	serveTemplate(w, r, "admin/challenges.html")
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/mailer"
+	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
+)
+
+// ForgotPassword envoie un lien de réinitialisation du mot de passe, valable pendant ttl.
+// La réponse est la même que l'adresse corresponde ou non à un compte.
+func ForgotPassword(db *sql.DB, sender mailer.Sender, publicURL string, ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Décoder le corps de la requête
+		var request models.ForgotPasswordRequest
+		if err := decodeJSONBody(r, &request); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		email := strings.TrimSpace(request.Email)
+		if email == "" {
+			respondWithError(w, http.StatusBadRequest, "Email requis")
+			return
+		}
+
+		token, err := database.CreatePasswordResetToken(db, email, ttl)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la demande de réinitialisation")
+			return
+		}
+
+		// Envoyer le lien en arrière-plan, pour que la durée de la réponse ne révèle pas l'existence du compte
+		if token != "" {
+			go func() {
+				link := publicURL + "/reset-password?token=" + url.QueryEscape(token)
+				err := sender.Send(mailer.Message{
+					To:      email,
+					Subject: "Réinitialisation de votre mot de passe",
+					Body: fmt.Sprintf(
+						"Bonjour,\n\nUne réinitialisation du mot de passe de votre compte BDD a été demandée. Pour choisir un nouveau mot de passe, ouvrez le lien suivant :\n\n%s\n\n"+
+							"Ce lien est valable %s et ne peut être utilisé qu'une fois. Si vous n'êtes pas à l'origine de cette demande, ignorez cet email : votre mot de passe reste inchangé.\n",
+						link, formatTTL(ttl),
+					),
+				})
+				if err != nil {
+					log.Printf("Erreur lors de l'envoi du lien de réinitialisation à %s: %v", email, err)
+				}
+			}()
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Si un compte correspond à cette adresse, un lien de réinitialisation vient d'y être envoyé",
+		})
+	}
+}
+
+// ResetPassword remplace le mot de passe avec le jeton reçu par email.
+// L'utilisateur est déconnecté de tous ses appareils et doit se reconnecter.
+func ResetPassword(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Décoder le corps de la requête
+		var request models.ResetPasswordRequest
+		if err := decodeJSONBody(r, &request); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		// Valider les données
+		if request.Token == "" || request.Password == "" {
+			respondWithError(w, http.StatusBadRequest, "Jeton et mot de passe requis")
+			return
+		}
+
+		if err := utils.ValidatePassword(request.Password); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Remplacer le mot de passe
+		err := database.ResetPassword(db, request.Token, request.Password)
+		if err != nil {
+			if err == database.ErrInvalidResetToken {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la réinitialisation du mot de passe")
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Mot de passe modifié avec succès, vous pouvez vous reconnecter",
+		})
+	}
+}
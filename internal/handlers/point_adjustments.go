@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/models"
+)
+
+// AdminGetUserEcoPoints récupère le registre complet des points d'un utilisateur
+func AdminGetUserEcoPoints(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de l'utilisateur
+		userID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'utilisateur invalide")
+			return
+		}
+
+		if _, err := database.GetUserByID(db, userID); err != nil {
+			respondWithError(w, http.StatusNotFound, "Utilisateur non trouvé")
+			return
+		}
+
+		// Récupérer les points
+		points, totalPoints, err := database.GetUserEcoPoints(db, userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des points")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, models.EcoPointsResponse{
+			Points:      points,
+			TotalPoints: totalPoints,
+		})
+	}
+}
+
+// AdminAdjustEcoPoints permet à un administrateur de créditer ou débiter des points avec un motif
+func AdminAdjustEcoPoints(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de l'utilisateur
+		userID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'utilisateur invalide")
+			return
+		}
+
+		// Décoder le corps de la requête
+		var adjustment models.EcoPointAdjustment
+		if err := decodeJSONBody(r, &adjustment); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		reason, err := database.ValidateAdjustmentReason(adjustment.Reason)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Enregistrer l'ajustement
+		pointID, err := database.AdjustEcoPoints(db, userID, adminID, adjustment.Points, reason)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message": "Ajustement de points enregistré",
+			"id":      pointID,
+		})
+	}
+}
+
+// AdminReverseEcoPoints permet à un administrateur d'annuler une écriture de points avec un motif
+func AdminReverseEcoPoints(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de l'écriture
+		pointID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'écriture invalide")
+			return
+		}
+
+		// Décoder le corps de la requête
+		var reversal models.EcoPointReversal
+		if err := decodeJSONBody(r, &reversal); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		reason, err := database.ValidateAdjustmentReason(reversal.Reason)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Enregistrer l'annulation
+		reversalID, err := database.ReverseEcoPoints(db, pointID, adminID, reason)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message": "Écriture annulée",
+			"id":      reversalID,
+		})
+	}
+}
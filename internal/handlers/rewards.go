@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/models"
+)
+
+// MaxRewardStock limite le stock d'une récompense
+const MaxRewardStock = 100000
+
+// validateReward vérifie et normalise les données d'une récompense
+func validateReward(reward *models.RewardCreate) string {
+	reward.Name = strings.TrimSpace(reward.Name)
+	reward.Description = strings.TrimSpace(reward.Description)
+	reward.ImagePath = strings.TrimSpace(reward.ImagePath)
+	reward.Partner = strings.TrimSpace(reward.Partner)
+
+	if reward.Name == "" || reward.Description == "" {
+		return "Nom et description obligatoires"
+	}
+
+	if reward.Cost <= 0 {
+		return "Le coût doit être positif"
+	}
+
+	if reward.Stock < 0 || reward.Stock > MaxRewardStock {
+		return "Stock invalide"
+	}
+
+	return ""
+}
+
+// GetRewards récupère le catalogue des récompenses disponibles
+func GetRewards(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rewards, err := database.GetRewards(db, true)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des récompenses")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, rewards)
+	}
+}
+
+// RedeemReward échange des points de l'utilisateur contre une récompense
+func RedeemReward(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de la récompense
+		rewardID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de récompense invalide")
+			return
+		}
+
+		redemption, err := database.RedeemReward(db, userID, rewardID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message":    "Récompense échangée, présentez ce code pour la retirer",
+			"redemption": redemption,
+		})
+	}
+}
+
+// GetUserRedemptions récupère les échanges de récompenses de l'utilisateur
+func GetUserRedemptions(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		redemptions, err := database.GetRewardRedemptions(db, userID, "", "")
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des échanges")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, redemptions)
+	}
+}
+
+// AdminGetRewards récupère toutes les récompenses, y compris celles désactivées
+func AdminGetRewards(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rewards, err := database.GetRewards(db, false)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des récompenses")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, rewards)
+	}
+}
+
+// AdminCreateReward permet à un administrateur d'ajouter une récompense au catalogue
+func AdminCreateReward(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Décoder le corps de la requête
+		var reward models.RewardCreate
+		if err := decodeJSONBody(r, &reward); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		// Valider les données
+		if msg := validateReward(&reward); msg != "" {
+			respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		rewardID, err := database.CreateReward(db, reward)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message": "Récompense créée avec succès",
+			"id":      rewardID,
+		})
+	}
+}
+
+// AdminUpdateReward permet à un administrateur de modifier une récompense (coût, stock, disponibilité...)
+func AdminUpdateReward(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de la récompense
+		rewardID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de récompense invalide")
+			return
+		}
+
+		// Décoder le corps de la requête
+		var reward models.RewardCreate
+		if err := decodeJSONBody(r, &reward); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		// Valider les données
+		if msg := validateReward(&reward); msg != "" {
+			respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		if err := database.UpdateReward(db, rewardID, reward); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Récompense mise à jour avec succès",
+		})
+	}
+}
+
+// AdminDeleteReward permet à un administrateur de supprimer une récompense jamais échangée
+func AdminDeleteReward(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de la récompense
+		rewardID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de récompense invalide")
+			return
+		}
+
+		if err := database.DeleteReward(db, rewardID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Récompense supprimée avec succès",
+		})
+	}
+}
+
+// AdminGetRewardRedemptions récupère les échanges de récompenses, filtrables par statut et par code
+func AdminGetRewardRedemptions(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		if status != "" && status != database.RedemptionPending &&
+			status != database.RedemptionDelivered && status != database.RedemptionCancelled {
+			respondWithError(w, http.StatusBadRequest, "Statut invalide (pending, delivered ou cancelled)")
+			return
+		}
+
+		code := strings.TrimSpace(r.URL.Query().Get("code"))
+
+		redemptions, err := database.GetRewardRedemptions(db, 0, status, code)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des échanges")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, redemptions)
+	}
+}
+
+// AdminDeliverRewardRedemption marque un échange comme remis à l'utilisateur
+func AdminDeliverRewardRedemption(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de l'échange
+		redemptionID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'échange invalide")
+			return
+		}
+
+		if err := database.DeliverRewardRedemption(db, redemptionID, adminID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Récompense remise",
+		})
+	}
+}
+
+// AdminCancelRewardRedemption annule un échange non remis, rembourse les points et remet la récompense en stock
+func AdminCancelRewardRedemption(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer l'ID de l'échange
+		redemptionID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'échange invalide")
+			return
+		}
+
+		if err := database.CancelRewardRedemption(db, redemptionID, adminID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Échange annulé, points remboursés",
+		})
+	}
+}
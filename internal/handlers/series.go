@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/mailer"
+	"bdd-website/internal/models"
+)
+
+// AdminCreateActivitySeries permet à un administrateur de créer une série d'activités récurrentes
+func AdminCreateActivitySeries(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Décoder le corps de la requête
+		var seriesCreate models.ActivitySeriesCreate
+		if err := json.NewDecoder(r.Body).Decode(&seriesCreate); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		// Valider les données
+		if seriesCreate.Title == "" || seriesCreate.Description == "" {
+			respondWithError(w, http.StatusBadRequest, "Titre et description obligatoires")
+			return
+		}
+
+		// Valider la catégorie et normaliser les tags
+		if err := normalizeActivityClassification(&seriesCreate.Category, &seriesCreate.Tags); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Valider les coordonnées du lieu
+		if err := validateActivityCoordinates(seriesCreate.Latitude, seriesCreate.Longitude); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Valider les facteurs d'impact
+		impact, err := database.NormalizeImpactFactors(seriesCreate.Impact)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		seriesCreate.Impact = impact
+
+		if seriesCreate.RRule == "" {
+			respondWithError(w, http.StatusBadRequest, "Règle de récurrence obligatoire")
+			return
+		}
+
+		if seriesCreate.StartDate.IsZero() || seriesCreate.EndDate.Before(seriesCreate.StartDate) {
+			respondWithError(w, http.StatusBadRequest, "Dates de la première occurrence invalides")
+			return
+		}
+
+		// Créer la série et ses occurrences
+		seriesID, err := database.CreateActivitySeries(db, seriesCreate)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Récupérer la série créée
+		series, err := database.GetActivitySeries(db, seriesID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération de la série")
+			return
+		}
+
+		// Répondre avec la série créée
+		respondWithJSON(w, http.StatusCreated, series)
+	}
+}
+
+// AdminGetActivitySeries permet à un administrateur de consulter une série et ses occurrences
+func AdminGetActivitySeries(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de la série
+		seriesID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de série invalide")
+			return
+		}
+
+		// Récupérer la série
+		series, err := database.GetActivitySeries(db, seriesID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		// Répondre avec la série
+		respondWithJSON(w, http.StatusOK, series)
+	}
+}
+
+// AdminCancelActivitySeries permet à un administrateur d'annuler une série et ses occurrences à venir
+func AdminCancelActivitySeries(db *sql.DB, sender mailer.Sender) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de la série
+		seriesID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de série invalide")
+			return
+		}
+
+		// Annuler la série
+		cancelled, registrations, err := database.CancelActivitySeries(db, seriesID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		// Prévenir les inscrits des occurrences annulées
+		go notifyActivityCancellations(sender, registrations)
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message":               "Série annulée avec succès",
+			"cancelled_occurrences": cancelled,
+		})
+	}
+}
+
+// notifyActivityCancellations prévient par email les inscrits des activités annulées
+func notifyActivityCancellations(sender mailer.Sender, registrations []models.CancelledRegistration) {
+	for _, registration := range registrations {
+		err := sender.Send(mailer.Message{
+			To:      registration.Email,
+			Subject: "Activité annulée : " + registration.Title,
+			Body: fmt.Sprintf(
+				"Bonjour %s,\n\nL'activité « %s » prévue le %s est annulée. Votre inscription est donc sans objet.\n\n"+
+					"Retrouvez les autres activités du BDD sur le site.\n",
+				registration.Username, registration.Title, registration.StartDate.In(time.Local).Format("02/01/2006 à 15h04"),
+			),
+		})
+		if err != nil {
+			log.Printf("Erreur lors de l'envoi de l'annulation de l'activité %d à %s: %v", registration.ActivityID, registration.Email, err)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bdd-website/internal/database"
+	"bdd-website/internal/models"
+)
+
+// Limites des équipes
+const (
+	MaxTeamNameLength        = 50
+	MaxTeamDescriptionLength = 500
+	DefaultTeamLeaderboard   = 20
+)
+
+// validateTeam nettoie et valide les données d'une équipe
+func validateTeam(team *models.TeamCreate) string {
+	team.Name = strings.TrimSpace(team.Name)
+	team.Description = strings.TrimSpace(team.Description)
+
+	if team.Name == "" {
+		return "Le nom de l'équipe est obligatoire"
+	}
+	if len([]rune(team.Name)) > MaxTeamNameLength {
+		return "Le nom de l'équipe ne doit pas dépasser 50 caractères"
+	}
+	if len([]rune(team.Description)) > MaxTeamDescriptionLength {
+		return "La description ne doit pas dépasser 500 caractères"
+	}
+
+	return ""
+}
+
+// GetTeams récupère la liste des équipes
+func GetTeams(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		teams, err := database.GetTeams(db)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération des équipes")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, teams)
+	}
+}
+
+// GetTeam récupère une équipe et ses membres
+func GetTeam(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		teamID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'équipe invalide")
+			return
+		}
+
+		team, err := database.GetTeam(db, teamID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, team)
+	}
+}
+
+// GetTeamLeaderboard récupère le classement des équipes.
+// Le paramètre challenge_id restreint le classement aux points gagnés sur un défi.
+func GetTeamLeaderboard(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var challengeID int64
+		if value := r.URL.Query().Get("challenge_id"); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				respondWithError(w, http.StatusBadRequest, "ID de défi invalide")
+				return
+			}
+			challengeID = id
+		}
+
+		limit := DefaultTeamLeaderboard
+		if value := r.URL.Query().Get("limit"); value != "" {
+			l, err := strconv.Atoi(value)
+			if err != nil || l <= 0 {
+				respondWithError(w, http.StatusBadRequest, "Limite invalide")
+				return
+			}
+			if l > MaxPageSize {
+				l = MaxPageSize
+			}
+			limit = l
+		}
+
+		entries, err := database.GetTeamLeaderboard(db, challengeID, limit)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération du classement")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, entries)
+	}
+}
+
+// CreateTeam crée une équipe dont l'utilisateur devient le capitaine
+func CreateTeam(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		var team models.TeamCreate
+		if err := decodeJSONBody(r, &team); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		if msg := validateTeam(&team); msg != "" {
+			respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		teamID, err := database.CreateTeam(db, userID, team)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message": "Équipe créée avec succès",
+			"id":      teamID,
+		})
+	}
+}
+
+// UpdateTeam met à jour une équipe (réservé au capitaine)
+func UpdateTeam(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		teamID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'équipe invalide")
+			return
+		}
+
+		var team models.TeamCreate
+		if err := decodeJSONBody(r, &team); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		if msg := validateTeam(&team); msg != "" {
+			respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		if err := database.UpdateTeam(db, teamID, userID, team); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Équipe mise à jour avec succès",
+		})
+	}
+}
+
+// JoinTeam permet à un utilisateur de rejoindre une équipe
+func JoinTeam(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		teamID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'équipe invalide")
+			return
+		}
+
+		if err := database.JoinTeam(db, userID, teamID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Vous avez rejoint l'équipe avec succès",
+		})
+	}
+}
+
+// LeaveTeam permet à un utilisateur de quitter son équipe
+func LeaveTeam(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		teamID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'équipe invalide")
+			return
+		}
+
+		if err := database.LeaveTeam(db, userID, teamID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Vous avez quitté l'équipe",
+		})
+	}
+}
+
+// RemoveTeamMember permet au capitaine de retirer un membre de l'équipe
+func RemoveTeamMember(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		teamID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'équipe invalide")
+			return
+		}
+
+		memberID, err := getIDParam(r, "userId")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'utilisateur invalide")
+			return
+		}
+
+		if err := database.RemoveTeamMember(db, teamID, userID, memberID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Membre retiré de l'équipe",
+		})
+	}
+}
+
+// TransferTeamCaptain permet au capitaine de désigner un nouveau capitaine
+func TransferTeamCaptain(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		teamID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'équipe invalide")
+			return
+		}
+
+		var transfer models.TeamCaptainTransfer
+		if err := decodeJSONBody(r, &transfer); err != nil || transfer.UserID <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Format de requête invalide")
+			return
+		}
+
+		if err := database.TransferTeamCaptain(db, teamID, userID, transfer.UserID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Nouveau capitaine désigné",
+		})
+	}
+}
+
+// GetTeamChallengeProgress récupère la progression des équipes sur un défi en équipe
+func GetTeamChallengeProgress(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		challengeID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID de défi invalide")
+			return
+		}
+
+		progress, err := database.GetTeamChallengeProgress(db, challengeID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, progress)
+	}
+}
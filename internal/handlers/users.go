@@ -3,10 +3,14 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
+	"time"
 
 	"bdd-website/internal/database"
+	"bdd-website/internal/mailer"
 	"bdd-website/internal/models"
+	"bdd-website/internal/utils"
 )
 
 // GetUserProfile récupère le profil de l'utilisateur actuellement connecté
@@ -30,8 +34,9 @@ func GetUserProfile(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// UpdateUserProfile met à jour le profil de l'utilisateur
-func UpdateUserProfile(db *sql.DB) http.HandlerFunc {
+// UpdateUserProfile met à jour le profil de l'utilisateur.
+// Une nouvelle adresse email doit être confirmée: le lien de confirmation lui est envoyé.
+func UpdateUserProfile(db *sql.DB, sender mailer.Sender, jwtSecret, publicURL string, ttl time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Récupérer l'ID utilisateur du contexte
 		userID, ok := getRequiredUserID(w, r)
@@ -47,18 +52,40 @@ func UpdateUserProfile(db *sql.DB) http.HandlerFunc {
 		}
 
 		// Valider les données (au moins un champ à mettre à jour)
-		if profileUpdate.Username == "" && profileUpdate.Email == "" && profileUpdate.Password == "" {
+		if profileUpdate.Username == "" && profileUpdate.Email == "" && profileUpdate.Password == "" &&
+			profileUpdate.HideFromLeaderboard == nil {
 			respondWithError(w, http.StatusBadRequest, "Aucun champ à mettre à jour")
 			return
 		}
 
+		if profileUpdate.Password != "" {
+			if err := utils.ValidatePassword(profileUpdate.Password); err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+
+		// Adresse actuelle, pour détecter un changement d'adresse
+		previousEmail, _, _, err := database.GetEmailVerificationState(db, userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération du profil")
+			return
+		}
+
 		// Mettre à jour le profil
-		err := database.UpdateUserProfile(db, userID, profileUpdate)
+		err = database.UpdateUserProfile(db, userID, profileUpdate)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
+		// Envoyer le lien de confirmation à la nouvelle adresse
+		if profileUpdate.Email != "" && profileUpdate.Email != previousEmail {
+			if err := sendVerificationEmail(db, sender, userID, profileUpdate.Email, jwtSecret, publicURL, ttl); err != nil {
+				log.Printf("Erreur lors de l'envoi du lien de confirmation à l'utilisateur %d: %v", userID, err)
+			}
+		}
+
 		// Récupérer le profil mis à jour
 		profile, err := database.GetUserProfile(db, userID)
 		if err != nil {
@@ -93,3 +120,79 @@ func AdminGetUsers(db *sql.DB) http.HandlerFunc {
 		})
 	}
 }
+
+// AdminRevokeUserSessions permet à un administrateur de déconnecter un utilisateur de tous ses appareils
+func AdminRevokeUserSessions(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de l'utilisateur
+		userID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'utilisateur invalide")
+			return
+		}
+
+		// Révoquer les sessions
+		err = database.RevokeUserSessions(db, userID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Toutes les sessions de l'utilisateur ont été révoquées",
+		})
+	}
+}
+
+// AdminUnlockUser permet à un administrateur de lever le verrouillage du compte d'un utilisateur
+// et des adresses IP de ses échecs de connexion récents
+func AdminUnlockUser(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID de l'utilisateur
+		userID, err := getIDParam(r, "id")
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "ID d'utilisateur invalide")
+			return
+		}
+
+		// Lever le verrouillage
+		err = database.UnlockUserLogin(db, userID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Répondre avec succès
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Le compte de l'utilisateur et les adresses IP de ses derniers échecs ont été déverrouillés",
+		})
+	}
+}
+
+// GetLoginHistory récupère les connexions récentes (réussies et échouées) de l'utilisateur connecté
+func GetLoginHistory(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Récupérer l'ID utilisateur du contexte
+		userID, ok := getRequiredUserID(w, r)
+		if !ok {
+			return
+		}
+
+		// Récupérer les paramètres de pagination
+		page, pageSize := getPagination(r)
+
+		attempts, total, err := database.GetLoginHistory(db, userID, page, pageSize)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erreur lors de la récupération de l'historique des connexions")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"logins":    attempts,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		})
+	}
+}
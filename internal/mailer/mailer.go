@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message représente un email en texte brut
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender est implémenté par les modes d'envoi des emails
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTPSender envoie les emails par un serveur SMTP (STARTTLS si le serveur le propose)
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string // Authentification PLAIN si renseigné
+	Password string
+	From     string
+}
+
+// Send envoie un email par le serveur SMTP
+func (s *SMTPSender) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	return smtp.SendMail(addr, auth, s.From, []string{msg.To}, formatMessage(s.From, msg, time.Now()))
+}
+
+// FileSender écrit les emails dans des fichiers .eml au lieu de les envoyer,
+// pour le développement local et les tests. Sans répertoire, les emails sont seulement journalisés.
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+// Send écrit l'email dans le répertoire et le journalise
+func (s *FileSender) Send(msg Message) error {
+	now := time.Now()
+	if s.Dir == "" {
+		log.Printf("Email pour %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102-150405.000000000"), sanitizeFileName(msg.To))
+	path := filepath.Join(s.Dir, name)
+	if err := os.WriteFile(path, formatMessage(s.From, msg, now), 0644); err != nil {
+		return err
+	}
+
+	log.Printf("Email pour %s écrit dans %s", msg.To, path)
+	return nil
+}
+
+// formatMessage construit un email au format RFC 5322
+func formatMessage(from string, msg Message, now time.Time) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + headerValue(from) + "\r\n")
+	b.WriteString("To: " + headerValue(msg.To) + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", headerValue(msg.Subject)) + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue retire les retours à la ligne d'une valeur d'en-tête (injection d'en-têtes)
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// sanitizeFileName remplace les caractères d'une adresse qui ne conviennent pas à un nom de fichier
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
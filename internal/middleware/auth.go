@@ -2,9 +2,13 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
 	"net/http"
 	"strings"
 
+	"bdd-website/internal/database"
 	"bdd-website/internal/utils"
 )
 
@@ -16,14 +20,42 @@ const (
 	IsAdminKey contextKey = "is_admin"
 )
 
-// Auth est un middleware pour vérifier l'authentification par JWT
-func Auth(jwtSecret string) func(http.Handler) http.Handler {
+// Cookies et en-tête de l'authentification par session
+const (
+	SessionCookieName = "bdd_session" // Jeton de session (HttpOnly)
+	CSRFCookieName    = "bdd_csrf"    // Jeton CSRF, lisible par le JavaScript de la page
+	CSRFHeaderName    = "X-CSRF-Token"
+)
+
+// Auth est un middleware pour vérifier l'authentification par JWT (header Authorization)
+// ou par cookie de session. Les requêtes modifiantes authentifiées par cookie
+// doivent renvoyer le jeton CSRF de la session dans l'en-tête X-CSRF-Token.
+// Le rôle administrateur est relu en base et les JWT émis avant une révocation sont refusés.
+func Auth(jwtSecret string, db *sql.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extraire le token du header Authorization
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+				// À défaut, utiliser le cookie de session
+				cookie, err := r.Cookie(SessionCookieName)
+				if err != nil {
+					http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+					return
+				}
+
+				session, err := database.GetSession(db, cookie.Value)
+				if err != nil {
+					http.Error(w, "Invalid or expired session", http.StatusUnauthorized)
+					return
+				}
+
+				if !checkCSRF(r, session.CSRFToken) {
+					http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(withUser(r.Context(), session.UserID, session.IsAdmin)))
 				return
 			}
 
@@ -43,16 +75,95 @@ func Auth(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Ajouter les informations utilisateur au contexte de la requête
-			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
-			ctx = context.WithValue(ctx, IsAdminKey, claims.IsAdmin)
+			// Vérifier que le token n'a pas été révoqué
+			isAdmin, err := checkRevocation(db, claims)
+			if err != nil {
+				http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+				return
+			}
 
 			// Passer au gestionnaire suivant avec le contexte mis à jour
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r.WithContext(withUser(r.Context(), claims.UserID, isAdmin)))
+		})
+	}
+}
+
+// OptionalAuth est un middleware qui identifie l'utilisateur si un JWT ou un cookie de session valide est fourni,
+// sans rejeter les requêtes anonymes
+func OptionalAuth(jwtSecret string, db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Format du token: "Bearer <token>"
+			bearerToken := strings.Split(r.Header.Get("Authorization"), " ")
+			if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+				// Ignorer une session invalide ou sans jeton CSRF: la requête reste anonyme
+				cookie, err := r.Cookie(SessionCookieName)
+				if err != nil {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				session, err := database.GetSession(db, cookie.Value)
+				if err != nil || !checkCSRF(r, session.CSRFToken) {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(withUser(r.Context(), session.UserID, session.IsAdmin)))
+				return
+			}
+
+			// Ignorer un token invalide, expiré ou révoqué: la requête reste anonyme
+			claims, err := utils.ValidateToken(bearerToken[1], jwtSecret)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			isAdmin, err := checkRevocation(db, claims)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withUser(r.Context(), claims.UserID, isAdmin)))
 		})
 	}
 }
 
+// withUser ajoute les informations utilisateur au contexte de la requête
+func withUser(ctx context.Context, userID int64, isAdmin bool) context.Context {
+	ctx = context.WithValue(ctx, UserIDKey, userID)
+	return context.WithValue(ctx, IsAdminKey, isAdmin)
+}
+
+// checkRevocation refuse un JWT émis avant la révocation des sessions de son utilisateur
+// et retourne le rôle administrateur actuel de l'utilisateur
+func checkRevocation(db *sql.DB, claims *utils.Claims) (bool, error) {
+	isAdmin, revokedAt, err := database.GetUserTokenState(db, claims.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	if !revokedAt.IsZero() && (claims.IssuedAt == nil || !claims.IssuedAt.Time.After(revokedAt)) {
+		return false, errors.New("jeton révoqué")
+	}
+
+	return isAdmin, nil
+}
+
+// checkCSRF vérifie le jeton CSRF d'une requête authentifiée par cookie.
+// Les méthodes sans effet (GET, HEAD, OPTIONS) n'en ont pas besoin.
+func checkCSRF(r *http.Request, expected string) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	token := r.Header.Get(CSRFHeaderName)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
 // GetUserID récupère l'ID utilisateur depuis le contexte
 func GetUserID(r *http.Request) int64 {
 	userID, ok := r.Context().Value(UserIDKey).(int64)
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitWindow compte les requêtes d'un client sur la fenêtre en cours
+type rateLimitWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimit est un middleware qui limite le nombre de requêtes par adresse IP:
+// au plus limit requêtes par fenêtre de durée window. Les compteurs sont conservés en mémoire.
+func RateLimit(limit int, window time.Duration) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	clients := make(map[string]*rateLimitWindow)
+	lastCleanup := time.Now()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now()
+			key := ClientIP(r)
+
+			mu.Lock()
+			// Oublier régulièrement les fenêtres terminées
+			if now.Sub(lastCleanup) > window {
+				for k, c := range clients {
+					if now.Sub(c.start) >= window {
+						delete(clients, k)
+					}
+				}
+				lastCleanup = now
+			}
+
+			c, exists := clients[key]
+			if !exists || now.Sub(c.start) >= window {
+				c = &rateLimitWindow{start: now}
+				clients[key] = c
+			}
+			c.count++
+			allowed := c.count <= limit
+			retryAfter := c.start.Add(window).Sub(now)
+			mu.Unlock()
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP retourne l'adresse IP du client à l'origine de la requête
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
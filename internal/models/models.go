@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -12,6 +13,8 @@ type User struct {
 	Password  string    `json:"-"` // Jamais envoyé dans les réponses JSON
 	IsAdmin   bool      `json:"is_admin"`
 	CreatedAt time.Time `json:"created_at"`
+
+	EmailVerified bool `json:"email_verified"` // Adresse email confirmée
 }
 
 // UserRegister représente les données requises pour l'inscription d'un utilisateur
@@ -25,6 +28,7 @@ type UserRegister struct {
 type UserLogin struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Session  bool   `json:"session"` // Ouvrir aussi une session de navigateur (cookies)
 }
 
 // UserProfile représente les données du profil utilisateur exposées à l'API
@@ -37,60 +41,170 @@ type UserProfile struct {
 	TotalEcoPoints int       `json:"total_eco_points"`
 	ActivityCount  int       `json:"activity_count"`
 	BadgeCount     int       `json:"badge_count"`
+
+	HideFromLeaderboard bool `json:"hide_from_leaderboard"` // Nom masqué dans les classements
+	EmailVerified       bool `json:"email_verified"`        // Adresse email confirmée
 }
 
 // UserProfileUpdate représente les données modifiables du profil utilisateur
 type UserProfileUpdate struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password,omitempty"` // Optionnel
+	Username            string `json:"username"`
+	Email               string `json:"email"`
+	Password            string `json:"password,omitempty"`              // Optionnel
+	HideFromLeaderboard *bool  `json:"hide_from_leaderboard,omitempty"` // Optionnel
 }
 
 // UserResponse représente la réponse après authentification
 type UserResponse struct {
-	User  UserProfile `json:"user"`
-	Token string      `json:"token"`
+	User         UserProfile `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    int         `json:"expires_in"`           // Durée de validité du jeton d'accès, en secondes
+	CSRFToken    string      `json:"csrf_token,omitempty"` // Jeton CSRF de la session ouverte par cookie
+}
+
+// RefreshTokenRequest représente un jeton de renouvellement envoyé pour obtenir un nouveau jeton d'accès
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ForgotPasswordRequest représente une demande de réinitialisation du mot de passe
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest représente le nouveau mot de passe choisi avec le jeton reçu par email
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// LoginAttempt représente une connexion, réussie ou échouée, de l'historique d'un utilisateur
+type LoginAttempt struct {
+	ID        int64     `json:"id"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TokenResponse représente la réponse du renouvellement des jetons
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Durée de validité du jeton d'accès, en secondes
 }
 
 // Activity représente une activité ou un événement du BDD
 type Activity struct {
-	ID                  int64     `json:"id"`
-	Title               string    `json:"title"`
-	Description         string    `json:"description"`
-	ImagePath           string    `json:"image_path"`
-	StartDate           time.Time `json:"start_date"`
-	EndDate             time.Time `json:"end_date"`
-	Location            string    `json:"location"`
-	MaxParticipants     int       `json:"max_participants"`
-	EcoPoints           int       `json:"eco_points"`
-	CurrentParticipants int       `json:"current_participants,omitempty"`
-	UserRegistered      bool      `json:"user_registered,omitempty"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	ID                  int64          `json:"id"`
+	Title               string         `json:"title"`
+	Description         string         `json:"description"`
+	ImagePath           string         `json:"image_path"`
+	StartDate           time.Time      `json:"start_date"`
+	EndDate             time.Time      `json:"end_date"`
+	Location            string         `json:"location"`
+	Latitude            *float64       `json:"latitude,omitempty"`
+	Longitude           *float64       `json:"longitude,omitempty"`
+	DistanceKm          *float64       `json:"distance_km,omitempty"` // Distance au point de recherche "near"
+	MaxParticipants     int            `json:"max_participants"`
+	EcoPoints           int            `json:"eco_points"`
+	Category            string         `json:"category"` // 'cleanup', 'workshop', 'conference', 'planting', 'outing', 'other'
+	Tags                []string       `json:"tags"`
+	Impact              []ImpactFactor `json:"impact,omitempty"` // Facteurs d'impact (détail de l'activité)
+	CurrentParticipants int            `json:"current_participants,omitempty"`
+	UserRegistered      bool           `json:"user_registered,omitempty"`
+	WaitlistCount       int            `json:"waitlist_count"`
+	WaitlistPosition    int            `json:"waitlist_position,omitempty"` // Position de l'utilisateur sur la liste d'attente
+	SeriesID            int64          `json:"series_id,omitempty"`
+	Status              string         `json:"status"`   // 'scheduled', 'cancelled'
+	Revision            int            `json:"revision"` // Incrémentée à chaque modification (SEQUENCE iCalendar)
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
 }
 
 // ActivityCreate représente les données pour créer une nouvelle activité
 type ActivityCreate struct {
-	Title           string    `json:"title"`
-	Description     string    `json:"description"`
-	ImagePath       string    `json:"image_path"`
-	StartDate       time.Time `json:"start_date"`
-	EndDate         time.Time `json:"end_date"`
-	Location        string    `json:"location"`
-	MaxParticipants int       `json:"max_participants"`
-	EcoPoints       int       `json:"eco_points"`
+	Title           string         `json:"title"`
+	Description     string         `json:"description"`
+	ImagePath       string         `json:"image_path"`
+	StartDate       time.Time      `json:"start_date"`
+	EndDate         time.Time      `json:"end_date"`
+	Location        string         `json:"location"`
+	Latitude        *float64       `json:"latitude,omitempty"`
+	Longitude       *float64       `json:"longitude,omitempty"`
+	MaxParticipants int            `json:"max_participants"`
+	EcoPoints       int            `json:"eco_points"`
+	Category        string         `json:"category"`
+	Tags            []string       `json:"tags"`
+	Impact          []ImpactFactor `json:"impact"`
 }
 
 // ActivityUpdate représente les données pour mettre à jour une activité
 type ActivityUpdate struct {
-	Title           string    `json:"title"`
-	Description     string    `json:"description"`
-	ImagePath       string    `json:"image_path"`
-	StartDate       time.Time `json:"start_date"`
-	EndDate         time.Time `json:"end_date"`
-	Location        string    `json:"location"`
-	MaxParticipants int       `json:"max_participants"`
-	EcoPoints       int       `json:"eco_points"`
+	Title           string          `json:"title"`
+	Description     string          `json:"description"`
+	ImagePath       string          `json:"image_path"`
+	StartDate       time.Time       `json:"start_date"`
+	EndDate         time.Time       `json:"end_date"`
+	Location        string          `json:"location"`
+	Latitude        *float64        `json:"latitude,omitempty"`
+	Longitude       *float64        `json:"longitude,omitempty"`
+	MaxParticipants int             `json:"max_participants"`
+	EcoPoints       int             `json:"eco_points"`
+	Category        string          `json:"category"`
+	Tags            *[]string       `json:"tags"`   // Absent: tags inchangés
+	Impact          *[]ImpactFactor `json:"impact"` // Absent: facteurs d'impact inchangés
+}
+
+// ActivityFilter représente les critères de filtrage et de tri de la liste des activités
+type ActivityFilter struct {
+	Upcoming     bool      // Uniquement les activités non terminées
+	Category     string    // Catégorie exacte
+	Tag          string    // Tag porté par l'activité
+	Location     string    // Sous-chaîne du lieu
+	From         time.Time // Activités se terminant après cette date
+	To           time.Time // Activités commençant avant cette date
+	HasFreeSeats bool      // Uniquement les activités avec des places disponibles
+	Located      bool      // Uniquement les activités géolocalisées
+	Near         *GeoPoint // Uniquement les activités géolocalisées autour de ce point, triées par distance
+	RadiusKm     float64   // Rayon de recherche autour de Near
+	Sort         string    // 'date', 'popularity', 'points'
+
+	IncludeCancelled bool // Inclure les activités annulées (flux iCalendar, qui les publient comme annulées)
+}
+
+// GeoPoint représente des coordonnées géographiques
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// ActivitySeries représente une série d'activités récurrentes
+type ActivitySeries struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	RRule       string     `json:"rrule"`
+	IsCancelled bool       `json:"is_cancelled"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Occurrences []Activity `json:"occurrences"`
+}
+
+// ActivitySeriesCreate représente les données pour créer une série d'activités récurrentes.
+// Les champs de l'activité décrivent la première occurrence.
+type ActivitySeriesCreate struct {
+	ActivityCreate
+	RRule string `json:"rrule"` // Ex: 'FREQ=WEEKLY;INTERVAL=1;UNTIL=20261231' ou 'FREQ=MONTHLY;COUNT=6'
+}
+
+// CancelledRegistration représente l'inscription d'un utilisateur à une activité annulée, à prévenir par email
+type CancelledRegistration struct {
+	Email      string
+	Username   string
+	ActivityID int64
+	Title      string
+	StartDate  time.Time
 }
 
 // ActivitiesResponse représente la réponse de la liste des activités
@@ -101,6 +215,48 @@ type ActivitiesResponse struct {
 	PageSize   int        `json:"page_size"`
 }
 
+// GeoJSONFeatureCollection représente une collection d'entités GeoJSON (RFC 7946)
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"` // Toujours 'FeatureCollection'
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature représente une entité GeoJSON ponctuelle
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"` // Toujours 'Feature'
+	ID         int64                  `json:"id"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONGeometry représente une géométrie GeoJSON de type Point
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`        // Toujours 'Point'
+	Coordinates []float64 `json:"coordinates"` // [longitude, latitude]
+}
+
+// Attendance représente la présence d'un inscrit à une activité
+type Attendance struct {
+	UserID        int64     `json:"user_id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	ActivityID    int64     `json:"activity_id"`
+	Status        string    `json:"status"` // 'unmarked', 'present', 'absent'
+	PointsAwarded bool      `json:"points_awarded"`
+	MarkedAt      time.Time `json:"marked_at,omitempty"`
+}
+
+// AttendanceUpdate représente le statut de présence à enregistrer pour un inscrit
+type AttendanceUpdate struct {
+	UserID int64  `json:"user_id"`
+	Status string `json:"status"` // 'present', 'absent'
+}
+
+// AttendanceRequest représente les données pour saisir la présence des inscrits
+type AttendanceRequest struct {
+	Attendances []AttendanceUpdate `json:"attendances"`
+}
+
 // ContactMessage représente un message envoyé via le formulaire de contact
 type ContactMessage struct {
 	ID          int64     `json:"id"`
@@ -137,11 +293,27 @@ type EcoPoint struct {
 	ChallengeID    int64     `json:"challenge_id,omitempty"`
 	Points         int       `json:"points"`
 	Description    string    `json:"description"`
+	Kind           string    `json:"kind"` // 'earned', 'adjustment', 'reversal', 'redemption', 'refund'
+	CreatedBy      int64     `json:"created_by,omitempty"`
+	CreatedByName  string    `json:"created_by_name,omitempty"`
+	ReversesID     int64     `json:"reverses_id,omitempty"`    // Écriture annulée par celle-ci
+	ReversedByID   int64     `json:"reversed_by_id,omitempty"` // Écriture qui annule celle-ci
 	Date           time.Time `json:"date"`
 	ActivityTitle  string    `json:"activity_title,omitempty"`
 	ChallengeTitle string    `json:"challenge_title,omitempty"`
 }
 
+// EcoPointAdjustment représente un crédit (points positifs) ou un débit (points négatifs) manuel
+type EcoPointAdjustment struct {
+	Points int    `json:"points"`
+	Reason string `json:"reason"`
+}
+
+// EcoPointReversal représente l'annulation d'une écriture de points
+type EcoPointReversal struct {
+	Reason string `json:"reason"`
+}
+
 // EcoPointsResponse représente la réponse pour les points écologiques d'un utilisateur
 type EcoPointsResponse struct {
 	Points      []EcoPoint `json:"points"`
@@ -150,40 +322,116 @@ type EcoPointsResponse struct {
 
 // Challenge représente un défi écologique
 type Challenge struct {
-	ID           int64     `json:"id"`
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	Points       int       `json:"points"`
-	DurationDays int       `json:"duration_days"`
-	StartDate    time.Time `json:"start_date,omitempty"`
-	EndDate      time.Time `json:"end_date,omitempty"`
-	IsActive     bool      `json:"is_active"`
-	CreatedAt    time.Time `json:"created_at"`
-	UserStatus   string    `json:"user_status,omitempty"` // 'not_joined', 'in_progress', 'completed', 'abandoned'
-	JoinedAt     time.Time `json:"joined_at,omitempty"`
-	CompletedAt  time.Time `json:"completed_at,omitempty"`
+	ID                   int64          `json:"id"`
+	Title                string         `json:"title"`
+	Description          string         `json:"description"`
+	Points               int            `json:"points"`
+	DurationDays         int            `json:"duration_days"`
+	StartDate            time.Time      `json:"start_date,omitempty"`
+	EndDate              time.Time      `json:"end_date,omitempty"`
+	IsActive             bool           `json:"is_active"`
+	RequiresDailyCheckin bool           `json:"requires_daily_checkin"` // La preuve exige un pointage chaque jour de la durée du défi
+	IsTeamChallenge      bool           `json:"is_team_challenge"`      // Défi disputé entre équipes
+	CreatedAt            time.Time      `json:"created_at"`
+	UserStatus           string         `json:"user_status,omitempty"` // 'not_joined', 'in_progress', 'pending_review', 'completed', 'abandoned'
+	JoinedAt             time.Time      `json:"joined_at,omitempty"`
+	CompletedAt          time.Time      `json:"completed_at,omitempty"`
+	ReviewReason         string         `json:"review_reason,omitempty"` // Motif du dernier refus de preuve
+	Attempts             int            `json:"attempts,omitempty"`      // Nombre de tentatives, la tentative en cours incluse
+	TeamID               int64          `json:"team_id,omitempty"`       // Équipe de l'utilisateur pour un défi en équipe
+	CheckinCount         int            `json:"checkin_count,omitempty"` // Pointages de la tentative en cours
+	CheckedInToday       bool           `json:"checked_in_today,omitempty"`
+	Impact               []ImpactFactor `json:"impact"` // Facteurs d'impact du défi
+
+	// Échéances de la participation en cours
+	CompletableAt time.Time `json:"completable_at,omitempty"` // Date à partir de laquelle la preuve peut être envoyée
+	Deadline      time.Time `json:"deadline,omitempty"`       // Date limite avant abandon automatique
+	RemainingDays *int      `json:"remaining_days,omitempty"` // Jours restants avant l'échéance
 }
 
 // ChallengeCreate représente les données pour créer un nouveau défi
 type ChallengeCreate struct {
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	Points       int       `json:"points"`
-	DurationDays int       `json:"duration_days"`
-	StartDate    time.Time `json:"start_date,omitempty"`
-	EndDate      time.Time `json:"end_date,omitempty"`
-	IsActive     bool      `json:"is_active"`
+	Title                string         `json:"title"`
+	Description          string         `json:"description"`
+	Points               int            `json:"points"`
+	DurationDays         int            `json:"duration_days"`
+	StartDate            time.Time      `json:"start_date,omitempty"`
+	EndDate              time.Time      `json:"end_date,omitempty"`
+	IsActive             bool           `json:"is_active"`
+	RequiresDailyCheckin bool           `json:"requires_daily_checkin"`
+	IsTeamChallenge      bool           `json:"is_team_challenge"`
+	Impact               []ImpactFactor `json:"impact"`
 }
 
 // ChallengeUpdate représente les données pour mettre à jour un défi
 type ChallengeUpdate struct {
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	Points       int       `json:"points"`
-	DurationDays int       `json:"duration_days"`
-	StartDate    time.Time `json:"start_date,omitempty"`
-	EndDate      time.Time `json:"end_date,omitempty"`
-	IsActive     bool      `json:"is_active"`
+	Title                string          `json:"title"`
+	Description          string          `json:"description"`
+	Points               int             `json:"points"`
+	DurationDays         int             `json:"duration_days"`
+	StartDate            time.Time       `json:"start_date,omitempty"`
+	EndDate              time.Time       `json:"end_date,omitempty"`
+	IsActive             bool            `json:"is_active"`
+	RequiresDailyCheckin bool            `json:"requires_daily_checkin"`
+	IsTeamChallenge      bool            `json:"is_team_challenge"`
+	Impact               *[]ImpactFactor `json:"impact"` // Absent: facteurs d'impact inchangés
+}
+
+// ChallengeSubmission représente une preuve soumise par un utilisateur pour valider un défi
+type ChallengeSubmission struct {
+	ID             int64                 `json:"id"`
+	UserID         int64                 `json:"user_id"`
+	Username       string                `json:"username"`
+	ChallengeID    int64                 `json:"challenge_id"`
+	ChallengeTitle string                `json:"challenge_title"`
+	Points         int                   `json:"points"`
+	Note           string                `json:"note"`
+	Status         string                `json:"status"` // 'pending', 'approved', 'rejected'
+	Reason         string                `json:"reason,omitempty"`
+	SubmittedAt    time.Time             `json:"submitted_at"`
+	ReviewedBy     int64                 `json:"reviewed_by,omitempty"`
+	ReviewedAt     time.Time             `json:"reviewed_at,omitempty"`
+	Photos         []ChallengeProofPhoto `json:"photos"`
+	Impact         map[string]float64    `json:"impact,omitempty"` // Impacts déclarés par indicateur
+}
+
+// ChallengeProofPhoto représente une photo jointe à une preuve de défi
+type ChallengeProofPhoto struct {
+	ID          int64  `json:"id"`
+	FileName    string `json:"-"` // Nom du fichier sur le disque, jamais exposé
+	ContentType string `json:"content_type"`
+	URL         string `json:"url"`
+}
+
+// ChallengeAttempt représente une tentative d'un utilisateur sur un défi
+type ChallengeAttempt struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	ChallengeID int64     `json:"challenge_id"`
+	Attempt     int       `json:"attempt"`
+	Status      string    `json:"status"`
+	JoinedAt    time.Time `json:"joined_at"`
+	EndedAt     time.Time `json:"ended_at,omitempty"`
+}
+
+// ChallengeCheckin représente un pointage quotidien sur un défi
+type ChallengeCheckin struct {
+	Date      string    `json:"date"` // AAAA-MM-JJ
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserStreak représente la série de jours consécutifs avec au moins un pointage
+type UserStreak struct {
+	CurrentStreak   int    `json:"current_streak"`
+	BestStreak      int    `json:"best_streak"`
+	LastCheckinDate string `json:"last_checkin_date,omitempty"`
+}
+
+// ChallengeReviewDecision représente la décision d'un administrateur sur une preuve
+type ChallengeReviewDecision struct {
+	Reason string `json:"reason"` // Obligatoire en cas de refus
 }
 
 // ChallengesResponse représente la réponse pour les défis écologiques
@@ -195,15 +443,39 @@ type ChallengesResponse struct {
 
 // Badge représente un badge écologique
 type Badge struct {
-	ID             int64     `json:"id"`
-	Name           string    `json:"name"`
-	Description    string    `json:"description"`
-	ImagePath      string    `json:"image_path"`
-	RequiredPoints int       `json:"required_points"`
-	Category       string    `json:"category"`
-	IsEarned       bool      `json:"is_earned,omitempty"`
-	EarnedAt       time.Time `json:"earned_at,omitempty"`
-	PointsToGo     int       `json:"points_to_go,omitempty"` // Points manquants pour obtenir le badge
+	ID          int64               `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	ImagePath   string              `json:"image_path"`
+	Category    string              `json:"category"`
+	Rules       []BadgeRule         `json:"rules"` // Critères, tous requis
+	IsEarned    bool                `json:"is_earned,omitempty"`
+	EarnedAt    time.Time           `json:"earned_at,omitempty"`
+	Progress    []BadgeRuleProgress `json:"progress,omitempty"` // Avancement de l'utilisateur pour chaque critère
+}
+
+// BadgeRule représente un critère d'attribution d'un badge
+type BadgeRule struct {
+	Type       string `json:"type"` // 'total_points', 'challenges_completed', 'activities_attended', 'points_in_window', 'streak', 'category_activities'
+	Threshold  int    `json:"threshold"`
+	WindowDays int    `json:"window_days,omitempty"` // Fenêtre glissante en jours ('points_in_window')
+	Category   string `json:"category,omitempty"`    // Catégorie d'activité ('category_activities')
+}
+
+// BadgeRuleProgress représente l'avancement d'un utilisateur sur un critère de badge
+type BadgeRuleProgress struct {
+	BadgeRule
+	Current int  `json:"current"`
+	Met     bool `json:"met"`
+}
+
+// BadgeCreate représente les données pour créer ou modifier un badge
+type BadgeCreate struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	ImagePath   string      `json:"image_path"`
+	Category    string      `json:"category"`
+	Rules       []BadgeRule `json:"rules"`
 }
 
 // BadgesResponse représente la réponse pour les badges écologiques
@@ -220,6 +492,26 @@ type EcoDashboardSummary struct {
 	BadgesEarned        int `json:"badges_earned"`
 	Ranking             int `json:"ranking,omitempty"`     // Position dans le classement général
 	TotalUsers          int `json:"total_users,omitempty"` // Nombre total d'utilisateurs pour le classement
+
+	// Séries de pointages quotidiens
+	CurrentStreak       int   `json:"current_streak"`
+	BestStreak          int   `json:"best_streak"`
+	StreakMilestones    []int `json:"streak_milestones"`               // Paliers de série atteints (meilleure série)
+	NextStreakMilestone int   `json:"next_streak_milestone,omitempty"` // Prochain palier pour la série en cours
+
+	// Impact environnemental de l'utilisateur
+	Impact        ImpactTotals        `json:"impact"`
+	ImpactByMonth []ImpactSeriesPoint `json:"impact_by_month"` // Douze derniers mois
+}
+
+// SearchResult représente un résultat de la recherche plein texte
+type SearchResult struct {
+	Type    string    `json:"type"` // 'activity', 'challenge', 'message'
+	ID      int64     `json:"id"`
+	Title   string    `json:"title"`   // Titre, termes trouvés entourés de <mark>
+	Snippet string    `json:"snippet"` // Extrait du texte, termes trouvés entourés de <mark>
+	Score   float64   `json:"score"`   // Pertinence (plus élevé = plus pertinent)
+	Date    time.Time `json:"date"`    // Date de l'activité, de création du défi ou d'envoi du message
 }
 
 // AdminStats représente les statistiques pour le tableau de bord administrateur
@@ -228,4 +520,195 @@ type AdminStats struct {
 	ActivitiesCount     int `json:"activities_count"`
 	ChallengesCount     int `json:"challenges_count"`
 	UnreadMessagesCount int `json:"unread_messages_count"`
+
+	// Impact environnemental de l'association
+	Impact        ImpactTotals        `json:"impact"`
+	ImpactByMonth []ImpactSeriesPoint `json:"impact_by_month"` // Douze derniers mois
+}
+
+// Team représente une équipe d'utilisateurs
+type Team struct {
+	ID          int64        `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	CaptainID   int64        `json:"captain_id,omitempty"`
+	CaptainName string       `json:"captain_name,omitempty"`
+	MemberCount int          `json:"member_count"`
+	Points      int          `json:"points"` // Somme des points écologiques des membres
+	CreatedAt   time.Time    `json:"created_at"`
+	Members     []TeamMember `json:"members,omitempty"`
+}
+
+// TeamMember représente un membre d'une équipe
+type TeamMember struct {
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"` // 'captain', 'member'
+	Points   int       `json:"points"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// TeamCreate représente les données pour créer ou modifier une équipe
+type TeamCreate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// TeamCaptainTransfer représente la désignation d'un nouveau capitaine
+type TeamCaptainTransfer struct {
+	UserID int64 `json:"user_id"`
+}
+
+// TeamLeaderboardEntry représente une ligne du classement des équipes
+type TeamLeaderboardEntry struct {
+	Rank        int    `json:"rank"`
+	TeamID      int64  `json:"team_id"`
+	Name        string `json:"name"`
+	MemberCount int    `json:"member_count"`
+	Points      int    `json:"points"`
+}
+
+// TeamChallengeProgress représente la progression d'une équipe sur un défi en équipe
+type TeamChallengeProgress struct {
+	TeamID       int64  `json:"team_id"`
+	Name         string `json:"name"`
+	Participants int    `json:"participants"` // Membres ayant rejoint le défi pour l'équipe
+	InProgress   int    `json:"in_progress"`
+	Completed    int    `json:"completed"`
+	Points       int    `json:"points"` // Points gagnés sur ce défi par les membres de l'équipe
+}
+
+// DomainEvent représente un événement métier enregistré dans la file de traitement
+type DomainEvent struct {
+	ID            int64           `json:"id"`
+	Type          string          `json:"type"`
+	UserID        int64           `json:"user_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"` // 'pending', 'processed', 'failed'
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	ProcessedAt   time.Time       `json:"processed_at,omitempty"`
+}
+
+// EventStats représente l'état de la file des événements
+type EventStats struct {
+	Pending         int       `json:"pending"`
+	Processed       int       `json:"processed"`
+	Failed          int       `json:"failed"`
+	OldestPendingAt time.Time `json:"oldest_pending_at,omitempty"`
+}
+
+// LeaderboardPeriod représente la période sur laquelle les points sont cumulés pour un classement.
+// Une borne nulle n'est pas appliquée.
+type LeaderboardPeriod struct {
+	Name string    `json:"name"` // 'all', 'month', 'week', 'custom'
+	From time.Time `json:"from,omitempty"`
+	To   time.Time `json:"to,omitempty"`
+}
+
+// LeaderboardEntry représente une ligne du classement des utilisateurs
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"` // Les ex aequo partagent le même rang, le suivant est décalé
+	UserID   int64  `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Hidden   bool   `json:"hidden,omitempty"` // L'utilisateur a choisi de masquer son nom
+	Points   int    `json:"points"`
+	IsMe     bool   `json:"is_me,omitempty"`
+}
+
+// LeaderboardResponse représente une page du classement des utilisateurs
+type LeaderboardResponse struct {
+	Period   LeaderboardPeriod  `json:"period"`
+	Entries  []LeaderboardEntry `json:"entries"`
+	Me       *LeaderboardEntry  `json:"me,omitempty"` // Position de l'utilisateur connecté
+	Total    int                `json:"total"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"page_size"`
+}
+
+// Reward représente une récompense du catalogue échangeable contre des points
+type Reward struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ImagePath   string    `json:"image_path,omitempty"`
+	Partner     string    `json:"partner,omitempty"`
+	Cost        int       `json:"cost"`
+	Stock       int       `json:"stock"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RewardCreate représente les données pour créer ou modifier une récompense
+type RewardCreate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ImagePath   string `json:"image_path"`
+	Partner     string `json:"partner"`
+	Cost        int    `json:"cost"`
+	Stock       int    `json:"stock"`
+	IsActive    bool   `json:"is_active"`
+}
+
+// RewardRedemption représente l'échange de points contre une récompense
+type RewardRedemption struct {
+	ID         int64     `json:"id"`
+	RewardID   int64     `json:"reward_id"`
+	RewardName string    `json:"reward_name"`
+	UserID     int64     `json:"user_id"`
+	Username   string    `json:"username,omitempty"`
+	Code       string    `json:"code"`
+	Cost       int       `json:"cost"`
+	Status     string    `json:"status"` // 'pending', 'delivered', 'cancelled'
+	CreatedAt  time.Time `json:"created_at"`
+	HandledBy  int64     `json:"handled_by,omitempty"`
+	HandledAt  time.Time `json:"handled_at,omitempty"`
+}
+
+// ImpactFactor représente un facteur d'impact environnemental d'une activité ou d'un défi
+type ImpactFactor struct {
+	Metric string  `json:"metric"` // 'co2_kg', 'waste_kg', 'water_l'
+	Mode   string  `json:"mode"`   // 'fixed' (montant par participation), 'reported' (déclaré par le participant)
+	Value  float64 `json:"value"`  // Montant par participation, ou plafond d'une déclaration (0 = sans plafond)
+}
+
+// ImpactReport représente les quantités déclarées par un participant, par indicateur
+type ImpactReport struct {
+	Impact map[string]float64 `json:"impact"` // Ex: {"waste_kg": 3.5}
+}
+
+// ImpactTotals représente l'impact environnemental cumulé
+type ImpactTotals struct {
+	CO2Kg   float64 `json:"co2_kg"`   // Kilos de CO2 évités
+	WasteKg float64 `json:"waste_kg"` // Kilos de déchets collectés
+	WaterL  float64 `json:"water_l"`  // Litres d'eau économisés
+}
+
+// ImpactSeriesPoint représente l'impact environnemental sur une période de la série temporelle
+type ImpactSeriesPoint struct {
+	Period string `json:"period"` // '2026-10-17' (jour), '2026-W41' (semaine), '2026-10' (mois)
+	ImpactTotals
+}
+
+// ImpactSeriesResponse représente la réponse de la série temporelle d'impact
+type ImpactSeriesResponse struct {
+	Interval string              `json:"interval"` // 'day', 'week', 'month'
+	From     time.Time           `json:"from"`
+	To       time.Time           `json:"to"`
+	Totals   ImpactTotals        `json:"totals"` // Totaux sur la période
+	Series   []ImpactSeriesPoint `json:"series"`
+}
+
+// Session représente une session de navigateur authentifiée par cookie
+type Session struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	IsAdmin   bool      `json:"is_admin"`
+	CSRFToken string    `json:"-"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
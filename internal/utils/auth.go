@@ -10,6 +10,13 @@ import (
 	"bdd-website/internal/models"
 )
 
+// Les dates des jetons sont à la milliseconde (et non à la seconde, valeur par défaut de la bibliothèque):
+// la révocation des sessions compare la date d'émission d'un jeton à la date de la révocation,
+// et un jeton émis dans la même seconde, avant ou après elle, doit être correctement classé
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
 // Claims représente les données encodées dans le JWT
 type Claims struct {
 	UserID  int64 `json:"user_id"`
@@ -17,10 +24,10 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-// GenerateToken crée un nouveau JWT pour l'utilisateur
-func GenerateToken(user *models.User, secret string, expirationHours int) (string, error) {
+// GenerateToken crée un nouveau JWT d'accès pour l'utilisateur, valable pendant ttl
+func GenerateToken(user *models.User, secret string, ttl time.Duration) (string, error) {
 	// Définir la durée d'expiration
-	expirationTime := time.Now().Add(time.Duration(expirationHours) * time.Hour)
+	expirationTime := time.Now().Add(ttl)
 
 	// Créer les claims
 	claims := &Claims{
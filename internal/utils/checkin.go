@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenerateCheckinToken crée un jeton de pointage signé pour une activité, valable jusqu'à expiresAt
+func GenerateCheckinToken(activityID int64, expiresAt time.Time, secret string) string {
+	payload := fmt.Sprintf("%d.%d", activityID, expiresAt.Unix())
+	return payload + "." + signCheckinPayload(payload, secret)
+}
+
+// ValidateCheckinToken vérifie la signature et l'expiration d'un jeton de pointage pour une activité
+func ValidateCheckinToken(token string, activityID int64, secret string) error {
+	// Format du jeton: "<activité>.<expiration>.<signature>"
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("jeton de pointage invalide")
+	}
+
+	// Vérifier la signature
+	payload := parts[0] + "." + parts[1]
+	expected := signCheckinPayload(payload, secret)
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return errors.New("jeton de pointage invalide")
+	}
+
+	// Vérifier que le jeton correspond à l'activité
+	tokenActivityID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || tokenActivityID != activityID {
+		return errors.New("ce jeton ne correspond pas à cette activité")
+	}
+
+	// Vérifier l'expiration
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return errors.New("jeton de pointage invalide")
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return errors.New("ce jeton de pointage a expiré")
+	}
+
+	return nil
+}
+
+// signCheckinPayload calcule la signature HMAC-SHA256 d'un jeton de pointage
+func signCheckinPayload(payload, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("checkin:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidVerificationToken est retournée pour un jeton de vérification d'email invalide ou expiré
+var ErrInvalidVerificationToken = errors.New("lien de confirmation invalide ou expiré")
+
+// GenerateEmailVerificationToken crée un jeton signé confirmant l'adresse email d'un utilisateur,
+// valable jusqu'à expiresAt. Le jeton est lié à l'adresse: il devient invalide si elle change.
+func GenerateEmailVerificationToken(userID int64, email string, expiresAt time.Time, secret string) string {
+	payload := fmt.Sprintf("%d.%d", userID, expiresAt.Unix())
+	return payload + "." + signEmailVerificationPayload(payload, email, secret)
+}
+
+// ParseEmailVerificationToken extrait l'utilisateur d'un jeton de vérification, sans vérifier sa signature
+func ParseEmailVerificationToken(token string) (int64, error) {
+	// Format du jeton: "<utilisateur>.<expiration>.<signature>"
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidVerificationToken
+	}
+
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidVerificationToken
+	}
+
+	return userID, nil
+}
+
+// ValidateEmailVerificationToken vérifie la signature et l'expiration d'un jeton pour l'adresse actuelle de l'utilisateur
+func ValidateEmailVerificationToken(token, email, secret string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidVerificationToken
+	}
+
+	// Vérifier la signature
+	payload := parts[0] + "." + parts[1]
+	expected := signEmailVerificationPayload(payload, email, secret)
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return ErrInvalidVerificationToken
+	}
+
+	// Vérifier l'expiration
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+// signEmailVerificationPayload calcule la signature HMAC-SHA256 d'un jeton de vérification d'email
+func signEmailVerificationPayload(payload, email, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("verify-email:" + payload + ":" + strings.ToLower(email)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"math"
+)
+
+// Rayon moyen de la Terre en kilomètres
+const earthRadiusKm = 6371.0
+
+// Nombre de kilomètres par degré de latitude
+const kmPerDegreeLat = 111.32
+
+// DistanceKm calcule la distance orthodromique entre deux points (formule de haversine)
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// BoundingBox calcule un rectangle englobant le cercle de rayon radiusKm autour d'un point.
+// Il sert de pré-filtre SQL avant le calcul exact de la distance.
+// wrapsLng indique que le rectangle traverse l'antiméridien ou un pôle: les bornes de longitude ne doivent alors pas être utilisées.
+func BoundingBox(lat, lng, radiusKm float64) (minLat, maxLat, minLng, maxLng float64, wrapsLng bool) {
+	dLat := radiusKm / kmPerDegreeLat
+	minLat = math.Max(-90, lat-dLat)
+	maxLat = math.Min(90, lat+dLat)
+
+	cosLat := math.Cos(toRadians(lat))
+	if cosLat < 0.01 || maxLat == 90 || minLat == -90 {
+		return minLat, maxLat, -180, 180, true
+	}
+
+	dLng := radiusKm / (kmPerDegreeLat * cosLat)
+	minLng = lng - dLng
+	maxLng = lng + dLng
+	if minLng < -180 || maxLng > 180 {
+		return minLat, maxLat, -180, 180, true
+	}
+
+	return minLat, maxLat, minLng, maxLng, false
+}
+
+// ValidCoordinates vérifie qu'une latitude et une longitude sont dans les bornes autorisées
+func ValidCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// toRadians convertit des degrés en radians
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"bdd-website/internal/models"
+)
+
+// Format des dates UTC dans un fichier iCalendar (RFC 5545)
+const icalDateFormat = "20060102T150405Z"
+
+// ActivityUID retourne l'identifiant iCalendar stable d'une activité.
+// Il ne dépend que de l'ID de l'activité, ce qui permet aux agendas abonnés
+// de reconnaître les mises à jour et annulations d'un même événement.
+func ActivityUID(activityID int64) string {
+	return fmt.Sprintf("activity-%d@bdd-website", activityID)
+}
+
+// RenderICalendar génère un calendrier iCalendar contenant un événement par activité
+func RenderICalendar(calendarName, publicURL string, activities []models.Activity) []byte {
+	var buf bytes.Buffer
+	now := time.Now().UTC().Format(icalDateFormat)
+
+	writeICalLine(&buf, "BEGIN:VCALENDAR")
+	writeICalLine(&buf, "VERSION:2.0")
+	writeICalLine(&buf, "PRODID:-//BDD//Activites//FR")
+	writeICalLine(&buf, "CALSCALE:GREGORIAN")
+	writeICalLine(&buf, "METHOD:PUBLISH")
+	writeICalLine(&buf, "X-WR-CALNAME:"+escapeICalText(calendarName))
+	writeICalLine(&buf, "X-WR-TIMEZONE:UTC")
+
+	for _, activity := range activities {
+		status := "CONFIRMED"
+		if activity.Status == "cancelled" {
+			status = "CANCELLED"
+		}
+
+		writeICalLine(&buf, "BEGIN:VEVENT")
+		writeICalLine(&buf, "UID:"+ActivityUID(activity.ID))
+		writeICalLine(&buf, "DTSTAMP:"+now)
+		writeICalLine(&buf, "DTSTART:"+activity.StartDate.UTC().Format(icalDateFormat))
+		writeICalLine(&buf, "DTEND:"+activity.EndDate.UTC().Format(icalDateFormat))
+		writeICalLine(&buf, "LAST-MODIFIED:"+activity.UpdatedAt.UTC().Format(icalDateFormat))
+		// SEQUENCE doit croître à chaque modification: c'est le numéro de révision de l'activité
+		writeICalLine(&buf, fmt.Sprintf("SEQUENCE:%d", activity.Revision))
+		writeICalLine(&buf, "STATUS:"+status)
+		writeICalLine(&buf, "SUMMARY:"+escapeICalText(activity.Title))
+		if activity.Description != "" {
+			writeICalLine(&buf, "DESCRIPTION:"+escapeICalText(activity.Description))
+		}
+		if activity.Location != "" {
+			writeICalLine(&buf, "LOCATION:"+escapeICalText(activity.Location))
+		}
+		writeICalLine(&buf, fmt.Sprintf("URL:%s/activities#activity-%d", publicURL, activity.ID))
+		writeICalLine(&buf, "END:VEVENT")
+	}
+
+	writeICalLine(&buf, "END:VCALENDAR")
+
+	return buf.Bytes()
+}
+
+// escapeICalText échappe les caractères spéciaux d'une valeur texte iCalendar
+func escapeICalText(text string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return replacer.Replace(text)
+}
+
+// writeICalLine écrit une ligne iCalendar terminée par CRLF, repliée à 75 octets
+// sans couper les caractères UTF-8 multi-octets
+func writeICalLine(buf *bytes.Buffer, line string) {
+	const maxLineLength = 75
+
+	length := 0
+	for _, r := range line {
+		size := len(string(r))
+		if length+size > maxLineLength {
+			// Ligne de continuation: CRLF suivi d'un espace
+			buf.WriteString("\r\n ")
+			length = 1
+		}
+		buf.WriteRune(r)
+		length += size
+	}
+	buf.WriteString("\r\n")
+}
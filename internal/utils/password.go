@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"errors"
+	"fmt"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -9,10 +9,23 @@ import (
 // Coût du hachage bcrypt (plus élevé = plus sécurisé mais plus lent)
 const bcryptCost = 10
 
+// MinPasswordLength est la longueur minimale d'un mot de passe
+const MinPasswordLength = 6
+
+// ValidatePassword vérifie qu'un nouveau mot de passe respecte les règles du site
+// (inscription, modification du profil et réinitialisation)
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("le mot de passe doit contenir au moins %d caractères", MinPasswordLength)
+	}
+
+	return nil
+}
+
 // HashPassword génère un hash bcrypt à partir d'un mot de passe en texte brut
 func HashPassword(password string) (string, error) {
-	if len(password) < 6 {
-		return "", errors.New("le mot de passe doit contenir au moins 6 caractères")
+	if err := ValidatePassword(password); err != nil {
+		return "", err
 	}
 
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
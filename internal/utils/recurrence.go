@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fréquences de récurrence prises en charge
+const (
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// MaxOccurrences limite le nombre d'occurrences générées pour une série
+const MaxOccurrences = 104
+
+// Recurrence représente une règle de récurrence inspirée du RRULE de la RFC 5545
+// (sous-ensemble: FREQ=WEEKLY|MONTHLY, INTERVAL, COUNT ou UNTIL)
+type Recurrence struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+}
+
+// ParseRRule analyse une règle du type "FREQ=WEEKLY;INTERVAL=2;COUNT=10" ou "FREQ=MONTHLY;UNTIL=20261231"
+func ParseRRule(rule string) (*Recurrence, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return nil, errors.New("règle de récurrence vide")
+	}
+
+	recurrence := &Recurrence{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) != 2 {
+			return nil, fmt.Errorf("élément de récurrence invalide: %s", part)
+		}
+
+		key := strings.ToUpper(strings.TrimSpace(keyValue[0]))
+		value := strings.TrimSpace(keyValue[1])
+
+		switch key {
+		case "FREQ":
+			freq := strings.ToUpper(value)
+			if freq != FreqWeekly && freq != FreqMonthly {
+				return nil, fmt.Errorf("fréquence non prise en charge: %s (WEEKLY ou MONTHLY)", value)
+			}
+			recurrence.Freq = freq
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("intervalle invalide: %s", value)
+			}
+			recurrence.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count <= 0 {
+				return nil, fmt.Errorf("nombre d'occurrences invalide: %s", value)
+			}
+			recurrence.Count = count
+		case "UNTIL":
+			until, err := parseRRuleDate(value)
+			if err != nil {
+				return nil, err
+			}
+			recurrence.Until = until
+		default:
+			return nil, fmt.Errorf("élément de récurrence non pris en charge: %s", key)
+		}
+	}
+
+	// Valider la règle
+	if recurrence.Freq == "" {
+		return nil, errors.New("la fréquence (FREQ) est obligatoire")
+	}
+
+	if recurrence.Count == 0 && recurrence.Until.IsZero() {
+		return nil, errors.New("la récurrence doit se terminer (COUNT ou UNTIL)")
+	}
+
+	if recurrence.Count > 0 && !recurrence.Until.IsZero() {
+		return nil, errors.New("COUNT et UNTIL ne peuvent pas être utilisés ensemble")
+	}
+
+	return recurrence, nil
+}
+
+// Occurrences calcule les dates de début de chaque occurrence à partir de la première date.
+// Comme dans la RFC 5545, les mois ne contenant pas le jour de départ (ex: le 31) sont ignorés.
+func (r *Recurrence) Occurrences(start time.Time) ([]time.Time, error) {
+	occurrences := []time.Time{}
+
+	for i := 0; ; i++ {
+		var next time.Time
+		switch r.Freq {
+		case FreqWeekly:
+			next = start.AddDate(0, 0, 7*r.Interval*i)
+		case FreqMonthly:
+			next = start.AddDate(0, r.Interval*i, 0)
+			// AddDate normalise le 31 avril en 1er mai: ignorer ce mois
+			if next.Day() != start.Day() {
+				// Garde-fou contre une boucle infinie si aucun mois ne convient
+				if i > MaxOccurrences*12 {
+					return occurrences, nil
+				}
+				continue
+			}
+		default:
+			return nil, fmt.Errorf("fréquence non prise en charge: %s", r.Freq)
+		}
+
+		// Conditions de fin
+		if !r.Until.IsZero() && next.After(r.Until) {
+			break
+		}
+
+		if len(occurrences) == MaxOccurrences {
+			return nil, fmt.Errorf("trop d'occurrences (maximum %d)", MaxOccurrences)
+		}
+
+		occurrences = append(occurrences, next)
+
+		if r.Count > 0 && len(occurrences) >= r.Count {
+			break
+		}
+	}
+
+	return occurrences, nil
+}
+
+// String retourne la règle au format RRULE normalisé
+func (r *Recurrence) String() string {
+	rule := fmt.Sprintf("FREQ=%s;INTERVAL=%d", r.Freq, r.Interval)
+	if r.Count > 0 {
+		rule += fmt.Sprintf(";COUNT=%d", r.Count)
+	}
+	if !r.Until.IsZero() {
+		rule += ";UNTIL=" + r.Until.UTC().Format("20060102T150405Z")
+	}
+	return rule
+}
+
+// parseRRuleDate analyse une date UNTIL (format RFC 5545 ou RFC 3339)
+func parseRRuleDate(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+
+	// Une date sans heure inclut toute la journée
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("date de fin de récurrence invalide: %s", value)
+}
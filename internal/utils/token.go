@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateRandomToken génère un jeton aléatoire non devinable de n octets, encodé en base64 URL
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken calcule l'empreinte SHA-256 d'un jeton secret, seule conservée en base
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// redemptionCodeAlphabet exclut les caractères ambigus à la lecture (0/O, 1/I/L)
+const redemptionCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// GenerateRedemptionCode génère un code de retrait lisible au format XXXX-XXXX
+func GenerateRedemptionCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	code := make([]byte, 0, 9)
+	for i, v := range b {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, redemptionCodeAlphabet[int(v)%len(redemptionCodeAlphabet)])
+	}
+	return string(code), nil
+}
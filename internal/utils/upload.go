@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// imageExtensions associe les types d'images acceptés à leur extension
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// SaveUploadedImage enregistre une image envoyée dans un formulaire multipart sous un nom aléatoire.
+// Le type est déterminé à partir du contenu du fichier et non de son nom.
+// Retourne le nom du fichier créé dans dir et son type MIME.
+func SaveUploadedImage(fileHeader *multipart.FileHeader, dir string, maxSize int64) (string, string, error) {
+	if fileHeader.Size > maxSize {
+		return "", "", fmt.Errorf("le fichier %s dépasse la taille maximale de %d Mo", fileHeader.Filename, maxSize/(1024*1024))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	// Détecter le type à partir des premiers octets
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", "", err
+	}
+
+	contentType := http.DetectContentType(header[:n])
+	extension, ok := imageExtensions[contentType]
+	if !ok {
+		return "", "", fmt.Errorf("le fichier %s n'est pas une image JPEG, PNG ou WebP", fileHeader.Filename)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+
+	// Créer le répertoire si nécessaire
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+
+	// Nom aléatoire: le nom d'origine n'est jamais utilisé sur le disque
+	token, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	fileName := token + extension
+
+	destination, err := os.OpenFile(filepath.Join(dir, fileName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Copier en limitant la taille, au cas où l'en-tête multipart serait mensonger
+	written, err := io.Copy(destination, io.LimitReader(file, maxSize+1))
+	closeErr := destination.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && written > maxSize {
+		err = errors.New("fichier trop volumineux")
+	}
+	if err != nil {
+		os.Remove(filepath.Join(dir, fileName))
+		return "", "", err
+	}
+
+	return fileName, contentType, nil
+}
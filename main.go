@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"bdd-website/config"
 	"bdd-website/internal/database"
 	"bdd-website/internal/handlers"
+	"bdd-website/internal/mailer"
 	"bdd-website/internal/middleware"
 )
 
@@ -24,6 +26,33 @@ func main() {
 	}
 	defer db.Close()
 
+	// La recherche plein texte nécessite un binaire compilé avec FTS5 (make build)
+	if !database.SearchAvailable() {
+		log.Fatalf("Erreur: %v", database.ErrSearchUnavailable)
+	}
+
+	// Abandonner périodiquement les participations aux défis dont l'échéance est dépassée
+	database.ChallengeGracePeriod = cfg.ChallengeGracePeriod
+	go database.StartChallengeSweeper(db, cfg.ChallengeSweepInterval)
+
+	// Traiter les événements métier enregistrés (attribution des badges...)
+	go database.StartEventWorker(db, cfg.EventWorkerInterval)
+
+	// Mode d'envoi des emails
+	var sender mailer.Sender
+	if cfg.SMTPHost != "" {
+		sender = &mailer.SMTPSender{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}
+	} else {
+		log.Printf("SMTP non configuré: les emails sont écrits dans %s", cfg.MailDir)
+		sender = &mailer.FileSender{Dir: cfg.MailDir, From: cfg.MailFrom}
+	}
+
 	// Créer le routeur
 	router := mux.NewRouter()
 
@@ -41,61 +70,168 @@ func main() {
 	router.HandleFunc("/activities", handlers.ActivitiesPage).Methods("GET")
 	router.HandleFunc("/login", handlers.LoginPage).Methods("GET")
 	router.HandleFunc("/signup", handlers.SignupPage).Methods("GET")
+	router.HandleFunc("/forgot-password", handlers.ForgotPasswordPage).Methods("GET")
+	router.HandleFunc("/reset-password", handlers.ResetPasswordPage).Methods("GET")
+	router.HandleFunc("/verify-email", handlers.VerifyEmailPage).Methods("GET")
 	router.HandleFunc("/profile", handlers.ProfilePage).Methods("GET")
+	router.HandleFunc("/checkin", handlers.CheckinPage).Methods("GET")
 
 	// Routes d'authentification
-	router.HandleFunc("/api/auth/register", handlers.Register(db)).Methods("POST")
-	router.HandleFunc("/api/auth/login", handlers.Login(db, cfg.JWTSecret, cfg.JWTExpirationHours)).Methods("POST")
+	router.HandleFunc("/api/auth/register", handlers.Register(db, sender, cfg.JWTSecret, cfg.PublicURL, cfg.EmailVerificationTTL)).Methods("POST")
+	router.HandleFunc("/api/auth/login", handlers.Login(db, cfg.JWTSecret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL, cfg.SessionTTL, cfg.SecureCookies)).Methods("POST")
+	router.HandleFunc("/api/auth/refresh", handlers.RefreshToken(db, cfg.JWTSecret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)).Methods("POST")
+
+	// Confirmation de l'adresse email (limitée par IP pour freiner les essais de jetons).
+	// Les renvois du lien ont leur propre limite: en demander ne doit pas empêcher de confirmer.
+	verifyEmailLimit := middleware.RateLimit(10, 15*time.Minute)
+	resendVerificationLimit := middleware.RateLimit(10, 15*time.Minute)
+	router.Handle("/api/auth/verify-email", verifyEmailLimit(handlers.VerifyEmail(db, cfg.JWTSecret))).Methods("GET")
+
+	// Réinitialisation du mot de passe (limitée par IP pour freiner les essais de jetons et les envois en masse)
+	passwordResetLimit := middleware.RateLimit(10, 15*time.Minute)
+	router.Handle("/api/auth/forgot-password", passwordResetLimit(handlers.ForgotPassword(db, sender, cfg.PublicURL, cfg.PasswordResetTTL))).Methods("POST")
+	router.Handle("/api/auth/reset-password", passwordResetLimit(handlers.ResetPassword(db))).Methods("POST")
+
+	authRouter := router.PathPrefix("/api/auth").Subrouter()
+	authRouter.Use(middleware.Auth(cfg.JWTSecret, db))
+	authRouter.HandleFunc("/logout", handlers.Logout(db, cfg.SecureCookies)).Methods("POST")
+	authRouter.Handle("/resend-verification", resendVerificationLimit(handlers.ResendVerificationEmail(db, sender, cfg.JWTSecret, cfg.PublicURL, cfg.EmailVerificationTTL))).Methods("POST")
 
 	// Routes utilisateurs
 	userRouter := router.PathPrefix("/api/users").Subrouter()
-	userRouter.Use(middleware.Auth(cfg.JWTSecret))
+	userRouter.Use(middleware.Auth(cfg.JWTSecret, db))
 	userRouter.HandleFunc("/profile", handlers.GetUserProfile(db)).Methods("GET")
-	userRouter.HandleFunc("/profile", handlers.UpdateUserProfile(db)).Methods("PUT")
+	userRouter.HandleFunc("/profile", handlers.UpdateUserProfile(db, sender, cfg.JWTSecret, cfg.PublicURL, cfg.EmailVerificationTTL)).Methods("PUT")
+	userRouter.HandleFunc("/login-history", handlers.GetLoginHistory(db)).Methods("GET")
+	userRouter.HandleFunc("/calendar", handlers.GetUserCalendarLink(db, cfg.PublicURL)).Methods("GET")
+	userRouter.HandleFunc("/calendar/regenerate", handlers.RegenerateUserCalendarLink(db, cfg.PublicURL)).Methods("POST")
 
 	// Routes activités
-	router.HandleFunc("/api/activities", handlers.GetActivities(db)).Methods("GET")
-	router.HandleFunc("/api/activities/{id}", handlers.GetActivity(db)).Methods("GET")
+	optionalAuth := middleware.OptionalAuth(cfg.JWTSecret, db)
+	router.HandleFunc("/api/activities.geojson", handlers.GetActivitiesGeoJSON(db)).Methods("GET")
+	router.HandleFunc("/api/activities.ics", handlers.GetActivitiesCalendar(db, cfg.PublicURL)).Methods("GET")
+	router.HandleFunc("/api/activities/{id:[0-9]+}.ics", handlers.GetActivityCalendar(db, cfg.PublicURL)).Methods("GET")
+	router.Handle("/api/activities", optionalAuth(handlers.GetActivities(db))).Methods("GET")
+	router.Handle("/api/activities/{id}", optionalAuth(handlers.GetActivity(db))).Methods("GET")
+
+	// Flux iCalendar personnel (le jeton de l'URL tient lieu d'authentification)
+	router.HandleFunc("/api/calendar/{token}.ics", handlers.GetUserCalendarFeed(db, cfg.PublicURL)).Methods("GET")
 
 	// Routes d'inscription aux activités (protégées)
 	activityRegistrationRouter := router.PathPrefix("/api/activities").Subrouter()
-	activityRegistrationRouter.Use(middleware.Auth(cfg.JWTSecret))
+	activityRegistrationRouter.Use(middleware.Auth(cfg.JWTSecret, db))
 	activityRegistrationRouter.HandleFunc("/{id}/register", handlers.RegisterToActivity(db)).Methods("POST")
 	activityRegistrationRouter.HandleFunc("/{id}/unregister", handlers.UnregisterFromActivity(db)).Methods("DELETE")
+	activityRegistrationRouter.HandleFunc("/{id}/checkin", handlers.CheckInToActivity(db, cfg.JWTSecret)).Methods("POST")
+	activityRegistrationRouter.HandleFunc("/{id}/impact", handlers.ReportActivityImpact(db)).Methods("POST")
+
+	// Routes équipes
+	router.HandleFunc("/api/teams", handlers.GetTeams(db)).Methods("GET")
+	router.HandleFunc("/api/teams/leaderboard", handlers.GetTeamLeaderboard(db)).Methods("GET")
+	router.HandleFunc("/api/teams/{id:[0-9]+}", handlers.GetTeam(db)).Methods("GET")
+
+	teamRouter := router.PathPrefix("/api/teams").Subrouter()
+	teamRouter.Use(middleware.Auth(cfg.JWTSecret, db))
+	teamRouter.HandleFunc("", handlers.CreateTeam(db)).Methods("POST")
+	teamRouter.HandleFunc("/{id:[0-9]+}", handlers.UpdateTeam(db)).Methods("PUT")
+	teamRouter.HandleFunc("/{id:[0-9]+}/join", handlers.JoinTeam(db)).Methods("POST")
+	teamRouter.HandleFunc("/{id:[0-9]+}/leave", handlers.LeaveTeam(db)).Methods("POST")
+	teamRouter.HandleFunc("/{id:[0-9]+}/captain", handlers.TransferTeamCaptain(db)).Methods("POST")
+	teamRouter.HandleFunc("/{id:[0-9]+}/members/{userId:[0-9]+}", handlers.RemoveTeamMember(db)).Methods("DELETE")
+
+	// Catalogue des récompenses
+	router.HandleFunc("/api/rewards", handlers.GetRewards(db)).Methods("GET")
+
+	rewardRouter := router.PathPrefix("/api/rewards").Subrouter()
+	rewardRouter.Use(middleware.Auth(cfg.JWTSecret, db))
+	rewardRouter.HandleFunc("/{id:[0-9]+}/redeem", handlers.RedeemReward(db)).Methods("POST")
+
+	// Classement des utilisateurs (l'utilisateur connecté voit sa propre position)
+	router.Handle("/api/leaderboard", optionalAuth(handlers.GetLeaderboard(db))).Methods("GET")
+
+	leaderboardRouter := router.PathPrefix("/api/leaderboard").Subrouter()
+	leaderboardRouter.Use(middleware.Auth(cfg.JWTSecret, db))
+	leaderboardRouter.HandleFunc("/neighborhood", handlers.GetLeaderboardNeighborhood(db)).Methods("GET")
+
+	// Recherche plein texte (les administrateurs voient aussi les messages de contact)
+	router.Handle("/api/search", optionalAuth(handlers.APISearch(db))).Methods("GET")
 
 	// Routes contact
 	router.HandleFunc("/api/contact", handlers.SubmitContactForm(db)).Methods("POST")
 
 	// Routes du tableau de bord écologique (protégées)
 	ecoDashboardRouter := router.PathPrefix("/api/eco-dashboard").Subrouter()
-	ecoDashboardRouter.Use(middleware.Auth(cfg.JWTSecret))
+	ecoDashboardRouter.Use(middleware.Auth(cfg.JWTSecret, db))
+	ecoDashboardRouter.HandleFunc("/summary", handlers.GetEcoDashboardSummary(db)).Methods("GET")
 	ecoDashboardRouter.HandleFunc("/points", handlers.GetUserEcoPoints(db)).Methods("GET")
+	ecoDashboardRouter.HandleFunc("/impact", handlers.GetUserImpact(db)).Methods("GET")
 	ecoDashboardRouter.HandleFunc("/challenges", handlers.GetUserChallenges(db)).Methods("GET")
 	ecoDashboardRouter.HandleFunc("/challenges/{id}/join", handlers.JoinChallenge(db)).Methods("POST")
-	ecoDashboardRouter.HandleFunc("/challenges/{id}/complete", handlers.CompleteChallenge(db)).Methods("POST")
+	ecoDashboardRouter.HandleFunc("/challenges/{id}/complete", handlers.CompleteChallenge(db, cfg.UploadDir)).Methods("POST")
+	ecoDashboardRouter.HandleFunc("/challenges/{id}/abandon", handlers.AbandonChallenge(db)).Methods("POST")
+	ecoDashboardRouter.HandleFunc("/challenges/{id}/attempts", handlers.GetChallengeAttempts(db)).Methods("GET")
+	ecoDashboardRouter.HandleFunc("/challenges/{id}/checkin", handlers.CheckInChallenge(db)).Methods("POST")
+	ecoDashboardRouter.HandleFunc("/challenges/{id}/checkins", handlers.GetChallengeCheckins(db)).Methods("GET")
+	ecoDashboardRouter.HandleFunc("/challenges/{id}/teams", handlers.GetTeamChallengeProgress(db)).Methods("GET")
+	ecoDashboardRouter.HandleFunc("/streak", handlers.GetUserStreak(db)).Methods("GET")
 	ecoDashboardRouter.HandleFunc("/badges", handlers.GetUserBadges(db)).Methods("GET")
+	ecoDashboardRouter.HandleFunc("/redemptions", handlers.GetUserRedemptions(db)).Methods("GET")
 
 	// Routes admin (protégées + vérification du rôle admin)
 	adminRouter := router.PathPrefix("/api/admin").Subrouter()
-	adminRouter.Use(middleware.Auth(cfg.JWTSecret))
+	adminRouter.Use(middleware.Auth(cfg.JWTSecret, db))
 	adminRouter.Use(middleware.AdminOnly)
+	adminRouter.HandleFunc("/stats", handlers.AdminGetStats(db)).Methods("GET")
+	adminRouter.HandleFunc("/impact", handlers.AdminGetImpact(db)).Methods("GET")
 	adminRouter.HandleFunc("/activities", handlers.AdminCreateActivity(db)).Methods("POST")
 	adminRouter.HandleFunc("/activities/{id}", handlers.AdminUpdateActivity(db)).Methods("PUT")
 	adminRouter.HandleFunc("/activities/{id}", handlers.AdminDeleteActivity(db)).Methods("DELETE")
+	adminRouter.HandleFunc("/activities/{id}/cancel", handlers.AdminCancelActivity(db, sender)).Methods("POST")
+	adminRouter.HandleFunc("/activities/{id}/attendance", handlers.AdminGetActivityAttendance(db)).Methods("GET")
+	adminRouter.HandleFunc("/activities/{id}/attendance", handlers.AdminMarkAttendance(db)).Methods("PUT")
+	adminRouter.HandleFunc("/activities/{id}/checkin-token", handlers.AdminGetCheckinToken(db, cfg.JWTSecret, cfg.PublicURL)).Methods("GET")
+	adminRouter.HandleFunc("/activities/{id}/checkin-qr", handlers.AdminGetCheckinQRCode(db, cfg.JWTSecret, cfg.PublicURL)).Methods("GET")
+	adminRouter.HandleFunc("/activity-series", handlers.AdminCreateActivitySeries(db)).Methods("POST")
+	adminRouter.HandleFunc("/activity-series/{id}", handlers.AdminGetActivitySeries(db)).Methods("GET")
+	adminRouter.HandleFunc("/activity-series/{id}", handlers.AdminCancelActivitySeries(db, sender)).Methods("DELETE")
 	adminRouter.HandleFunc("/challenges", handlers.AdminCreateChallenge(db)).Methods("POST")
 	adminRouter.HandleFunc("/challenges/{id}", handlers.AdminUpdateChallenge(db)).Methods("PUT")
 	adminRouter.HandleFunc("/challenges/{id}", handlers.AdminDeleteChallenge(db)).Methods("DELETE")
+	adminRouter.HandleFunc("/challenges/{id}/attempts", handlers.AdminGetChallengeAttempts(db)).Methods("GET")
+	adminRouter.HandleFunc("/challenge-submissions", handlers.AdminGetChallengeSubmissions(db)).Methods("GET")
+	adminRouter.HandleFunc("/challenge-submissions/{id}/approve", handlers.AdminApproveChallengeSubmission(db)).Methods("POST")
+	adminRouter.HandleFunc("/challenge-submissions/{id}/reject", handlers.AdminRejectChallengeSubmission(db)).Methods("POST")
+	adminRouter.HandleFunc("/challenge-submissions/photos/{id}", handlers.AdminGetChallengeProofPhoto(db, cfg.UploadDir)).Methods("GET")
+	adminRouter.HandleFunc("/badges", handlers.AdminGetBadges(db)).Methods("GET")
+	adminRouter.HandleFunc("/badges", handlers.AdminCreateBadge(db)).Methods("POST")
+	adminRouter.HandleFunc("/badges/{id}", handlers.AdminUpdateBadge(db)).Methods("PUT")
+	adminRouter.HandleFunc("/badges/{id}", handlers.AdminDeleteBadge(db)).Methods("DELETE")
+	adminRouter.HandleFunc("/rewards", handlers.AdminGetRewards(db)).Methods("GET")
+	adminRouter.HandleFunc("/rewards", handlers.AdminCreateReward(db)).Methods("POST")
+	adminRouter.HandleFunc("/rewards/{id}", handlers.AdminUpdateReward(db)).Methods("PUT")
+	adminRouter.HandleFunc("/rewards/{id}", handlers.AdminDeleteReward(db)).Methods("DELETE")
+	adminRouter.HandleFunc("/reward-redemptions", handlers.AdminGetRewardRedemptions(db)).Methods("GET")
+	adminRouter.HandleFunc("/reward-redemptions/{id}/deliver", handlers.AdminDeliverRewardRedemption(db)).Methods("POST")
+	adminRouter.HandleFunc("/reward-redemptions/{id}/cancel", handlers.AdminCancelRewardRedemption(db)).Methods("POST")
+	adminRouter.HandleFunc("/events", handlers.AdminGetEvents(db)).Methods("GET")
+	adminRouter.HandleFunc("/events/{id}/retry", handlers.AdminRetryEvent(db)).Methods("POST")
 	adminRouter.HandleFunc("/users", handlers.AdminGetUsers(db)).Methods("GET")
+	adminRouter.HandleFunc("/users/{id}/revoke-sessions", handlers.AdminRevokeUserSessions(db)).Methods("POST")
+	adminRouter.HandleFunc("/users/{id}/unlock", handlers.AdminUnlockUser(db)).Methods("POST")
+	adminRouter.HandleFunc("/users/{id}/points", handlers.AdminGetUserEcoPoints(db)).Methods("GET")
+	adminRouter.HandleFunc("/users/{id}/points", handlers.AdminAdjustEcoPoints(db)).Methods("POST")
+	adminRouter.HandleFunc("/eco-points/{id}/reverse", handlers.AdminReverseEcoPoints(db)).Methods("POST")
 	adminRouter.HandleFunc("/contact-messages", handlers.AdminGetContactMessages(db)).Methods("GET")
 
 	// Routes pages admin (protégées)
 	adminPagesRouter := router.PathPrefix("/admin").Subrouter()
-	adminPagesRouter.Use(middleware.Auth(cfg.JWTSecret))
+	adminPagesRouter.Use(middleware.Auth(cfg.JWTSecret, db))
 	adminPagesRouter.Use(middleware.AdminOnly)
 	adminPagesRouter.HandleFunc("", handlers.AdminDashboardPage).Methods("GET")
 	adminPagesRouter.HandleFunc("/activities", handlers.AdminActivitiesPage).Methods("GET")
 	adminPagesRouter.HandleFunc("/activities/edit/{id}", handlers.AdminEditActivityPage).Methods("GET")
 	adminPagesRouter.HandleFunc("/activities/new", handlers.AdminNewActivityPage).Methods("GET")
+	adminPagesRouter.HandleFunc("/activities/checkin/{id}", handlers.AdminCheckinQRCodePage).Methods("GET")
 	adminPagesRouter.HandleFunc("/challenges", handlers.AdminChallengesPage).Methods("GET")
 	adminPagesRouter.HandleFunc("/users", handlers.AdminUsersPage).Methods("GET")
 	adminPagesRouter.HandleFunc("/messages", handlers.AdminMessagesPage).Methods("GET")
@@ -1,17 +1,92 @@
 -- Création des tables
+-- Exécuté à chaque démarrage: toutes les instructions sont idempotentes.
+-- Les colonnes ajoutées aux tables existantes sont gérées par internal/database/schema_upgrades.go.
 
 -- Table des utilisateurs
-CREATE TABLE users (
+CREATE TABLE IF NOT EXISTS users (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     email TEXT NOT NULL UNIQUE,
     username TEXT NOT NULL,
     password_hash TEXT NOT NULL,
     is_admin BOOLEAN NOT NULL DEFAULT 0,
+    calendar_token TEXT UNIQUE, -- Jeton secret du flux iCalendar personnel
+    hide_from_leaderboard BOOLEAN NOT NULL DEFAULT 0, -- Nom masqué dans les classements
+    tokens_revoked_at TIMESTAMP, -- Les jetons d'accès émis jusqu'à cette date sont refusés
+    email_verified_at TIMESTAMP, -- NULL tant que l'adresse n'est pas confirmée
+    verification_sent_at TIMESTAMP, -- Dernier envoi du lien de confirmation
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Table des sessions des navigateurs (cookie de session)
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL UNIQUE, -- Empreinte SHA-256 du jeton du cookie
+    user_id INTEGER NOT NULL,
+    csrf_token TEXT NOT NULL, -- Jeton à renvoyer dans l'en-tête X-CSRF-Token des requêtes modifiantes
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id, expires_at);
+
+-- Table des jetons de renouvellement (à usage unique, renouvelés à chaque utilisation)
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL UNIQUE, -- Empreinte SHA-256 du jeton
+    user_id INTEGER NOT NULL,
+    family_id TEXT NOT NULL, -- Chaîne des jetons issus d'une même connexion
+    parent_id INTEGER, -- Jeton remplacé par celui-ci
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP, -- Date de remplacement par un nouveau jeton
+    revoked_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES refresh_tokens(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id, expires_at);
+
+-- Table des jetons de réinitialisation du mot de passe (à usage unique)
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL UNIQUE, -- Empreinte SHA-256 du jeton envoyé par email
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id, created_at);
+
+-- Table de l'historique des connexions (réussies et échouées)
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER, -- NULL si l'adresse ne correspond à aucun compte
+    email TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(user_id, created_at);
+
+-- Table des échecs de connexion consécutifs par compte ("email:...") et par adresse IP ("ip:...")
+CREATE TABLE IF NOT EXISTS login_throttles (
+    throttle_key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP, -- Connexions refusées jusqu'à cette date
+    last_failure_at TIMESTAMP NOT NULL
+);
+
 -- Table des activités
-CREATE TABLE activities (
+CREATE TABLE IF NOT EXISTS activities (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     title TEXT NOT NULL,
     description TEXT NOT NULL,
@@ -19,14 +94,43 @@ CREATE TABLE activities (
     start_date TIMESTAMP NOT NULL,
     end_date TIMESTAMP NOT NULL,
     location TEXT NOT NULL,
+    latitude REAL, -- Coordonnées optionnelles du lieu (WGS 84)
+    longitude REAL,
     max_participants INTEGER DEFAULT 0,
     eco_points INTEGER DEFAULT 0,
+    category TEXT NOT NULL DEFAULT 'other', -- 'cleanup', 'workshop', 'conference', 'planting', 'outing', 'other'
+    series_id INTEGER, -- Série récurrente d'origine (NULL pour une activité ponctuelle)
+    status TEXT NOT NULL DEFAULT 'scheduled', -- 'scheduled', 'cancelled'
+    revision INTEGER NOT NULL DEFAULT 0, -- Incrémentée à chaque modification (SEQUENCE des flux iCalendar)
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (series_id) REFERENCES activity_series(id) ON DELETE SET NULL
+);
+
+-- Table des tags libres des activités
+CREATE TABLE IF NOT EXISTS activity_tags (
+    activity_id INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (activity_id, tag),
+    FOREIGN KEY (activity_id) REFERENCES activities(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_tags_tag ON activity_tags(tag);
+
+CREATE INDEX IF NOT EXISTS idx_activities_coordinates ON activities(latitude, longitude);
+
+-- Table des séries d'activités récurrentes
+CREATE TABLE IF NOT EXISTS activity_series (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    rrule TEXT NOT NULL, -- Règle de récurrence, ex: 'FREQ=WEEKLY;INTERVAL=1;COUNT=10'
+    is_cancelled BOOLEAN NOT NULL DEFAULT 0,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 -- Table des inscriptions aux activités
-CREATE TABLE registrations (
+CREATE TABLE IF NOT EXISTS registrations (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     user_id INTEGER NOT NULL,
     activity_id INTEGER NOT NULL,
@@ -36,8 +140,37 @@ CREATE TABLE registrations (
     UNIQUE(user_id, activity_id)
 );
 
+-- Table des listes d'attente des activités complètes
+CREATE TABLE IF NOT EXISTS waitlist (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    activity_id INTEGER NOT NULL,
+    position INTEGER NOT NULL, -- Ordre d'arrivée sur la liste d'attente de l'activité
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (activity_id) REFERENCES activities(id) ON DELETE CASCADE,
+    UNIQUE(user_id, activity_id),
+    UNIQUE(activity_id, position)
+);
+
+-- Table des présences aux activités
+CREATE TABLE IF NOT EXISTS attendances (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    activity_id INTEGER NOT NULL,
+    status TEXT NOT NULL, -- 'present', 'absent'
+    eco_point_id INTEGER, -- Points crédités pour cette présence (NULL tant que rien n'a été crédité)
+    marked_by INTEGER,
+    marked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (activity_id) REFERENCES activities(id) ON DELETE CASCADE,
+    FOREIGN KEY (eco_point_id) REFERENCES eco_points(id) ON DELETE SET NULL,
+    FOREIGN KEY (marked_by) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE(user_id, activity_id)
+);
+
 -- Table des défis écologiques
-CREATE TABLE eco_challenges (
+CREATE TABLE IF NOT EXISTS eco_challenges (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     title TEXT NOT NULL,
     description TEXT NOT NULL,
@@ -46,48 +179,215 @@ CREATE TABLE eco_challenges (
     start_date TIMESTAMP,
     end_date TIMESTAMP,
     is_active BOOLEAN NOT NULL DEFAULT 1,
+    requires_daily_checkin BOOLEAN NOT NULL DEFAULT 0, -- La preuve exige un pointage chaque jour de la durée du défi
+    is_team_challenge BOOLEAN NOT NULL DEFAULT 0, -- Défi disputé entre équipes
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table des équipes (classes, associations du campus...)
+CREATE TABLE IF NOT EXISTS teams (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
--- Table des participations aux défis
-CREATE TABLE challenge_participants (
+-- Table des membres des équipes (un utilisateur appartient à une seule équipe)
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL UNIQUE,
+    role TEXT NOT NULL DEFAULT 'member', -- 'captain', 'member'
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, user_id),
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Table des tentatives sur les défis: rejoindre un défi, puis le rejoindre à nouveau après un abandon,
+-- crée chaque fois une nouvelle tentative
+CREATE TABLE IF NOT EXISTS challenge_attempts (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     user_id INTEGER NOT NULL,
     challenge_id INTEGER NOT NULL,
-    status TEXT NOT NULL, -- 'in_progress', 'completed', 'abandoned'
+    attempt INTEGER NOT NULL, -- Numéro de la tentative (1 pour la première)
+    status TEXT NOT NULL, -- 'in_progress', 'pending_review', 'completed', 'abandoned'
+    team_id INTEGER, -- Équipe pour laquelle l'utilisateur relève un défi en équipe
     joined_at TIMESTAMP NOT NULL,
     completed_at TIMESTAMP,
+    abandoned_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (challenge_id) REFERENCES eco_challenges(id) ON DELETE CASCADE,
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE SET NULL,
+    UNIQUE(user_id, challenge_id, attempt)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_attempts_status ON challenge_attempts(status);
+CREATE INDEX IF NOT EXISTS idx_challenge_attempts_team ON challenge_attempts(team_id);
+
+-- Table des participations aux défis: tentative en cours de chaque utilisateur sur chaque défi
+CREATE TABLE IF NOT EXISTS challenge_participants (
+    user_id INTEGER NOT NULL,
+    challenge_id INTEGER NOT NULL,
+    attempt_id INTEGER NOT NULL UNIQUE, -- Tentative la plus récente
+    PRIMARY KEY (user_id, challenge_id),
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (challenge_id) REFERENCES eco_challenges(id) ON DELETE CASCADE,
-    UNIQUE(user_id, challenge_id)
+    FOREIGN KEY (attempt_id) REFERENCES challenge_attempts(id)
 );
 
--- Table des points écologiques
-CREATE TABLE eco_points (
+-- Table des pointages quotidiens sur les défis en cours
+CREATE TABLE IF NOT EXISTS challenge_checkins (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    attempt_id INTEGER NOT NULL, -- Tentative pendant laquelle le pointage a été fait
+    checkin_date TEXT NOT NULL, -- Jour du pointage (AAAA-MM-JJ, heure locale du serveur)
+    note TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (attempt_id) REFERENCES challenge_attempts(id) ON DELETE CASCADE,
+    UNIQUE(attempt_id, checkin_date)
+);
+
+-- Table des séries de jours consécutifs avec au moins un pointage
+CREATE TABLE IF NOT EXISTS user_streaks (
+    user_id INTEGER PRIMARY KEY,
+    current_streak INTEGER NOT NULL DEFAULT 0,
+    best_streak INTEGER NOT NULL DEFAULT 0,
+    last_checkin_date TEXT, -- AAAA-MM-JJ
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Table des preuves soumises pour valider un défi
+CREATE TABLE IF NOT EXISTS challenge_submissions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    attempt_id INTEGER NOT NULL, -- Tentative pour laquelle la preuve a été soumise
+    note TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending', 'approved', 'rejected'
+    reason TEXT, -- Motif du refus
+    submitted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_by INTEGER,
+    reviewed_at TIMESTAMP,
+    eco_point_id INTEGER, -- Points crédités lors de l'approbation
+    FOREIGN KEY (attempt_id) REFERENCES challenge_attempts(id) ON DELETE CASCADE,
+    FOREIGN KEY (reviewed_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (eco_point_id) REFERENCES eco_points(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_status ON challenge_submissions(status, submitted_at);
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_attempt ON challenge_submissions(attempt_id);
+
+-- Table des photos jointes aux preuves de défi
+CREATE TABLE IF NOT EXISTS challenge_proof_photos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    submission_id INTEGER NOT NULL,
+    file_name TEXT NOT NULL, -- Nom du fichier dans le répertoire d'upload
+    content_type TEXT NOT NULL,
+    uploaded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (submission_id) REFERENCES challenge_submissions(id) ON DELETE CASCADE
+);
+
+-- Table des impacts déclarés avec une preuve de défi, enregistrés lors de l'approbation
+CREATE TABLE IF NOT EXISTS challenge_submission_impacts (
+    submission_id INTEGER NOT NULL,
+    metric TEXT NOT NULL, -- 'co2_kg', 'waste_kg', 'water_l'
+    amount REAL NOT NULL CHECK (amount > 0),
+    PRIMARY KEY (submission_id, metric),
+    FOREIGN KEY (submission_id) REFERENCES challenge_submissions(id) ON DELETE CASCADE
+);
+
+-- Table des points écologiques (registre en ajout seul: une erreur se corrige par une écriture d'annulation)
+CREATE TABLE IF NOT EXISTS eco_points (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     user_id INTEGER NOT NULL,
     activity_id INTEGER,
     challenge_id INTEGER,
-    points INTEGER NOT NULL,
-    description TEXT NOT NULL,
+    attempt_id INTEGER, -- Tentative de défi récompensée
+    points INTEGER NOT NULL, -- Négatif pour un débit ou une annulation
+    description TEXT NOT NULL, -- Motif obligatoire pour les ajustements et annulations
+    kind TEXT NOT NULL DEFAULT 'earned', -- 'earned', 'adjustment', 'reversal', 'redemption', 'refund'
+    created_by INTEGER, -- Administrateur à l'origine d'un ajustement, d'une annulation ou d'un remboursement
+    reverses_id INTEGER UNIQUE, -- Écriture annulée ou remboursée (une seule fois)
     date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (activity_id) REFERENCES activities(id) ON DELETE SET NULL,
+    FOREIGN KEY (challenge_id) REFERENCES eco_challenges(id) ON DELETE SET NULL,
+    FOREIGN KEY (attempt_id) REFERENCES challenge_attempts(id) ON DELETE SET NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (reverses_id) REFERENCES eco_points(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_eco_points_user ON eco_points(user_id, date);
+
+-- Les écritures passées ne peuvent être ni modifiées ni supprimées
+-- (seuls les liens vers une activité ou un défi supprimé peuvent être remis à NULL)
+CREATE TRIGGER IF NOT EXISTS eco_points_no_update
+BEFORE UPDATE OF user_id, points, description, kind, created_by, reverses_id, date ON eco_points
+BEGIN
+    SELECT RAISE(ABORT, 'les écritures de points ne peuvent pas être modifiées');
+END;
+
+CREATE TRIGGER IF NOT EXISTS eco_points_no_delete
+BEFORE DELETE ON eco_points
+BEGIN
+    SELECT RAISE(ABORT, 'les écritures de points ne peuvent pas être supprimées');
+END;
+
+-- Table des facteurs d'impact environnemental d'une activité ou d'un défi
+CREATE TABLE IF NOT EXISTS impact_factors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    activity_id INTEGER,
+    challenge_id INTEGER,
+    metric TEXT NOT NULL, -- 'co2_kg', 'waste_kg', 'water_l'
+    mode TEXT NOT NULL, -- 'fixed' (montant par participation) ou 'reported' (déclaré par le participant)
+    value REAL NOT NULL CHECK (value >= 0), -- Montant par participation, ou plafond d'une déclaration (0 = sans plafond)
+    FOREIGN KEY (activity_id) REFERENCES activities(id) ON DELETE CASCADE,
+    FOREIGN KEY (challenge_id) REFERENCES eco_challenges(id) ON DELETE CASCADE,
+    CHECK ((activity_id IS NULL) <> (challenge_id IS NULL)),
+    UNIQUE(activity_id, metric),
+    UNIQUE(challenge_id, metric)
+);
+
+-- Table des impacts enregistrés pour chaque participation
+CREATE TABLE IF NOT EXISTS impact_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    activity_id INTEGER,
+    challenge_id INTEGER,
+    metric TEXT NOT NULL, -- 'co2_kg', 'waste_kg', 'water_l'
+    mode TEXT NOT NULL, -- 'fixed' ou 'reported'
+    amount REAL NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (activity_id) REFERENCES activities(id) ON DELETE SET NULL,
     FOREIGN KEY (challenge_id) REFERENCES eco_challenges(id) ON DELETE SET NULL
 );
 
+CREATE INDEX IF NOT EXISTS idx_impact_entries_user ON impact_entries(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_impact_entries_date ON impact_entries(created_at);
+CREATE INDEX IF NOT EXISTS idx_impact_entries_activity ON impact_entries(activity_id, user_id);
+
 -- Table des badges
-CREATE TABLE badges (
+CREATE TABLE IF NOT EXISTS badges (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL UNIQUE,
     description TEXT NOT NULL,
     image_path TEXT NOT NULL,
-    required_points INTEGER NOT NULL,
     category TEXT NOT NULL -- 'participation', 'challenge', 'special'
 );
 
+-- Table des critères d'attribution des badges (tous les critères d'un badge doivent être remplis)
+CREATE TABLE IF NOT EXISTS badge_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    badge_id INTEGER NOT NULL,
+    rule_type TEXT NOT NULL, -- 'total_points', 'challenges_completed', 'activities_attended', 'points_in_window', 'streak', 'category_activities'
+    threshold INTEGER NOT NULL,
+    window_days INTEGER, -- Fenêtre glissante en jours ('points_in_window')
+    category TEXT, -- Catégorie d'activité ('category_activities')
+    FOREIGN KEY (badge_id) REFERENCES badges(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_badge_rules_badge ON badge_rules(badge_id);
+
 -- Table des badges des utilisateurs
-CREATE TABLE user_badges (
+CREATE TABLE IF NOT EXISTS user_badges (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     user_id INTEGER NOT NULL,
     badge_id INTEGER NOT NULL,
@@ -97,8 +397,62 @@ CREATE TABLE user_badges (
     UNIQUE(user_id, badge_id)
 );
 
+-- Table du catalogue des récompenses échangeables contre des points
+CREATE TABLE IF NOT EXISTS rewards (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL,
+    image_path TEXT,
+    partner TEXT, -- Partenaire qui fournit la récompense (bon d'achat...)
+    cost INTEGER NOT NULL CHECK (cost > 0), -- Coût en points
+    stock INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0), -- Quantité restante
+    is_active BOOLEAN NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table des échanges de points contre une récompense
+CREATE TABLE IF NOT EXISTS reward_redemptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reward_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    code TEXT NOT NULL UNIQUE, -- Code à présenter pour retirer la récompense
+    cost INTEGER NOT NULL, -- Coût payé au moment de l'échange
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending', 'delivered', 'cancelled'
+    eco_point_id INTEGER NOT NULL, -- Écriture de débit
+    refund_point_id INTEGER, -- Écriture de remboursement en cas d'annulation
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    handled_by INTEGER, -- Administrateur qui a remis ou annulé la récompense
+    handled_at TIMESTAMP,
+    FOREIGN KEY (reward_id) REFERENCES rewards(id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (eco_point_id) REFERENCES eco_points(id),
+    FOREIGN KEY (refund_point_id) REFERENCES eco_points(id),
+    FOREIGN KEY (handled_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reward_redemptions_user ON reward_redemptions(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_reward_redemptions_status ON reward_redemptions(status, created_at);
+
+-- File des événements métier (outbox): enregistrés dans la transaction qui les produit,
+-- puis traités par une tâche de fond avec nouvelles tentatives en cas d'échec
+CREATE TABLE IF NOT EXISTS domain_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type TEXT NOT NULL, -- 'points_credited', 'points_adjusted', 'challenge_completed', 'attendance_confirmed', 'checkin_recorded', 'badge_rules_changed'
+    user_id INTEGER, -- NULL pour les événements qui concernent tous les utilisateurs
+    payload TEXT NOT NULL DEFAULT '{}', -- Détails de l'événement (JSON)
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending', 'processed', 'failed'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_domain_events_status ON domain_events(status, next_attempt_at);
+
 -- Table des messages de contact
-CREATE TABLE contact_messages (
+CREATE TABLE IF NOT EXISTS contact_messages (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
     email TEXT NOT NULL,
@@ -107,42 +461,3 @@ CREATE TABLE contact_messages (
     submitted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     is_read BOOLEAN NOT NULL DEFAULT 0
 );
-
--- Insertion des données initiales
-
--- Création d'un utilisateur administrateur par défaut (mot de passe: admin123)
--- Note: En production, utiliser un mot de passe plus sécurisé et le hacher correctement
-INSERT INTO users (email, username, password_hash, is_admin)
-VALUES ('admin@example.com', 'Admin', '$2a$10$JPh0PJoNeHwroDfzF6NW6uXZcs.TY4Kz7GQXudCS3KnCYTu/RgzXm', 1);
-
--- Insertion des badges de base
-INSERT INTO badges (name, description, image_path, required_points, category)
-VALUES 
-    ('Débutant écolo', 'Bienvenue dans la communauté écologique !', '/assets/images/badges/beginner.svg', 0, 'participation'),
-    ('Écologiste en herbe', 'Vous avez accumulé 100 points écologiques', '/assets/images/badges/green_starter.svg', 100, 'participation'),
-    ('Champion vert', 'Vous avez accumulé 500 points écologiques', '/assets/images/badges/green_champion.svg', 500, 'participation'),
-    ('Maître de la durabilité', 'Vous avez accumulé 1000 points écologiques', '/assets/images/badges/sustainability_master.svg', 1000, 'participation'),
-    ('Premier défi', 'Vous avez complété votre premier défi', '/assets/images/badges/first_challenge.svg', 50, 'challenge'),
-    ('Défieur en série', 'Vous avez complété 5 défis', '/assets/images/badges/serial_challenger.svg', 250, 'challenge'),
-    ('Bénévole', 'Vous avez participé à votre première activité', '/assets/images/badges/volunteer.svg', 30, 'participation'),
-    ('Ambassadeur BDD', 'Vous avez participé à 10 activités', '/assets/images/badges/ambassador.svg', 300, 'participation');
-
--- Insertion de quelques défis écologiques
-INSERT INTO eco_challenges (title, description, points, duration_days, is_active)
-VALUES 
-    ('Zéro déchet pendant une semaine', 'Essayez de ne produire aucun déchet non recyclable pendant une semaine entière.', 100, 7, 1),
-    ('Transport écologique', 'Utilisez uniquement des transports en commun, vélo ou marche pendant 5 jours consécutifs.', 75, 5, 1),
-    ('Réduction d''énergie', 'Réduisez votre consommation d''électricité de 20% pendant 10 jours.', 120, 10, 1),
-    ('Alimentation locale', 'Ne consommez que des produits locaux (moins de 100km) pendant 3 jours.', 50, 3, 1);
-
--- Insertion de quelques activités
-INSERT INTO activities (title, description, image_path, start_date, end_date, location, max_participants, eco_points)
-VALUES 
-    ('Atelier zéro déchet', 'Apprenez à fabriquer vos propres produits ménagers écologiques.', '/assets/images/events/workshop.jpg', 
-     datetime('now', '+7 days'), datetime('now', '+7 days', '+3 hours'), 'Salle A103, Paris Ynov Campus', 20, 30),
-    
-    ('Nettoyage du parc', 'Collecte de déchets dans le parc à proximité du campus.', '/assets/images/events/cleanup.jpg', 
-     datetime('now', '+14 days'), datetime('now', '+14 days', '+4 hours'), 'Parc Martin Luther King', 30, 50),
-    
-    ('Conférence sur l''économie circulaire', 'Venez découvrir comment réduire votre impact environnemental grâce à l''économie circulaire.', '/assets/images/events/conference.jpg', 
-     datetime('now', '+21 days'), datetime('now', '+21 days', '+2 hours'), 'Amphithéâtre, Paris Ynov Campus', 100, 20);
\ No newline at end of file
@@ -0,0 +1,70 @@
+-- Index de recherche plein texte (SQLite FTS5)
+-- Exécuté à chaque démarrage: toutes les instructions sont idempotentes.
+-- Nécessite un binaire compilé avec le tag sqlite_fts5 (go build -tags sqlite_fts5).
+-- Les index "external content" ne stockent pas le texte: ils pointent vers les tables sources
+-- et sont maintenus à jour par des triggers.
+
+-- Activités
+CREATE VIRTUAL TABLE IF NOT EXISTS activities_fts USING fts5(
+    title, description,
+    content='activities', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS activities_fts_insert AFTER INSERT ON activities BEGIN
+    INSERT INTO activities_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS activities_fts_delete AFTER DELETE ON activities BEGIN
+    INSERT INTO activities_fts(activities_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS activities_fts_update AFTER UPDATE OF title, description ON activities BEGIN
+    INSERT INTO activities_fts(activities_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO activities_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+-- Défis
+CREATE VIRTUAL TABLE IF NOT EXISTS eco_challenges_fts USING fts5(
+    title, description,
+    content='eco_challenges', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS eco_challenges_fts_insert AFTER INSERT ON eco_challenges BEGIN
+    INSERT INTO eco_challenges_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS eco_challenges_fts_delete AFTER DELETE ON eco_challenges BEGIN
+    INSERT INTO eco_challenges_fts(eco_challenges_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS eco_challenges_fts_update AFTER UPDATE OF title, description ON eco_challenges BEGIN
+    INSERT INTO eco_challenges_fts(eco_challenges_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO eco_challenges_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+-- Messages de contact (recherche réservée aux administrateurs)
+CREATE VIRTUAL TABLE IF NOT EXISTS contact_messages_fts USING fts5(
+    subject, message, name, email,
+    content='contact_messages', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS contact_messages_fts_insert AFTER INSERT ON contact_messages BEGIN
+    INSERT INTO contact_messages_fts(rowid, subject, message, name, email) VALUES (new.id, new.subject, new.message, new.name, new.email);
+END;
+
+CREATE TRIGGER IF NOT EXISTS contact_messages_fts_delete AFTER DELETE ON contact_messages BEGIN
+    INSERT INTO contact_messages_fts(contact_messages_fts, rowid, subject, message, name, email) VALUES ('delete', old.id, old.subject, old.message, old.name, old.email);
+END;
+
+CREATE TRIGGER IF NOT EXISTS contact_messages_fts_update AFTER UPDATE OF subject, message, name, email ON contact_messages BEGIN
+    INSERT INTO contact_messages_fts(contact_messages_fts, rowid, subject, message, name, email) VALUES ('delete', old.id, old.subject, old.message, old.name, old.email);
+    INSERT INTO contact_messages_fts(rowid, subject, message, name, email) VALUES (new.id, new.subject, new.message, new.name, new.email);
+END;
+
+-- Reconstruire les index à partir des tables sources (rattrape les écritures faites sans FTS5)
+INSERT INTO activities_fts(activities_fts) VALUES ('rebuild');
+INSERT INTO eco_challenges_fts(eco_challenges_fts) VALUES ('rebuild');
+INSERT INTO contact_messages_fts(contact_messages_fts) VALUES ('rebuild');
@@ -0,0 +1,79 @@
+-- Insertion des données initiales
+-- Exécuté une seule fois, à la création de la base de données.
+
+-- Création d'un utilisateur administrateur par défaut (mot de passe: admin123)
+-- Note: En production, utiliser un mot de passe plus sécurisé et le hacher correctement
+INSERT INTO users (email, username, password_hash, is_admin, email_verified_at)
+VALUES ('admin@example.com', 'Admin', '$2a$10$JPh0PJoNeHwroDfzF6NW6uXZcs.TY4Kz7GQXudCS3KnCYTu/RgzXm', 1, CURRENT_TIMESTAMP);
+
+-- Insertion des badges de base
+INSERT INTO badges (name, description, image_path, category)
+VALUES 
+    ('Débutant écolo', 'Bienvenue dans la communauté écologique !', '/assets/images/badges/beginner.svg', 'participation'),
+    ('Écologiste en herbe', 'Vous avez accumulé 100 points écologiques', '/assets/images/badges/green_starter.svg', 'participation'),
+    ('Champion vert', 'Vous avez accumulé 500 points écologiques', '/assets/images/badges/green_champion.svg', 'participation'),
+    ('Maître de la durabilité', 'Vous avez accumulé 1000 points écologiques', '/assets/images/badges/sustainability_master.svg', 'participation'),
+    ('Premier défi', 'Vous avez complété votre premier défi', '/assets/images/badges/first_challenge.svg', 'challenge'),
+    ('Défieur en série', 'Vous avez complété 5 défis', '/assets/images/badges/serial_challenger.svg', 'challenge'),
+    ('Bénévole', 'Vous avez participé à votre première activité', '/assets/images/badges/volunteer.svg', 'participation'),
+    ('Ambassadeur BDD', 'Vous avez participé à 10 activités', '/assets/images/badges/ambassador.svg', 'participation'),
+    ('Habitude durable', 'Vous avez pointé 7 jours d''affilée sur vos défis', '/assets/images/badges/steady_habit.svg', 'challenge'),
+    ('Main verte', 'Vous avez participé à 3 plantations', '/assets/images/badges/green_thumb.svg', 'special'),
+    ('Sprint écolo', 'Vous avez gagné 200 points en 30 jours', '/assets/images/badges/eco_sprint.svg', 'special');
+
+-- Critères des badges de base
+INSERT INTO badge_rules (badge_id, rule_type, threshold, window_days, category)
+VALUES
+    (1, 'total_points', 0, NULL, NULL),
+    (2, 'total_points', 100, NULL, NULL),
+    (3, 'total_points', 500, NULL, NULL),
+    (4, 'total_points', 1000, NULL, NULL),
+    (5, 'challenges_completed', 1, NULL, NULL),
+    (6, 'challenges_completed', 5, NULL, NULL),
+    (7, 'activities_attended', 1, NULL, NULL),
+    (8, 'activities_attended', 10, NULL, NULL),
+    (9, 'streak', 7, NULL, NULL),
+    (10, 'category_activities', 3, NULL, 'planting'),
+    (11, 'points_in_window', 200, 30, NULL);
+
+-- Insertion de quelques défis écologiques
+INSERT INTO eco_challenges (title, description, points, duration_days, is_active, requires_daily_checkin)
+VALUES 
+    ('Zéro déchet pendant une semaine', 'Essayez de ne produire aucun déchet non recyclable pendant une semaine entière.', 100, 7, 1, 1),
+    ('Transport écologique', 'Utilisez uniquement des transports en commun, vélo ou marche pendant 5 jours consécutifs.', 75, 5, 1, 1),
+    ('Réduction d''énergie', 'Réduisez votre consommation d''électricité de 20% pendant 10 jours.', 120, 10, 1, 0),
+    ('Alimentation locale', 'Ne consommez que des produits locaux (moins de 100km) pendant 3 jours.', 50, 3, 1, 1);
+
+-- Insertion de quelques activités
+INSERT INTO activities (title, description, image_path, start_date, end_date, location, latitude, longitude, max_participants, eco_points, category)
+VALUES 
+    ('Atelier zéro déchet', 'Apprenez à fabriquer vos propres produits ménagers écologiques.', '/assets/images/events/workshop.jpg', 
+     datetime('now', '+7 days'), datetime('now', '+7 days', '+3 hours'), 'Salle A103, Paris Ynov Campus', 48.8895, 2.2404, 20, 30, 'workshop'),
+    
+    ('Nettoyage du parc', 'Collecte de déchets dans le parc à proximité du campus.', '/assets/images/events/cleanup.jpg', 
+     datetime('now', '+14 days'), datetime('now', '+14 days', '+4 hours'), 'Parc Martin Luther King', 48.8875, 2.3137, 30, 50, 'cleanup'),
+    
+    ('Conférence sur l''économie circulaire', 'Venez découvrir comment réduire votre impact environnemental grâce à l''économie circulaire.', '/assets/images/events/conference.jpg', 
+     datetime('now', '+21 days'), datetime('now', '+21 days', '+2 hours'), 'Amphithéâtre, Paris Ynov Campus', 48.8895, 2.2404, 100, 20, 'conference');
+-- Tags des activités initiales
+INSERT INTO activity_tags (activity_id, tag)
+VALUES
+    (1, 'zéro-déchet'), (1, 'diy'),
+    (2, 'plein-air'), (2, 'déchets'),
+    (3, 'économie-circulaire');
+
+-- Facteurs d'impact des activités et défis initiaux
+INSERT INTO impact_factors (activity_id, challenge_id, metric, mode, value)
+VALUES
+    (2, NULL, 'waste_kg', 'reported', 50),
+    (NULL, 1, 'waste_kg', 'fixed', 2),
+    (NULL, 2, 'co2_kg', 'fixed', 8),
+    (NULL, 3, 'co2_kg', 'reported', 100),
+    (NULL, 4, 'co2_kg', 'fixed', 3);
+
+-- Insertion de récompenses par défaut
+INSERT INTO rewards (name, description, image_path, partner, cost, stock)
+VALUES
+    ('Tote bag du BDD', 'Sac en coton bio aux couleurs du BDD.', '/assets/images/rewards/tote_bag.svg', NULL, 150, 30),
+    ('Gourde inox', 'Gourde réutilisable de 500 ml.', '/assets/images/rewards/gourde.svg', NULL, 300, 20),
+    ('Bon réparation vélo', 'Bon de 15 € valable à l''atelier vélo partenaire.', '/assets/images/rewards/velo.svg', 'Atelier vélo solidaire', 500, 10);